@@ -0,0 +1,236 @@
+package readfiles
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"sync"
+)
+
+//----------------------------------------------------------- Archive Limits --------------------------------------------------------
+
+// Errors wrapped by LimitError, one per limit, so callers can tell them apart with errors.Is.
+var (
+	ErrTooManyEntries   = errors.New("archive has too many entries")
+	ErrEntryTooLarge    = errors.New("entry exceeds the uncompressed size limit")
+	ErrArchiveTooLarge  = errors.New("archive exceeds the total uncompressed size limit")
+	ErrCompressionRatio = errors.New("entry exceeds the compression ratio limit")
+	ErrUnsafePath       = errors.New("entry path escapes the destination directory")
+	ErrUnsupportedEntry = errors.New("entry type cannot be extracted")
+)
+
+// ratioGrace is the number of bytes an entry may produce before its compression ratio is checked,
+// so that small, highly compressible files such as an empty sheet are not rejected.
+const ratioGrace = 1 << 20
+
+// ArchiveLimits protects archive reading against zip bombs. The limits are checked against the bytes actually
+// produced while an entry is decompressed, not against the sizes stored in the archive, which an attacker controls.
+// A zero field means no limit.
+type ArchiveLimits struct {
+	// MaxEntries is the largest number of files read across the archive and its nested archives.
+	MaxEntries int
+	// MaxEntryBytes is the largest uncompressed size of one entry.
+	MaxEntryBytes int64
+	// MaxTotalBytes is the largest number of uncompressed bytes produced across all entries.
+	// Bytes are counted at every nesting level, as each level costs a full decompression.
+	MaxTotalBytes int64
+	// MaxRatio is the largest ratio of uncompressed to compressed bytes of one entry.
+	MaxRatio float64
+}
+
+// DefaultArchiveLimits returns limits suited to archives received from untrusted users:
+// 10 000 entries, 1 GiB per entry, 4 GiB in total and a compression ratio of 200.
+func DefaultArchiveLimits() ArchiveLimits {
+	return ArchiveLimits{
+		MaxEntries:    10000,
+		MaxEntryBytes: 1 << 30,
+		MaxTotalBytes: 4 << 30,
+		MaxRatio:      200,
+	}
+}
+
+// LimitError reports the entry that broke an archive limit. Err is one of the ErrTooManyEntries,
+// ErrEntryTooLarge, ErrArchiveTooLarge or ErrCompressionRatio values.
+type LimitError struct {
+	Path   string
+	Err    error
+	Detail string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %v (%s)", e.Path, e.Err, e.Detail)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// archiveBudget tracks the entries and bytes of one top-level archive read. It is shared by the nested
+// archives, and the first limit broken is kept so that the whole read stops, whatever StopOnError says.
+type archiveBudget struct {
+	limits ArchiveLimits
+
+	mu        sync.Mutex
+	entries   int
+	total     int64
+	violation error
+
+	// parsedEntries and parsedRows feed ReadOptions.OnParse.
+	parsedEntries int
+	parsedRows    int
+}
+
+// withBudget starts a budget for a top-level read. Nested reads keep the budget they were given.
+func (o ReadOptions) withBudget() ReadOptions {
+	if o.budget == nil {
+		o.budget = &archiveBudget{limits: o.Limits}
+	}
+	return o
+}
+
+// addEntry counts one more file of the archive.
+func (b *archiveBudget) addEntry(pPath string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.violation != nil {
+		return b.violation
+	}
+	b.entries++
+	if b.limits.MaxEntries > 0 && b.entries > b.limits.MaxEntries {
+		b.violation = &LimitError{Path: pPath, Err: ErrTooManyEntries, Detail: fmt.Sprintf("more than %d", b.limits.MaxEntries)}
+	}
+	return b.violation
+}
+
+// parsed adds pEntries entries and pRows rows to the parse counts and returns the running totals.
+func (b *archiveBudget) parsed(pEntries int, pRows int) (int, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.parsedEntries += pEntries
+	b.parsedRows += pRows
+	return b.parsedEntries, b.parsedRows
+}
+
+// failed returns the limit broken so far, if any.
+func (b *archiveBudget) failed() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.violation
+}
+
+// consume counts pCount bytes produced by an entry that has now produced pEntryBytes from pCompressed compressed bytes.
+// A container, the decompressed stream of a .tar.gz, is only checked for its ratio: the files inside it are counted on their own.
+func (b *archiveBudget) consume(pPath string, pCount int, pEntryBytes int64, pCompressed int64, pContainer bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.violation != nil {
+		return b.violation
+	}
+	if !pContainer {
+		b.total += int64(pCount)
+	}
+
+	switch {
+	case !pContainer && b.limits.MaxEntryBytes > 0 && pEntryBytes > b.limits.MaxEntryBytes:
+		b.violation = &LimitError{Path: pPath, Err: ErrEntryTooLarge, Detail: fmt.Sprintf("more than %d bytes", b.limits.MaxEntryBytes)}
+	case b.limits.MaxTotalBytes > 0 && b.total > b.limits.MaxTotalBytes:
+		b.violation = &LimitError{Path: pPath, Err: ErrArchiveTooLarge, Detail: fmt.Sprintf("more than %d bytes", b.limits.MaxTotalBytes)}
+	case b.limits.MaxRatio > 0 && pCompressed >= 0 && pEntryBytes > ratioGrace:
+		lRatio := float64(pEntryBytes) / float64(max(pCompressed, 1))
+		if lRatio > b.limits.MaxRatio {
+			b.violation = &LimitError{Path: pPath, Err: ErrCompressionRatio, Detail: fmt.Sprintf("ratio %.0f, limit %.0f", lRatio, b.limits.MaxRatio)}
+		}
+	}
+	return b.violation
+}
+
+// limitedReader counts the bytes read from an entry against the budget and fails as soon as a limit is broken.
+type limitedReader struct {
+	reader     io.Reader
+	path       string
+	budget     *archiveBudget
+	read       int64
+	compressed func() int64
+	container  bool
+}
+
+// limitEntry wraps the stream of an entry. pCompressed returns the compressed bytes behind it so far,
+// or -1 when the entry is stored without compression; it may be nil for the same meaning.
+// The budget must have been started with withBudget.
+func limitEntry(pReader io.Reader, pPath string, pOptions ReadOptions, pCompressed func() int64) *limitedReader {
+	if pCompressed == nil {
+		pCompressed = func() int64 { return -1 }
+	}
+	return &limitedReader{reader: pReader, path: pPath, budget: pOptions.budget, compressed: pCompressed}
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	lCount, lErr := r.reader.Read(p)
+	r.read += int64(lCount)
+	if lLimitErr := r.budget.consume(r.path, lCount, r.read, r.compressed(), r.container); lLimitErr != nil {
+		return lCount, lLimitErr
+	}
+	return lCount, lErr
+}
+
+// countingReader counts the bytes read through it, such as the compressed side of a gzip stream.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	lCount, lErr := r.reader.Read(p)
+	r.count += int64(lCount)
+	return lCount, lErr
+}
+
+// openCountedZip opens an unencrypted ZIP entry together with a count of the compressed bytes its decompressor
+// has consumed so far, for the ratio limit. A deflated entry is decompressed here from its raw bytes, since the
+// compressed size in the archive is attacker-controlled: a short stream padded up to a large declared size would
+// otherwise pass. Stored entries report -1; other methods go through the zip package, and the declared size is
+// then used as an upper bound.
+func openCountedZip(pFile *zip.File) (io.ReadCloser, func() int64, error) {
+	switch pFile.Method {
+	case zip.Store:
+		lReader, lErr := pFile.Open()
+		return lReader, func() int64 { return -1 }, lErr
+	case zip.Deflate:
+		lRaw, lErr := pFile.OpenRaw()
+		if lErr != nil {
+			return nil, nil, lErr
+		}
+		lCounted := &countingReader{reader: lRaw}
+		lReader := &crcReader{reader: flate.NewReader(lCounted), want: pFile.CRC32, hash: crc32.NewIEEE()}
+		return lReader, func() int64 { return lCounted.count }, nil
+	default:
+		lReader, lErr := pFile.Open()
+		return lReader, func() int64 { return int64(pFile.CompressedSize64) }, lErr
+	}
+}
+
+// crcReader checks the CRC-32 of an entry decompressed outside the zip package when it has been read to the end.
+type crcReader struct {
+	reader io.ReadCloser
+	want   uint32
+	hash   hash.Hash32
+}
+
+func (r *crcReader) Read(p []byte) (int, error) {
+	lCount, lErr := r.reader.Read(p)
+	r.hash.Write(p[:lCount])
+	if lErr == io.EOF && r.hash.Sum32() != r.want {
+		return lCount, zip.ErrChecksum
+	}
+	return lCount, lErr
+}
+
+func (r *crcReader) Close() error {
+	return r.reader.Close()
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,166 @@
+package readfiles
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+func TestArchiveLimits(t *testing.T) {
+	lBomb := strings.Repeat("0", 4<<20)
+	lTar := tarBytes(t, testFile{"a.csv", "1,2\n"}, testFile{"b.csv", "3,4\n"}, testFile{"c.csv", "5,6\n"})
+
+	lCases := []struct {
+		name    string
+		files   []testFile
+		limits  ArchiveLimits
+		wantErr error
+	}{
+		{
+			name:   "within the limits",
+			files:  []testFile{{"a.csv", "1,2\n"}, {"b.csv", strings.Repeat("x,y\n", 100)}},
+			limits: ArchiveLimits{MaxEntries: 2, MaxEntryBytes: 400, MaxTotalBytes: 404, MaxRatio: 2},
+		},
+		{
+			name:    "too many entries",
+			files:   []testFile{{"a.csv", "1\n"}, {"b.csv", "2\n"}, {"c.csv", "3\n"}},
+			limits:  ArchiveLimits{MaxEntries: 2},
+			wantErr: ErrTooManyEntries,
+		},
+		{
+			name:    "entries of nested archives count",
+			files:   []testFile{{"a.csv", "1\n"}, {"inner.tar", string(lTar)}},
+			limits:  ArchiveLimits{MaxEntries: 3},
+			wantErr: ErrTooManyEntries,
+		},
+		{
+			name:    "entry too large",
+			files:   []testFile{{"a.csv", strings.Repeat("1,2\n", 10)}},
+			limits:  ArchiveLimits{MaxEntryBytes: 39},
+			wantErr: ErrEntryTooLarge,
+		},
+		{
+			name:    "total too large",
+			files:   []testFile{{"a.csv", strings.Repeat("1,2\n", 10)}, {"b.csv", strings.Repeat("1,2\n", 10)}},
+			limits:  ArchiveLimits{MaxTotalBytes: 79},
+			wantErr: ErrArchiveTooLarge,
+		},
+		{
+			name:    "compression ratio",
+			files:   []testFile{{"bomb.csv", lBomb}},
+			limits:  ArchiveLimits{MaxRatio: 100},
+			wantErr: ErrCompressionRatio,
+		},
+		{
+			name:    "compression ratio of a nested gzip",
+			files:   []testFile{{"bomb.csv.gz", string(gzipBytes(t, "bomb.csv", []byte(lBomb)))}},
+			limits:  ArchiveLimits{MaxRatio: 100},
+			wantErr: ErrCompressionRatio,
+		},
+		{
+			name:   "small compressible entry within the grace",
+			files:  []testFile{{"empty.csv", strings.Repeat(",", 100000)}},
+			limits: ArchiveLimits{MaxRatio: 10},
+		},
+		{
+			name:    "default limits",
+			files:   []testFile{{"bomb.csv", lBomb}},
+			limits:  DefaultArchiveLimits(),
+			wantErr: ErrCompressionRatio,
+		},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			lArchive := zipBytes(t, lCase.files...)
+			lReader, lErr := zip.NewReader(bytes.NewReader(lArchive), int64(len(lArchive)))
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			// Limits stop the read whatever StopOnError says.
+			_, lErr = ReadZipEntries(lReader, ReadOptions{Limits: lCase.limits})
+			if lCase.wantErr == nil {
+				if lErr != nil {
+					t.Fatal(lErr)
+				}
+				return
+			}
+			var lLimitErr *LimitError
+			if !errors.Is(lErr, lCase.wantErr) || !errors.As(lErr, &lLimitErr) || lLimitErr.Path == "" {
+				t.Fatalf("err = %v, want a *LimitError for %v", lErr, lCase.wantErr)
+			}
+		})
+	}
+}
+
+// paddedBomb is a ZIP whose deflated entry declares a compressed size padded far beyond its real stream,
+// so that the ratio computed from the archive's header looks harmless.
+func paddedBomb(t *testing.T, pContent []byte) []byte {
+	t.Helper()
+	var lDeflated bytes.Buffer
+	lCompressor, _ := flate.NewWriter(&lDeflated, flate.BestCompression)
+	lCompressor.Write(pContent)
+	lCompressor.Close()
+	lPadding := bytes.Repeat([]byte{0}, 1<<20)
+
+	var lArchive bytes.Buffer
+	lWriter := zip.NewWriter(&lArchive)
+	lEntry, lErr := lWriter.CreateRaw(&zip.FileHeader{
+		Name:               "bomb.csv",
+		Method:             zip.Deflate,
+		CRC32:              crc32.ChecksumIEEE(pContent),
+		CompressedSize64:   uint64(lDeflated.Len() + len(lPadding)),
+		UncompressedSize64: uint64(len(pContent)),
+	})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lEntry.Write(lDeflated.Bytes())
+	lEntry.Write(lPadding)
+	lWriter.Close()
+	return lArchive.Bytes()
+}
+
+func TestArchiveLimitsDeclaredSize(t *testing.T) {
+	lContent := bytes.Repeat([]byte("0"), 4<<20)
+	lArchive := paddedBomb(t, lContent)
+	lReader, lErr := zip.NewReader(bytes.NewReader(lArchive), int64(len(lArchive)))
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	if lFile := lReader.File[0]; float64(lFile.UncompressedSize64)/float64(lFile.CompressedSize64) > 10 {
+		t.Fatalf("declared ratio %d/%d, want it to look harmless", lFile.UncompressedSize64, lFile.CompressedSize64)
+	}
+
+	// The ratio comes from the compressed bytes actually read, so the padding does not hide the bomb.
+	lOptions := ReadOptions{Limits: ArchiveLimits{MaxRatio: 100}}
+	if _, lErr := ReadZipEntries(lReader, lOptions); !errors.Is(lErr, ErrCompressionRatio) {
+		t.Errorf("ReadZipEntries err = %v, want ErrCompressionRatio", lErr)
+	}
+	if _, lErr := ExtractZip(lReader, t.TempDir(), lOptions); !errors.Is(lErr, ErrCompressionRatio) {
+		t.Errorf("ExtractZip err = %v, want ErrCompressionRatio", lErr)
+	}
+
+	// Without the limit the entry still reads, with its CRC-32 checked.
+	lResults, lErr := ReadZipEntries(lReader, ReadOptions{})
+	if lErr != nil || len(lResults) != 1 || lResults[0].Err != nil || len(lResults[0].Rows) != 1 {
+		t.Fatalf("results = %+v, %v", lResults, lErr)
+	}
+}
+
+func TestArchiveLimitsTarGzip(t *testing.T) {
+	lBomb := gzipBytes(t, "bomb.tar", tarBytes(t, testFile{"bomb.csv", strings.Repeat("0", 4<<20)}))
+	_, lErr := ReadArchiveReader("bomb.tgz", bytes.NewReader(lBomb), int64(len(lBomb)), ReadOptions{Limits: ArchiveLimits{MaxRatio: 100}})
+	if !errors.Is(lErr, ErrCompressionRatio) {
+		t.Fatalf("err = %v, want ErrCompressionRatio", lErr)
+	}
+
+	lSmall := gzipBytes(t, "small.tar", tarBytes(t, testFile{"a.csv", "1,2\n"}))
+	lResults, lErr := ReadArchiveReader("small.tgz", bytes.NewReader(lSmall), int64(len(lSmall)), ReadOptions{Limits: ArchiveLimits{MaxRatio: 100, MaxEntryBytes: 4}})
+	if lErr != nil || len(lResults) != 1 {
+		t.Fatalf("results = %v, %v", lResults, lErr)
+	}
+}
@@ -0,0 +1,143 @@
+package readfiles
+
+import (
+	"log"
+	"net/url"
+	"sync"
+	"time"
+)
+
+//----------------------------------------------------------- Batch Download --------------------------------------------------------
+
+// BatchOptions controls the concurrency of ReadBatch.
+type BatchOptions struct {
+	// Workers is the number of archives downloaded and parsed at the same time. Zero means 4.
+	Workers int
+	// MaxPerHost caps the downloads running at the same time against one host. Zero means no cap beyond Workers.
+	MaxPerHost int
+	// HostInterval is the smallest gap between the start of two downloads from the same host. Zero means no gap.
+	HostInterval time.Duration
+}
+
+// BatchResult is the outcome of one URL of a batch.
+type BatchResult struct {
+	URL      string
+	Entries  []EntryResult
+	Err      error
+	Duration time.Duration
+}
+
+// ReadBatch downloads and reads many archives with ReadZipWithOptions, a few at a time.
+// The results are in the order of pUrls, and a failed URL does not stop the others.
+// Every download uses its own temporary storage, so archives with the same file name do not clash;
+// a URL listed twice is downloaded once and its result repeated.
+
+// Step-by-Step Process:
+// 1. Start the workers and hand them the index of each distinct URL.
+// 2. Before each download, wait for a free slot and the rate interval of the URL's host.
+// 3. Read the archive and store the result at the URL's position.
+// 4. Copy the result of repeated URLs and return the list.
+func ReadBatch(pUrls []string, pOptions ReadOptions, pBatch BatchOptions) []BatchResult {
+	log.Println("ReadBatch(+)")
+
+	lResults := make([]BatchResult, len(pUrls))
+	lWorkers := pBatch.Workers
+	if lWorkers <= 0 {
+		lWorkers = 4
+	}
+	lLimiter := &hostLimiter{
+		interval:   pBatch.HostInterval,
+		maxPerHost: pBatch.MaxPerHost,
+		next:       make(map[string]time.Time),
+		running:    make(map[string]int),
+	}
+	lLimiter.cond = sync.NewCond(&lLimiter.mu)
+
+	lFirst := make(map[string]int, len(pUrls))
+	lJobs := make(chan int)
+	var lWait sync.WaitGroup
+	for i := 0; i < lWorkers; i++ {
+		lWait.Add(1)
+		go func() {
+			defer lWait.Done()
+			for lIndex := range lJobs {
+				lUrl := pUrls[lIndex]
+				lHost := batchHost(lUrl)
+
+				lLimiter.acquire(lHost)
+				lStart := time.Now()
+				lEntries, lErr := ReadZipWithOptions(lUrl, "", pOptions)
+				lLimiter.release(lHost)
+
+				lResults[lIndex] = BatchResult{URL: lUrl, Entries: lEntries, Err: lErr, Duration: time.Since(lStart)}
+			}
+		}()
+	}
+
+	for lIndex, lUrl := range pUrls {
+		if _, lSeen := lFirst[lUrl]; lSeen {
+			continue
+		}
+		lFirst[lUrl] = lIndex
+		lJobs <- lIndex
+	}
+	close(lJobs)
+	lWait.Wait()
+
+	for lIndex, lUrl := range pUrls {
+		if lFirstIndex := lFirst[lUrl]; lFirstIndex != lIndex {
+			lResults[lIndex] = lResults[lFirstIndex]
+		}
+	}
+
+	log.Println("ReadBatch(-)")
+	return lResults
+}
+
+// hostLimiter spaces out and caps the downloads made to each host.
+type hostLimiter struct {
+	interval   time.Duration
+	maxPerHost int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	next    map[string]time.Time
+	running map[string]int
+}
+
+// acquire waits for a free slot on pHost, then for the host's next start time.
+func (l *hostLimiter) acquire(pHost string) {
+	l.mu.Lock()
+	for l.maxPerHost > 0 && l.running[pHost] >= l.maxPerHost {
+		l.cond.Wait()
+	}
+	l.running[pHost]++
+
+	lStart := time.Now()
+	if lNext, lOk := l.next[pHost]; lOk && lNext.After(lStart) {
+		lStart = lNext
+	}
+	l.next[pHost] = lStart.Add(l.interval)
+	l.mu.Unlock()
+
+	time.Sleep(time.Until(lStart))
+}
+
+// release frees the slot taken by acquire.
+func (l *hostLimiter) release(pHost string) {
+	l.mu.Lock()
+	l.running[pHost]--
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
+
+// batchHost returns the host that a URL's downloads are limited under.
+func batchHost(pUrl string) string {
+	lParsed, lErr := url.Parse(pUrl)
+	if lErr != nil {
+		return ""
+	}
+	return lParsed.Host
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
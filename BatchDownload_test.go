@@ -0,0 +1,124 @@
+package readfiles
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadBatch(t *testing.T) {
+	lArchives := map[string][]byte{
+		"/a/daily.zip": zipBytes(t, testFile{"a.csv", "1,2\n"}),
+		"/b/daily.zip": zipBytes(t, testFile{"b.csv", "3,4\n"}),
+		"/c.zip":       zipBytes(t, testFile{"c.csv", "5,6\n"}),
+	}
+	var lMu sync.Mutex
+	lHits := make(map[string]int)
+	lRunning, lPeak := 0, 0
+	lServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lMu.Lock()
+		lHits[r.URL.Path]++
+		lRunning++
+		if lRunning > lPeak {
+			lPeak = lRunning
+		}
+		lMu.Unlock()
+		defer func() {
+			lMu.Lock()
+			lRunning--
+			lMu.Unlock()
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		lArchive, lOk := lArchives[r.URL.Path]
+		if !lOk {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(lArchive)
+	}))
+	defer lServer.Close()
+
+	lUrls := []string{
+		lServer.URL + "/a/daily.zip",
+		lServer.URL + "/missing.zip",
+		lServer.URL + "/b/daily.zip",
+		lServer.URL + "/a/daily.zip",
+		lServer.URL + "/c.zip",
+	}
+	lResults := ReadBatch(lUrls, ReadOptions{Retry: RetryPolicy{MaxAttempts: 1}}, BatchOptions{Workers: 4, MaxPerHost: 2})
+
+	if len(lResults) != len(lUrls) {
+		t.Fatalf("%d results, want %d", len(lResults), len(lUrls))
+	}
+	lWant := []string{"a.csv", "", "b.csv", "a.csv", "c.csv"}
+	for lIndex, lResult := range lResults {
+		if lResult.URL != lUrls[lIndex] {
+			t.Errorf("results[%d].URL = %s, want %s", lIndex, lResult.URL, lUrls[lIndex])
+		}
+		if lWant[lIndex] == "" {
+			if lResult.Err == nil {
+				t.Errorf("results[%d]: missing archive did not fail", lIndex)
+			}
+			continue
+		}
+		// Archives with the same file name must not overwrite each other.
+		if lResult.Err != nil || len(lResult.Entries) != 1 || lResult.Entries[0].Name != lWant[lIndex] {
+			t.Errorf("results[%d] = %v, %v; want %s", lIndex, lResult.Entries, lResult.Err, lWant[lIndex])
+		}
+	}
+	if lHits["/a/daily.zip"] != 1 {
+		t.Errorf("repeated URL downloaded %d times, want 1", lHits["/a/daily.zip"])
+	}
+	if lPeak > 2 {
+		t.Errorf("%d downloads ran at once against one host, want at most 2", lPeak)
+	}
+}
+
+func TestReadBatchHostInterval(t *testing.T) {
+	lArchive := zipBytes(t, testFile{"a.csv", "1,2\n"})
+	var lMu sync.Mutex
+	var lStarts []time.Time
+	lServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lMu.Lock()
+		lStarts = append(lStarts, time.Now())
+		lMu.Unlock()
+		w.Write(lArchive)
+	}))
+	defer lServer.Close()
+
+	var lUrls []string
+	for _, lName := range []string{"a", "b", "c"} {
+		lUrls = append(lUrls, lServer.URL+"/"+lName+".zip")
+	}
+	lInterval := 50 * time.Millisecond
+	for _, lResult := range ReadBatch(lUrls, ReadOptions{}, BatchOptions{Workers: 3, HostInterval: lInterval}) {
+		if lResult.Err != nil {
+			t.Fatal(lResult.Err)
+		}
+	}
+	if len(lStarts) != 3 {
+		t.Fatalf("%d requests, want 3", len(lStarts))
+	}
+	// The server sees the requests a little after the limiter lets them go, so allow some slack.
+	for i := 1; i < len(lStarts); i++ {
+		if lGap := lStarts[i].Sub(lStarts[i-1]); lGap < lInterval-10*time.Millisecond {
+			t.Errorf("gap between downloads %d and %d = %v, want at least %v", i-1, i, lGap, lInterval)
+		}
+	}
+}
+
+func TestBatchHost(t *testing.T) {
+	lCases := map[string]string{
+		"https://archives.nseindia.com/a.zip":      "archives.nseindia.com",
+		"https://archives.nseindia.com:8443/a.zip": "archives.nseindia.com:8443",
+		"::not a url": "",
+	}
+	for lUrl, lWant := range lCases {
+		if lGot := batchHost(lUrl); lGot != lWant {
+			t.Errorf("batchHost(%q) = %q, want %q", lUrl, lGot, lWant)
+		}
+	}
+}
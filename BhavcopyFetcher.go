@@ -0,0 +1,184 @@
+package readfiles
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//---------------------------------------------------------- Bhavcopy Fetcher -------------------------------------------------------
+
+// HolidayCalendar holds the exchange holidays on which no bhavcopy is published, keyed by "2006-01-02".
+type HolidayCalendar map[string]bool
+
+// NewHolidayCalendar builds a calendar from dates written as "2006-01-02".
+func NewHolidayCalendar(pDates ...string) (HolidayCalendar, error) {
+	lCalendar := make(HolidayCalendar, len(pDates))
+	for _, lDate := range pDates {
+		lParsed, lErr := time.Parse("2006-01-02", strings.TrimSpace(lDate))
+		if lErr != nil {
+			return nil, fmt.Errorf("NewHolidayCalendar:001 %w", lErr)
+		}
+		lCalendar.Add(lParsed)
+	}
+	return lCalendar, nil
+}
+
+// Add marks pDate as a holiday.
+func (c HolidayCalendar) Add(pDate time.Time) {
+	c[pDate.Format("2006-01-02")] = true
+}
+
+// IsHoliday reports whether pDate is a holiday.
+func (c HolidayCalendar) IsHoliday(pDate time.Time) bool {
+	return c[pDate.Format("2006-01-02")]
+}
+
+// BhavcopyOptions describes the daily files to fetch.
+type BhavcopyOptions struct {
+	// Template is the URL with date tokens in braces, for example
+	// "https://nsearchives.nseindia.com/content/historical/EQUITIES/{YYYY}/{MON}/cm{ddMONyyyy}bhav.csv.zip".
+	// See ExpandDateTemplate for the tokens.
+	Template string
+	// From and To are the first and last dates fetched, both included.
+	From time.Time
+	To   time.Time
+	// Holidays lists the dates that are skipped besides the weekend.
+	Holidays HolidayCalendar
+	// Weekend lists the days that are never fetched. Nil means Saturday and Sunday.
+	Weekend []time.Weekday
+	// Read is passed to ReadZipWithOptions for each file.
+	Read ReadOptions
+	// Batch controls how many files are fetched at once and how fast. Exchanges throttle
+	// aggressive clients, so a single worker with a HostInterval is a sensible start.
+	Batch BatchOptions
+}
+
+// BhavcopyResult is the outcome for one trading date.
+// NoTradingDay is set when the server answered 404, which exchanges do for unlisted holidays;
+// it is not an error. Rows holds the rows of all entries, joined as ReadZip does.
+type BhavcopyResult struct {
+	Date         time.Time
+	URL          string
+	Entries      []EntryResult
+	Rows         [][]string
+	NoTradingDay bool
+	Err          error
+}
+
+// FetchBhavcopy fetches and reads the file of every trading date between From and To.
+
+// Step-by-Step Process:
+// 1. List the dates of the range, leaving out the weekend and the holiday calendar.
+// 2. Expand the URL template for each date.
+// 3. Download and read the files through ReadBatch.
+// 4. Turn a 404 into NoTradingDay and return one result per date, in date order.
+func FetchBhavcopy(pOptions BhavcopyOptions) ([]BhavcopyResult, error) {
+	log.Println("FetchBhavcopy(+)")
+
+	if pOptions.To.Before(pOptions.From) {
+		return nil, fmt.Errorf("FetchBhavcopy:001 range ends (%s) before it starts (%s)", pOptions.To.Format("2006-01-02"), pOptions.From.Format("2006-01-02"))
+	}
+	lWeekend := pOptions.Weekend
+	if lWeekend == nil {
+		lWeekend = []time.Weekday{time.Saturday, time.Sunday}
+	}
+
+	var lResults []BhavcopyResult
+	var lUrls []string
+	lLast := dateOnly(pOptions.To)
+	for lDate := dateOnly(pOptions.From); !lDate.After(lLast); lDate = lDate.AddDate(0, 0, 1) {
+		if weekdayIn(lDate.Weekday(), lWeekend) || pOptions.Holidays.IsHoliday(lDate) {
+			continue
+		}
+		lUrl, lErr := ExpandDateTemplate(pOptions.Template, lDate)
+		if lErr != nil {
+			return nil, fmt.Errorf("FetchBhavcopy:002 %w", lErr)
+		}
+		lResults = append(lResults, BhavcopyResult{Date: lDate, URL: lUrl})
+		lUrls = append(lUrls, lUrl)
+	}
+
+	for i, lBatch := range ReadBatch(lUrls, pOptions.Read, pOptions.Batch) {
+		var lDownloadErr *DownloadError
+		if errors.As(lBatch.Err, &lDownloadErr) && lDownloadErr.StatusCode == http.StatusNotFound {
+			lResults[i].NoTradingDay = true
+			continue
+		}
+		lResults[i].Entries = lBatch.Entries
+		lResults[i].Rows = joinEntryRows(lBatch.Entries)
+		lResults[i].Err = lBatch.Err
+	}
+
+	log.Println("FetchBhavcopy(-)")
+	return lResults, nil
+}
+
+// ExpandDateTemplate replaces the date tokens of pTemplate with parts of pDate. The tokens are:
+//
+//	{YYYY}      2023       {YY}   23
+//	{MM}        10         {DD}   06
+//	{MON}       OCT        {Mon}  Oct
+//	{ddMONyyyy} 06OCT2023  {ddMMyyyy} 06102023  {yyyyMMdd} 20231006
+//
+// An unknown or unclosed token is an error, so that a typo does not turn into a run of 404s.
+func ExpandDateTemplate(pTemplate string, pDate time.Time) (string, error) {
+	var lExpanded strings.Builder
+	lRest := pTemplate
+	for {
+		lOpen := strings.IndexByte(lRest, '{')
+		if lOpen < 0 {
+			lExpanded.WriteString(lRest)
+			return lExpanded.String(), nil
+		}
+		lClose := strings.IndexByte(lRest[lOpen:], '}')
+		if lClose < 0 {
+			return "", fmt.Errorf("ExpandDateTemplate:001 unclosed token in %q", pTemplate)
+		}
+		lExpanded.WriteString(lRest[:lOpen])
+
+		lToken := lRest[lOpen+1 : lOpen+lClose]
+		switch lToken {
+		case "YYYY":
+			lExpanded.WriteString(pDate.Format("2006"))
+		case "YY":
+			lExpanded.WriteString(pDate.Format("06"))
+		case "MM":
+			lExpanded.WriteString(pDate.Format("01"))
+		case "DD":
+			lExpanded.WriteString(pDate.Format("02"))
+		case "MON":
+			lExpanded.WriteString(strings.ToUpper(pDate.Format("Jan")))
+		case "Mon":
+			lExpanded.WriteString(pDate.Format("Jan"))
+		case "ddMONyyyy":
+			lExpanded.WriteString(strings.ToUpper(pDate.Format("02Jan2006")))
+		case "ddMMyyyy":
+			lExpanded.WriteString(pDate.Format("02012006"))
+		case "yyyyMMdd":
+			lExpanded.WriteString(pDate.Format("20060102"))
+		default:
+			return "", fmt.Errorf("ExpandDateTemplate:002 unknown token {%s} in %q", lToken, pTemplate)
+		}
+		lRest = lRest[lOpen+lClose+1:]
+	}
+}
+
+// dateOnly drops the time of day, keeping the location.
+func dateOnly(pDate time.Time) time.Time {
+	return time.Date(pDate.Year(), pDate.Month(), pDate.Day(), 0, 0, 0, 0, pDate.Location())
+}
+
+func weekdayIn(pDay time.Weekday, pDays []time.Weekday) bool {
+	for _, lDay := range pDays {
+		if lDay == pDay {
+			return true
+		}
+	}
+	return false
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
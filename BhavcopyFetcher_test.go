@@ -0,0 +1,106 @@
+package readfiles
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpandDateTemplate(t *testing.T) {
+	lDate := time.Date(2023, time.October, 6, 15, 30, 0, 0, time.UTC)
+	lCases := []struct {
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{template: "https://x/{YYYY}/{MON}/cm{ddMONyyyy}bhav.csv.zip", want: "https://x/2023/OCT/cm06OCT2023bhav.csv.zip"},
+		{template: "{YY}{MM}{DD}-{Mon}", want: "231006-Oct"},
+		{template: "fo{ddMMyyyy}.zip|{yyyyMMdd}", want: "fo06102023.zip|20231006"},
+		{template: "no tokens", want: "no tokens"},
+		{template: "{yyyy}", wantErr: true},
+		{template: "cm{DD", wantErr: true},
+	}
+	for _, lCase := range lCases {
+		lGot, lErr := ExpandDateTemplate(lCase.template, lDate)
+		if lCase.wantErr {
+			if lErr == nil {
+				t.Errorf("ExpandDateTemplate(%q) = %q, want an error", lCase.template, lGot)
+			}
+			continue
+		}
+		if lErr != nil || lGot != lCase.want {
+			t.Errorf("ExpandDateTemplate(%q) = %q, %v; want %q", lCase.template, lGot, lErr, lCase.want)
+		}
+	}
+}
+
+func TestNewHolidayCalendar(t *testing.T) {
+	lCalendar, lErr := NewHolidayCalendar("2023-10-24", " 2023-11-14 ")
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	if !lCalendar.IsHoliday(time.Date(2023, time.November, 14, 9, 15, 0, 0, time.Local)) || lCalendar.IsHoliday(time.Date(2023, time.October, 25, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("calendar = %v", lCalendar)
+	}
+	if _, lErr := NewHolidayCalendar("24-10-2023"); lErr == nil {
+		t.Error("a malformed date was accepted")
+	}
+}
+
+func TestFetchBhavcopy(t *testing.T) {
+	lServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "05OCT2023"):
+			w.Write(zipBytes(t, testFile{"cm05OCT2023bhav.csv", "SYMBOL,CLOSE\nINFY,1450\n"}))
+		case strings.Contains(r.URL.Path, "10OCT2023"):
+			http.Error(w, "maintenance", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer lServer.Close()
+
+	lHolidays, _ := NewHolidayCalendar("2023-10-09")
+	lOptions := BhavcopyOptions{
+		Template: lServer.URL + "/{YYYY}/{MON}/cm{ddMONyyyy}bhav.csv.zip",
+		// Thursday to Tuesday: the weekend and the Monday holiday are skipped.
+		From:     time.Date(2023, time.October, 5, 18, 0, 0, 0, time.UTC),
+		To:       time.Date(2023, time.October, 10, 0, 0, 0, 0, time.UTC),
+		Holidays: lHolidays,
+		Read:     ReadOptions{Retry: RetryPolicy{MaxAttempts: 1}},
+	}
+	lResults, lErr := FetchBhavcopy(lOptions)
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+
+	var lDates []string
+	for _, lResult := range lResults {
+		lDates = append(lDates, lResult.Date.Format("2006-01-02"))
+	}
+	if lWant := []string{"2023-10-05", "2023-10-06", "2023-10-10"}; !reflect.DeepEqual(lDates, lWant) {
+		t.Fatalf("dates = %q, want %q", lDates, lWant)
+	}
+	if lWant := [][]string{{"SYMBOL", "CLOSE"}, {"INFY", "1450"}}; lResults[0].Err != nil || !reflect.DeepEqual(lResults[0].Rows, lWant) {
+		t.Errorf("trading day = %q, %v; want %q", lResults[0].Rows, lResults[0].Err, lWant)
+	}
+	if !lResults[1].NoTradingDay || lResults[1].Err != nil {
+		t.Errorf("404 = NoTradingDay %v, %v; want a non-trading day without error", lResults[1].NoTradingDay, lResults[1].Err)
+	}
+	if lResults[2].NoTradingDay || lResults[2].Err == nil {
+		t.Errorf("500 = NoTradingDay %v, %v; want an error", lResults[2].NoTradingDay, lResults[2].Err)
+	}
+
+	lOptions.From, lOptions.To = lOptions.To, lOptions.From
+	if _, lErr := FetchBhavcopy(lOptions); lErr == nil {
+		t.Error("a reversed range was accepted")
+	}
+	lOptions.From, lOptions.To = lOptions.To, lOptions.From
+	lOptions.Template = lServer.URL + "/{DDMMYYYY}.zip"
+	if _, lErr := FetchBhavcopy(lOptions); lErr == nil {
+		t.Error("an unknown token was accepted")
+	}
+}
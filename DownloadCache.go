@@ -0,0 +1,351 @@
+package readfiles
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//----------------------------------------------------------- Download Cache --------------------------------------------------------
+
+// CacheEviction chooses which entries DownloadCache removes first when it is over MaxBytes.
+type CacheEviction int
+
+const (
+	// EvictLeastRecentlyUsed removes the entries that were read longest ago.
+	EvictLeastRecentlyUsed CacheEviction = iota
+	// EvictOldest removes the entries that were downloaded longest ago.
+	EvictOldest
+)
+
+// DownloadCache is an on-disk cache of downloaded archives keyed by URL.
+// Each entry keeps the server's ETag and Last-Modified values, which are sent back as
+// If-None-Match / If-Modified-Since so that an unchanged archive costs a 304 instead of a download,
+// and a SHA-256 of the content, which is checked before a cached file is reused unless the file still has
+// the size and modification time recorded when it was stored.
+// A DownloadCache is safe for concurrent use.
+type DownloadCache struct {
+	// Dir holds the cached files. It is created by NewDownloadCache.
+	Dir string
+	// MaxAge is how long an entry is reused without asking the server. Zero revalidates on every use.
+	MaxAge time.Duration
+	// MaxBytes caps the total size of the cached files. Zero means no cap.
+	MaxBytes int64
+	// Eviction chooses the entries removed when the cache is over MaxBytes.
+	Eviction CacheEviction
+
+	mu sync.Mutex
+}
+
+// cacheEntry is the metadata stored next to each cached file.
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	SHA256       string    `json:"sha256"`
+	Size         int64     `json:"size"`
+	ModTime      time.Time `json:"modTime,omitempty"`
+	StoredAt     time.Time `json:"storedAt"`
+	LastUsed     time.Time `json:"lastUsed"`
+}
+
+// staleCacheTemp is how long a partial download or metadata file must have been left untouched before
+// NewDownloadCache treats it as left behind by a crashed writer and removes it.
+const staleCacheTemp = time.Hour
+
+// NewDownloadCache creates the cache directory if needed and returns a cache using it.
+// Partial files left in the directory by a crashed process are removed.
+func NewDownloadCache(pDir string) (*DownloadCache, error) {
+	lErr := os.MkdirAll(pDir, 0o755)
+	if lErr != nil {
+		return nil, fmt.Errorf("NewDownloadCache:001 %w", lErr)
+	}
+	lCache := &DownloadCache{Dir: pDir}
+	lCache.sweepTemp()
+	return lCache, nil
+}
+
+// sweepTemp removes the *.part and *.json.tmp files not modified for staleCacheTemp.
+// Younger ones may belong to a download still running in another process and are kept.
+func (c *DownloadCache) sweepTemp() {
+	lParts, _ := filepath.Glob(filepath.Join(c.Dir, "*.part"))
+	lTemps, _ := filepath.Glob(filepath.Join(c.Dir, "*.json.tmp"))
+	for _, lPath := range append(lParts, lTemps...) {
+		lInfo, lErr := os.Stat(lPath)
+		if lErr == nil && time.Since(lInfo.ModTime()) > staleCacheTemp {
+			log.Println("DownloadCache: removing stale", filepath.Base(lPath))
+			os.Remove(lPath)
+		}
+	}
+}
+
+// Remove deletes the cached copy of pUrl, if any.
+func (c *DownloadCache) Remove(pUrl string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.removeKey(cacheKey(pUrl))
+}
+
+// fetch returns the archive at pUrl, from the cache when it is still valid and from the server otherwise.
+
+// Step-by-Step Process:
+// 1. Load the entry for the URL and check that its file still matches the stored size and hash.
+// 2. If the entry is younger than MaxAge, reuse it without a request.
+// 3. Otherwise send a conditional request built from the stored ETag and Last-Modified.
+// 4. On 304, reload the entry under the lock, mark it as revalidated and reuse it.
+// 5. On 200, store the new body and metadata, then apply the size cap.
+func (c *DownloadCache) fetch(pUrl string, pOptions ReadOptions) (*spooledDownload, error) {
+	lKey := cacheKey(pUrl)
+
+	c.mu.Lock()
+	lEntry, lOk := c.loadValid(lKey)
+	if lOk && c.MaxAge > 0 && time.Since(lEntry.StoredAt) < c.MaxAge {
+		lDownload, lErr := c.useLocked(lKey, lEntry, false)
+		if lErr == nil {
+			c.mu.Unlock()
+			log.Println("DownloadCache: fresh copy of", pUrl)
+			return lDownload, nil
+		}
+		lOk = false
+	}
+	c.mu.Unlock()
+
+	lHeaders := make(http.Header)
+	if lOk {
+		if lEntry.ETag != "" {
+			lHeaders.Set("If-None-Match", lEntry.ETag)
+		}
+		if lEntry.LastModified != "" {
+			lHeaders.Set("If-Modified-Since", lEntry.LastModified)
+		}
+	}
+
+	lResponse, lErr := fetchURL(pUrl, lHeaders, pOptions)
+	if lErr != nil {
+		return nil, fmt.Errorf("DownloadCache:001 %w", lErr)
+	}
+	defer lResponse.Body.Close()
+
+	if lResponse.StatusCode == http.StatusNotModified {
+		if !lOk {
+			return nil, fmt.Errorf("DownloadCache:002 %s answered 304 without a cached copy", pUrl)
+		}
+		log.Println("DownloadCache: not modified", pUrl)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		// Another caller may have stored a new body while the lock was released, so save the entry as it is now.
+		lEntry, lOk = c.loadValid(lKey)
+		if !lOk {
+			return nil, fmt.Errorf("DownloadCache:008 cached copy of %s was removed during revalidation", pUrl)
+		}
+		return c.useLocked(lKey, lEntry, true)
+	}
+
+	// Write to a private file first so a failed download never replaces a good cached copy.
+	lTemp, lErr := os.CreateTemp(c.Dir, lKey+"-*.part")
+	if lErr != nil {
+		return nil, fmt.Errorf("DownloadCache:003 %w", lErr)
+	}
+	lHash := sha256.New()
+	lBody := progressBody(lResponse.Body, pUrl, 0, lResponse.ContentLength, pOptions)
+	lSize, lErr := io.Copy(io.MultiWriter(lTemp, lHash), lBody)
+	lCloseErr := lTemp.Close()
+	if lErr == nil {
+		lErr = lCloseErr
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if lErr == nil {
+		lErr = os.Rename(lTemp.Name(), c.dataPath(lKey))
+	}
+	if lErr != nil {
+		os.Remove(lTemp.Name())
+		return nil, fmt.Errorf("DownloadCache:004 %w", lErr)
+	}
+
+	lNow := time.Now()
+	var lModTime time.Time
+	if lInfo, lErr := os.Stat(c.dataPath(lKey)); lErr == nil {
+		lModTime = lInfo.ModTime()
+	}
+	lEntry = cacheEntry{
+		URL:          pUrl,
+		ETag:         lResponse.Header.Get("ETag"),
+		LastModified: lResponse.Header.Get("Last-Modified"),
+		SHA256:       hex.EncodeToString(lHash.Sum(nil)),
+		Size:         lSize,
+		ModTime:      lModTime,
+		StoredAt:     lNow,
+		LastUsed:     lNow,
+	}
+	lErr = c.saveEntry(lKey, lEntry)
+	if lErr != nil {
+		return nil, fmt.Errorf("DownloadCache:005 %w", lErr)
+	}
+	c.evictLocked(lKey)
+
+	return c.openLocked(lKey, lEntry)
+}
+
+// useLocked marks an entry as used (and as revalidated when pRevalidated is set) and opens its file.
+func (c *DownloadCache) useLocked(pKey string, pEntry cacheEntry, pRevalidated bool) (*spooledDownload, error) {
+	pEntry.LastUsed = time.Now()
+	if pRevalidated {
+		pEntry.StoredAt = pEntry.LastUsed
+	}
+	lErr := c.saveEntry(pKey, pEntry)
+	if lErr != nil {
+		return nil, fmt.Errorf("DownloadCache:006 %w", lErr)
+	}
+	return c.openLocked(pKey, pEntry)
+}
+
+// openLocked opens the cached file of an entry as a download that is kept on Close.
+func (c *DownloadCache) openLocked(pKey string, pEntry cacheEntry) (*spooledDownload, error) {
+	lFile, lErr := os.Open(c.dataPath(pKey))
+	if lErr != nil {
+		return nil, fmt.Errorf("DownloadCache:007 %w", lErr)
+	}
+	return &spooledDownload{file: lFile, size: pEntry.Size}, nil
+}
+
+// loadValid reads the metadata of an entry and checks its file against the stored size and hash.
+// The hash, which costs a read of the whole file under the lock, is skipped when the file still has the
+// recorded modification time; after a successful check the current one is recorded for the next save.
+// A damaged entry is removed and reported as missing.
+func (c *DownloadCache) loadValid(pKey string) (cacheEntry, bool) {
+	var lEntry cacheEntry
+	lData, lErr := os.ReadFile(c.metaPath(pKey))
+	if lErr != nil {
+		return lEntry, false
+	}
+	if json.Unmarshal(lData, &lEntry) != nil {
+		c.removeKey(pKey)
+		return lEntry, false
+	}
+
+	lFile, lErr := os.Open(c.dataPath(pKey))
+	if lErr != nil {
+		c.removeKey(pKey)
+		return lEntry, false
+	}
+	defer lFile.Close()
+	lInfo, lErr := lFile.Stat()
+	if lErr == nil && lInfo.Size() == lEntry.Size && !lEntry.ModTime.IsZero() && lInfo.ModTime().Equal(lEntry.ModTime) {
+		return lEntry, true
+	}
+	lHash := sha256.New()
+	lSize, lErr := io.Copy(lHash, lFile)
+	if lErr != nil || lSize != lEntry.Size || hex.EncodeToString(lHash.Sum(nil)) != lEntry.SHA256 {
+		log.Println("DownloadCache: discarding damaged copy of", lEntry.URL)
+		c.removeKey(pKey)
+		return lEntry, false
+	}
+	if lInfo != nil {
+		lEntry.ModTime = lInfo.ModTime()
+	}
+	return lEntry, true
+}
+
+// saveEntry writes the metadata of an entry atomically.
+func (c *DownloadCache) saveEntry(pKey string, pEntry cacheEntry) error {
+	lData, lErr := json.Marshal(pEntry)
+	if lErr != nil {
+		return lErr
+	}
+	lTemp := c.metaPath(pKey) + ".tmp"
+	lErr = os.WriteFile(lTemp, lData, 0o644)
+	if lErr != nil {
+		return lErr
+	}
+	return os.Rename(lTemp, c.metaPath(pKey))
+}
+
+// evictLocked removes entries, in the configured order, until the cache fits in MaxBytes.
+// The entry pKeep, which was just stored, is never removed.
+func (c *DownloadCache) evictLocked(pKeep string) {
+	if c.MaxBytes <= 0 {
+		return
+	}
+
+	type keyedEntry struct {
+		key   string
+		entry cacheEntry
+	}
+	var lEntries []keyedEntry
+	var lTotal int64
+
+	lMetaFiles, _ := filepath.Glob(filepath.Join(c.Dir, "*.json"))
+	for _, lMetaFile := range lMetaFiles {
+		lData, lErr := os.ReadFile(lMetaFile)
+		if lErr != nil {
+			continue
+		}
+		var lEntry cacheEntry
+		if json.Unmarshal(lData, &lEntry) != nil {
+			continue
+		}
+		lKey := strings.TrimSuffix(filepath.Base(lMetaFile), ".json")
+		lEntries = append(lEntries, keyedEntry{lKey, lEntry})
+		lTotal += lEntry.Size
+	}
+
+	sort.Slice(lEntries, func(i, j int) bool {
+		if c.Eviction == EvictOldest {
+			return lEntries[i].entry.StoredAt.Before(lEntries[j].entry.StoredAt)
+		}
+		return lEntries[i].entry.LastUsed.Before(lEntries[j].entry.LastUsed)
+	})
+
+	for _, lItem := range lEntries {
+		if lTotal <= c.MaxBytes {
+			break
+		}
+		if lItem.key == pKeep {
+			continue
+		}
+		if c.removeKey(lItem.key) == nil {
+			log.Println("DownloadCache: evicted", lItem.entry.URL)
+			lTotal -= lItem.entry.Size
+		}
+	}
+}
+
+// removeKey deletes the file and metadata of an entry.
+func (c *DownloadCache) removeKey(pKey string) error {
+	lErr := os.Remove(c.dataPath(pKey))
+	if lErr != nil && !os.IsNotExist(lErr) {
+		return lErr
+	}
+	lErr = os.Remove(c.metaPath(pKey))
+	if lErr != nil && !os.IsNotExist(lErr) {
+		return lErr
+	}
+	return nil
+}
+
+func (c *DownloadCache) dataPath(pKey string) string {
+	return filepath.Join(c.Dir, pKey+".data")
+}
+
+func (c *DownloadCache) metaPath(pKey string) string {
+	return filepath.Join(c.Dir, pKey+".json")
+}
+
+// cacheKey names the files of a URL's entry.
+func cacheKey(pUrl string) string {
+	lSum := sha256.Sum256([]byte(pUrl))
+	return hex.EncodeToString(lSum[:])
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
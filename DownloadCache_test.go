@@ -0,0 +1,197 @@
+package readfiles
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// spoolText reads a whole download and closes it.
+func spoolText(t *testing.T, pDownload *spooledDownload) string {
+	t.Helper()
+	defer pDownload.Close()
+	lData, lErr := io.ReadAll(io.NewSectionReader(pDownload.ReaderAt(), 0, pDownload.Size()))
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	return string(lData)
+}
+
+// versionedServer serves pBody with pETag and answers 304 to a matching If-None-Match.
+type versionedServer struct {
+	mu        sync.Mutex
+	body      string
+	etag      string
+	requests  int
+	validated int
+	onVerify  func()
+}
+
+func (s *versionedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	lBody, lETag, lHook := s.body, s.etag, s.onVerify
+	lMatch := r.Header.Get("If-None-Match") == lETag
+	if lMatch {
+		s.validated++
+	}
+	s.mu.Unlock()
+
+	if lMatch {
+		if lHook != nil {
+			lHook()
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", lETag)
+	io.WriteString(w, lBody)
+}
+
+func TestDownloadCacheFetch(t *testing.T) {
+	lCases := []struct {
+		name          string
+		maxAge        time.Duration
+		changeTo      string
+		damage        string
+		want          string
+		wantRequests  int
+		wantValidated int
+	}{
+		{name: "revalidated with 304", want: "v1", wantRequests: 2, wantValidated: 1},
+		{name: "fresh copy without a request", maxAge: time.Hour, want: "v1", wantRequests: 1},
+		{name: "changed on the server", changeTo: "v2", want: "v2", wantRequests: 2},
+		{name: "damaged copy is downloaded again", damage: "xx", want: "v1", wantRequests: 2},
+		// The size is unchanged, so only the hash check catches it.
+		{name: "damaged copy of the same size", damage: "v9", want: "v1", wantRequests: 2},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			lHandler := &versionedServer{body: "v1", etag: `"1"`}
+			lServer := httptest.NewServer(lHandler)
+			defer lServer.Close()
+
+			lCache, lErr := NewDownloadCache(t.TempDir())
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			lCache.MaxAge = lCase.maxAge
+			lDownload, lErr := lCache.fetch(lServer.URL, ReadOptions{})
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			spoolText(t, lDownload)
+
+			if lCase.changeTo != "" {
+				lHandler.body, lHandler.etag = lCase.changeTo, `"2"`
+			}
+			if lCase.damage != "" {
+				lPath := lCache.dataPath(cacheKey(lServer.URL))
+				os.WriteFile(lPath, []byte(lCase.damage), 0o644)
+				os.Chtimes(lPath, time.Now(), time.Now().Add(time.Second))
+			}
+			lDownload, lErr = lCache.fetch(lServer.URL, ReadOptions{})
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			if lGot := spoolText(t, lDownload); lGot != lCase.want {
+				t.Errorf("content = %q, want %q", lGot, lCase.want)
+			}
+			if lHandler.requests != lCase.wantRequests || lHandler.validated != lCase.wantValidated {
+				t.Errorf("requests = %d (%d validated), want %d (%d)", lHandler.requests, lHandler.validated, lCase.wantRequests, lCase.wantValidated)
+			}
+		})
+	}
+}
+
+func TestDownloadCacheNotModifiedAfterStore(t *testing.T) {
+	lHandler := &versionedServer{body: "old", etag: `"1"`}
+	lServer := httptest.NewServer(lHandler)
+	defer lServer.Close()
+
+	lCache, lErr := NewDownloadCache(t.TempDir())
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lDownload, lErr := lCache.fetch(lServer.URL, ReadOptions{})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	spoolText(t, lDownload)
+
+	// While the revalidation is in flight, another caller stores a newer body for the same URL.
+	lKey := cacheKey(lServer.URL)
+	lHandler.onVerify = func() {
+		lBody := []byte("newer body")
+		lSum := sha256.Sum256(lBody)
+		os.WriteFile(lCache.dataPath(lKey), lBody, 0o644)
+		lCache.saveEntry(lKey, cacheEntry{URL: lServer.URL, ETag: `"2"`, SHA256: hex.EncodeToString(lSum[:]), Size: int64(len(lBody)), StoredAt: time.Now()})
+	}
+	lDownload, lErr = lCache.fetch(lServer.URL, ReadOptions{})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	if lGot := spoolText(t, lDownload); lGot != "newer body" {
+		t.Errorf("content = %q, want the newer body", lGot)
+	}
+	if lEntry, lOk := lCache.loadValid(lKey); !lOk || lEntry.ETag != `"2"` {
+		t.Errorf("entry = %+v, %v; want the newer entry kept", lEntry, lOk)
+	}
+}
+
+func TestDownloadCacheSweep(t *testing.T) {
+	lDir := t.TempDir()
+	lOld := time.Now().Add(-2 * staleCacheTemp)
+	for _, lName := range []string{"a-1.part", "b.json.tmp", "c-2.part", "d.data"} {
+		lPath := filepath.Join(lDir, lName)
+		os.WriteFile(lPath, []byte("x"), 0o644)
+		if lName != "c-2.part" {
+			os.Chtimes(lPath, lOld, lOld)
+		}
+	}
+	if _, lErr := NewDownloadCache(lDir); lErr != nil {
+		t.Fatal(lErr)
+	}
+	// Only stale temporary files go; a recent one may be a download running in another process.
+	lGot, _ := filepath.Glob(filepath.Join(lDir, "*"))
+	if lWant := []string{filepath.Join(lDir, "c-2.part"), filepath.Join(lDir, "d.data")}; !reflect.DeepEqual(lGot, lWant) {
+		t.Errorf("files = %q, want %q", lGot, lWant)
+	}
+}
+
+func TestDownloadCacheEviction(t *testing.T) {
+	lServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "0123456789")
+	}))
+	defer lServer.Close()
+
+	lCache, lErr := NewDownloadCache(t.TempDir())
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lCache.MaxBytes = 25
+	lUrls := []string{lServer.URL + "/a", lServer.URL + "/b", lServer.URL + "/c"}
+	for _, lUrl := range lUrls {
+		lDownload, lErr := lCache.fetch(lUrl, ReadOptions{})
+		if lErr != nil {
+			t.Fatal(lErr)
+		}
+		spoolText(t, lDownload)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for lIndex, lUrl := range lUrls {
+		_, lOk := lCache.loadValid(cacheKey(lUrl))
+		if lOk != (lIndex > 0) {
+			t.Errorf("%s cached = %v, want only the two newest kept", lUrl, lOk)
+		}
+	}
+}
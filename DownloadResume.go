@@ -0,0 +1,306 @@
+package readfiles
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//---------------------------------------------------------- Resumable Download -----------------------------------------------------
+
+// ResumeOptions keeps partial downloads on disk so that a dropped transfer continues where it stopped.
+type ResumeOptions struct {
+	// Dir holds the partial downloads. Empty turns resuming off.
+	Dir string
+	// SHA256, if set, is the expected hex SHA-256 of the complete file.
+	SHA256 string
+}
+
+// ErrIncompleteDownload is returned when a finished download is shorter or longer than the server announced.
+var ErrIncompleteDownload = errors.New("download length does not match the announced size")
+
+// ErrChecksumMismatch is returned when a downloaded file does not have the expected hash.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ErrDownloadInProgress is returned when another resumable download of the same URL is using its partial file.
+var ErrDownloadInProgress = errors.New("a resumable download of this URL is already in progress")
+
+// activeParts holds the partial files in use by this process, so that two downloads of one URL
+// never append to and truncate the same file.
+var activeParts = struct {
+	sync.Mutex
+	paths map[string]bool
+}{paths: make(map[string]bool)}
+
+// partialDownload is the metadata stored next to a partial file. The validators are sent back as If-Range
+// so that a file changed on the server is downloaded again from the start instead of being spliced.
+type partialDownload struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Total        int64  `json:"total"`
+	NoRanges     bool   `json:"noRanges,omitempty"`
+}
+
+// resumableDownload downloads pUrl into pOptions.Resume.Dir, continuing any earlier partial download with Range requests.
+// The partial file is claimed for the whole download; a second download of the same URL in this
+// process fails with ErrDownloadInProgress instead of sharing it.
+
+// Step-by-Step Process:
+// 1. Load the partial file and its metadata; start from zero when they do not match.
+// 2. Request the rest of the file with Range and If-Range, or the whole file when ranges are not supported.
+// 3. On 206, check that Content-Range starts at our offset and append; on 200, start over; on 416, finish or start over.
+// 4. If the body breaks off, keep what arrived and try again, up to Retry.MaxAttempts times.
+// 5. Move the finished file to a private name and check its length and its hash against Resume.SHA256.
+// 6. Return the file; it is removed when the download is closed.
+func resumableDownload(pUrl string, pOptions ReadOptions) (*spooledDownload, error) {
+	lKey := cacheKey(pUrl)
+	lPartPath := filepath.Join(pOptions.Resume.Dir, lKey+".part")
+	lMetaPath := lPartPath + ".json"
+
+	lErr := os.MkdirAll(pOptions.Resume.Dir, 0o755)
+	if lErr != nil {
+		return nil, fmt.Errorf("resumableDownload:001 %w", lErr)
+	}
+	if !claimPart(lPartPath) {
+		return nil, fmt.Errorf("resumableDownload:004 %w: %s", ErrDownloadInProgress, pUrl)
+	}
+	defer releasePart(lPartPath)
+
+	lState := partialDownload{URL: pUrl}
+	if lData, lErr := os.ReadFile(lMetaPath); lErr == nil {
+		if json.Unmarshal(lData, &lState) != nil || lState.URL != pUrl {
+			lState = partialDownload{URL: pUrl}
+		}
+	}
+
+	lAttempts := pOptions.Retry.MaxAttempts
+	if lAttempts < 1 {
+		lAttempts = 1
+	}
+	// Each HTTP request already retries its own connection failures, so one attempt here covers the body.
+	lRequestOptions := pOptions
+	lRequestOptions.Retry.MaxAttempts = 1
+
+	var lLastErr error
+	for lAttempt := 1; lAttempt <= lAttempts; lAttempt++ {
+		if lAttempt > 1 {
+			lWait := backoffDelay(normalizedRetry(pOptions.Retry), lAttempt-1)
+			log.Println("resumableDownload: attempt", lAttempt-1, "failed:", lLastErr, "- retrying in", lWait)
+			time.Sleep(lWait)
+		}
+
+		lComplete, lErr := resumeOnce(pUrl, lPartPath, &lState, lRequestOptions)
+		lData, _ := json.Marshal(lState)
+		os.WriteFile(lMetaPath, lData, 0o644)
+		if lErr != nil {
+			lLastErr = lErr
+			var lDownloadErr *DownloadError
+			if errors.As(lErr, &lDownloadErr) && lDownloadErr.StatusCode != 0 && !retryableStatus(lDownloadErr.StatusCode) {
+				break
+			}
+			continue
+		}
+		if !lComplete {
+			continue
+		}
+
+		// The finished file leaves the part name, so the next download of the URL starts fresh while this one is read.
+		lDonePath, lErr := moveFinishedPart(lPartPath, lKey)
+		os.Remove(lMetaPath)
+		if lErr != nil {
+			os.Remove(lPartPath)
+			return nil, fmt.Errorf("resumableDownload:002 %w", lErr)
+		}
+		lDownload, lErr := finishResumable(lDonePath, lState, pOptions.Resume.SHA256)
+		if lErr != nil {
+			os.Remove(lDonePath)
+			return nil, fmt.Errorf("resumableDownload:002 %w", lErr)
+		}
+		return lDownload, nil
+	}
+	return nil, fmt.Errorf("resumableDownload:003 %w", lLastErr)
+}
+
+// claimPart marks a partial file as in use. It reports false when another download already holds it.
+func claimPart(pPartPath string) bool {
+	activeParts.Lock()
+	defer activeParts.Unlock()
+	if activeParts.paths[pPartPath] {
+		return false
+	}
+	activeParts.paths[pPartPath] = true
+	return true
+}
+
+// releasePart marks a partial file as free again.
+func releasePart(pPartPath string) {
+	activeParts.Lock()
+	defer activeParts.Unlock()
+	delete(activeParts.paths, pPartPath)
+}
+
+// moveFinishedPart renames a completed partial file to a unique name in the same directory.
+func moveFinishedPart(pPartPath string, pKey string) (string, error) {
+	lTemp, lErr := os.CreateTemp(filepath.Dir(pPartPath), pKey+"-*.done")
+	if lErr != nil {
+		return "", lErr
+	}
+	lTemp.Close()
+	lErr = os.Rename(pPartPath, lTemp.Name())
+	if lErr != nil {
+		os.Remove(lTemp.Name())
+		return "", lErr
+	}
+	return lTemp.Name(), nil
+}
+
+// resumeOnce makes one request for the missing part of the file and appends what arrives.
+// It reports whether the file is now complete.
+func resumeOnce(pUrl string, pPartPath string, pState *partialDownload, pOptions ReadOptions) (bool, error) {
+	var lOffset int64
+	if lInfo, lErr := os.Stat(pPartPath); lErr == nil {
+		lOffset = lInfo.Size()
+	}
+
+	lHeaders := make(http.Header)
+	// Ranges count encoded bytes, so the body must not be compressed in transit.
+	lHeaders.Set("Accept-Encoding", "identity")
+	if lOffset > 0 && !pState.NoRanges {
+		lHeaders.Set("Range", "bytes="+strconv.FormatInt(lOffset, 10)+"-")
+		if pState.ETag != "" && !strings.HasPrefix(pState.ETag, "W/") {
+			lHeaders.Set("If-Range", pState.ETag)
+		} else if pState.LastModified != "" {
+			lHeaders.Set("If-Range", pState.LastModified)
+		}
+	}
+
+	lResponse, lErr := fetchURL(pUrl, lHeaders, pOptions)
+	if lErr != nil {
+		var lDownloadErr *DownloadError
+		if errors.As(lErr, &lDownloadErr) && lDownloadErr.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			// Either the file is already complete, or it shrank on the server; in the latter case start over
+			// at once, since the 416 itself is not worth a retry.
+			if pState.Total > 0 && lOffset == pState.Total {
+				return true, nil
+			}
+			if lOffset > 0 {
+				log.Println("resumeOnce: partial file of", pUrl, "no longer fits the remote file - starting over")
+				os.Remove(pPartPath)
+				*pState = partialDownload{URL: pState.URL}
+				return resumeOnce(pUrl, pPartPath, pState, pOptions)
+			}
+		}
+		return false, lErr
+	}
+	defer lResponse.Body.Close()
+
+	lFlags := os.O_CREATE | os.O_WRONLY
+	switch lResponse.StatusCode {
+	case http.StatusPartialContent:
+		lStart, lTotal, lOk := parseContentRange(lResponse.Header.Get("Content-Range"))
+		if !lOk || lStart != lOffset {
+			// The server sent a range we did not ask for; drop it and download everything next time.
+			pState.NoRanges = true
+			os.Remove(pPartPath)
+			return false, fmt.Errorf("resumeOnce:001 unexpected Content-Range %q for offset %d", lResponse.Header.Get("Content-Range"), lOffset)
+		}
+		pState.Total = lTotal
+		lFlags |= os.O_APPEND
+	case http.StatusOK:
+		// A full body: ranges are unsupported, the If-Range validator no longer matches, or this is the first request.
+		pState.ETag = lResponse.Header.Get("ETag")
+		pState.LastModified = lResponse.Header.Get("Last-Modified")
+		pState.Total = lResponse.ContentLength
+		pState.NoRanges = !strings.EqualFold(strings.TrimSpace(lResponse.Header.Get("Accept-Ranges")), "bytes")
+		lFlags |= os.O_TRUNC
+	default:
+		return false, fmt.Errorf("resumeOnce:002 unexpected HTTP %d", lResponse.StatusCode)
+	}
+
+	lPart, lErr := os.OpenFile(pPartPath, lFlags, 0o644)
+	if lErr != nil {
+		return false, fmt.Errorf("resumeOnce:003 %w", lErr)
+	}
+	lStart := int64(0)
+	if lFlags&os.O_APPEND != 0 {
+		lStart = lOffset
+	}
+	_, lCopyErr := io.Copy(lPart, progressBody(lResponse.Body, pUrl, lStart, pState.Total, pOptions))
+	lErr = lPart.Close()
+	if lCopyErr != nil {
+		return false, fmt.Errorf("resumeOnce:004 %w", lCopyErr)
+	}
+	if lErr != nil {
+		return false, fmt.Errorf("resumeOnce:005 %w", lErr)
+	}
+	return true, nil
+}
+
+// finishResumable verifies the length and optional hash of a completed download and opens it.
+func finishResumable(pPartPath string, pState partialDownload, pSHA256 string) (*spooledDownload, error) {
+	lFile, lErr := os.Open(pPartPath)
+	if lErr != nil {
+		return nil, lErr
+	}
+	lDownload := &spooledDownload{file: lFile, temporary: true}
+
+	lHash := sha256.New()
+	lSize, lErr := io.Copy(lHash, lFile)
+	if lErr != nil {
+		lDownload.Close()
+		return nil, lErr
+	}
+	lDownload.size = lSize
+
+	if pState.Total >= 0 && lSize != pState.Total {
+		lDownload.Close()
+		return nil, fmt.Errorf("%w: got %d bytes, expected %d", ErrIncompleteDownload, lSize, pState.Total)
+	}
+	if pSHA256 != "" && !strings.EqualFold(hex.EncodeToString(lHash.Sum(nil)), pSHA256) {
+		lDownload.Close()
+		return nil, fmt.Errorf("%w: SHA-256 of %s", ErrChecksumMismatch, pState.URL)
+	}
+	return lDownload, nil
+}
+
+// parseContentRange reads "bytes start-end/total". A total of "*" is returned as -1.
+func parseContentRange(pValue string) (int64, int64, bool) {
+	lValue := strings.TrimSpace(pValue)
+	if !strings.HasPrefix(lValue, "bytes ") {
+		return 0, 0, false
+	}
+	lRange, lTotalText, lFound := strings.Cut(strings.TrimPrefix(lValue, "bytes "), "/")
+	if !lFound {
+		return 0, 0, false
+	}
+	lStartText, _, lFound := strings.Cut(lRange, "-")
+	if !lFound {
+		return 0, 0, false
+	}
+	lStart, lErr := strconv.ParseInt(strings.TrimSpace(lStartText), 10, 64)
+	if lErr != nil {
+		return 0, 0, false
+	}
+	lTotal := int64(-1)
+	if strings.TrimSpace(lTotalText) != "*" {
+		lTotal, lErr = strconv.ParseInt(strings.TrimSpace(lTotalText), 10, 64)
+		if lErr != nil {
+			return 0, 0, false
+		}
+	}
+	return lStart, lTotal, true
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
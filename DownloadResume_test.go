@@ -0,0 +1,258 @@
+package readfiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseContentRange(t *testing.T) {
+	lCases := []struct {
+		value       string
+		start, size int64
+		ok          bool
+	}{
+		{"bytes 0-9/10", 0, 10, true},
+		{" bytes 5-9/10 ", 5, 10, true},
+		{"bytes 5-9/*", 5, -1, true},
+		{"bytes */10", 0, 0, false},
+		{"bytes 5-9", 0, 0, false},
+		{"items 0-9/10", 0, 0, false},
+		{"bytes x-9/10", 0, 0, false},
+		{"bytes 0-9/ten", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, lCase := range lCases {
+		lStart, lSize, lOk := parseContentRange(lCase.value)
+		if lStart != lCase.start || lSize != lCase.size || lOk != lCase.ok {
+			t.Errorf("parseContentRange(%q) = %d, %d, %v; want %d, %d, %v", lCase.value, lStart, lSize, lOk, lCase.start, lCase.size, lCase.ok)
+		}
+	}
+}
+
+// flakyServer serves content like http.ServeContent, except that the bodies of the first cutOff
+// responses stop halfway. When replaceWith is set, it is served instead of content from the second
+// request on. It records the Range and If-Range headers of each request.
+type flakyServer struct {
+	mu          sync.Mutex
+	content     []byte
+	replaceWith []byte
+	etag        string
+	noRanges    bool
+	cutOff      int
+	ranges      []string
+	ifRanges    []string
+}
+
+func (s *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	s.ifRanges = append(s.ifRanges, r.Header.Get("If-Range"))
+	lCut := len(s.ranges) <= s.cutOff
+	if len(s.ranges) == 2 && s.replaceWith != nil {
+		s.content = s.replaceWith
+	}
+	lContent := s.content
+	s.mu.Unlock()
+
+	if s.etag != "" {
+		w.Header().Set("ETag", s.etag)
+	}
+	if s.noRanges {
+		r.Header.Del("Range")
+	}
+	if !lCut {
+		if s.noRanges {
+			w.Write(lContent)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(lContent))
+		return
+	}
+
+	// Announce the whole file but send only half of it, as a dropped connection would.
+	if !s.noRanges {
+		w.Header().Set("Accept-Ranges", "bytes")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(lContent)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(lContent[:len(lContent)/2])
+}
+
+func TestResumableDownload(t *testing.T) {
+	lContent := bytes.Repeat([]byte("0123456789"), 100)
+
+	lCases := []struct {
+		name      string
+		server    *flakyServer
+		partial   []byte
+		state     *partialDownload
+		wantRange []string
+		wantIf    []string
+	}{
+		{
+			name:      "continues after a dropped body",
+			server:    &flakyServer{content: lContent, etag: `"v1"`, cutOff: 1},
+			wantRange: []string{"", "bytes=500-"},
+			wantIf:    []string{"", `"v1"`},
+		},
+		{
+			name:      "continues a part left by an earlier run",
+			server:    &flakyServer{content: lContent, etag: `"v1"`},
+			partial:   lContent[:300],
+			state:     &partialDownload{ETag: `"v1"`, Total: 1000},
+			wantRange: []string{"bytes=300-"},
+			wantIf:    []string{`"v1"`},
+		},
+		{
+			name:      "If-Range mismatch starts over",
+			server:    &flakyServer{content: lContent, etag: `"v2"`},
+			partial:   []byte("stale content"),
+			state:     &partialDownload{ETag: `"v1"`, Total: 1000},
+			wantRange: []string{"bytes=13-"},
+			wantIf:    []string{`"v1"`},
+		},
+		{
+			name:      "weak ETag is not used for If-Range",
+			server:    &flakyServer{content: lContent, etag: `W/"v1"`},
+			partial:   lContent[:10],
+			state:     &partialDownload{ETag: `W/"v1"`, Total: 1000},
+			wantRange: []string{"bytes=10-"},
+			wantIf:    []string{""},
+		},
+		{
+			name:      "without Accept-Ranges the file is downloaded again",
+			server:    &flakyServer{content: lContent, noRanges: true, cutOff: 1},
+			wantRange: []string{"", ""},
+			wantIf:    []string{"", ""},
+		},
+		{
+			// Without a validator the server cannot tell the file changed, so the shrunk file answers 416.
+			name:      "file shrunk between attempts starts over",
+			server:    &flakyServer{content: bytes.Repeat(lContent, 2), replaceWith: lContent, cutOff: 1},
+			wantRange: []string{"", "bytes=1000-", ""},
+			wantIf:    []string{"", "", ""},
+		},
+		{
+			name:      "shrunk file left by an earlier run starts over",
+			server:    &flakyServer{content: lContent},
+			partial:   bytes.Repeat(lContent, 2)[:1500],
+			state:     &partialDownload{Total: 2000},
+			wantRange: []string{"bytes=1500-", ""},
+			wantIf:    []string{"", ""},
+		},
+		{
+			name:      "complete part answered with 416",
+			server:    &flakyServer{content: lContent, etag: `"v1"`},
+			partial:   lContent,
+			state:     &partialDownload{ETag: `"v1"`, Total: 1000},
+			wantRange: []string{"bytes=1000-"},
+			wantIf:    []string{`"v1"`},
+		},
+	}
+
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			lServer := httptest.NewServer(lCase.server)
+			defer lServer.Close()
+
+			lDir := t.TempDir()
+			lPartPath := filepath.Join(lDir, cacheKey(lServer.URL)+".part")
+			if lCase.partial != nil {
+				os.WriteFile(lPartPath, lCase.partial, 0o644)
+				lCase.state.URL = lServer.URL
+				writeJSON(t, lPartPath+".json", lCase.state)
+			}
+
+			lOptions := ReadOptions{Retry: fastRetry, Resume: ResumeOptions{Dir: lDir}}
+			lDownload, lErr := resumableDownload(lServer.URL, lOptions)
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			if lGot := spoolText(t, lDownload); lGot != string(lContent) {
+				t.Errorf("content = %d bytes, want %d", len(lGot), len(lContent))
+			}
+			if !reflect.DeepEqual(lCase.server.ranges, lCase.wantRange) || !reflect.DeepEqual(lCase.server.ifRanges, lCase.wantIf) {
+				t.Errorf("Range %q If-Range %q, want %q %q", lCase.server.ranges, lCase.server.ifRanges, lCase.wantRange, lCase.wantIf)
+			}
+			if lLeft, _ := os.ReadDir(lDir); len(lLeft) != 0 {
+				t.Errorf("files left behind: %v", lLeft)
+			}
+		})
+	}
+}
+
+func TestResumableDownloadErrors(t *testing.T) {
+	lServer := httptest.NewServer(&flakyServer{content: []byte("payload")})
+	defer lServer.Close()
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		lOptions := ReadOptions{Retry: fastRetry, Resume: ResumeOptions{Dir: t.TempDir(), SHA256: "00"}}
+		if _, lErr := resumableDownload(lServer.URL, lOptions); !errors.Is(lErr, ErrChecksumMismatch) {
+			t.Fatalf("err = %v, want ErrChecksumMismatch", lErr)
+		}
+	})
+
+	t.Run("part already in use", func(t *testing.T) {
+		lDir := t.TempDir()
+		lPartPath := filepath.Join(lDir, cacheKey(lServer.URL)+".part")
+		if !claimPart(lPartPath) {
+			t.Fatal("part was already claimed")
+		}
+		defer releasePart(lPartPath)
+
+		_, lErr := resumableDownload(lServer.URL, ReadOptions{Resume: ResumeOptions{Dir: lDir}})
+		if !errors.Is(lErr, ErrDownloadInProgress) {
+			t.Fatalf("err = %v, want ErrDownloadInProgress", lErr)
+		}
+	})
+
+	t.Run("concurrent downloads of one URL", func(t *testing.T) {
+		lOptions := ReadOptions{Retry: fastRetry, Resume: ResumeOptions{Dir: t.TempDir()}}
+		var lWait sync.WaitGroup
+		lErrs := make([]error, 8)
+		for lIndex := range lErrs {
+			lWait.Add(1)
+			go func(pIndex int) {
+				defer lWait.Done()
+				lDownload, lErr := resumableDownload(lServer.URL, lOptions)
+				if lErr == nil {
+					lData, _ := io.ReadAll(io.NewSectionReader(lDownload.ReaderAt(), 0, lDownload.Size()))
+					lDownload.Close()
+					if string(lData) != "payload" {
+						lErr = errors.New("corrupted content " + string(lData))
+					}
+				}
+				lErrs[pIndex] = lErr
+			}(lIndex)
+		}
+		lWait.Wait()
+		for _, lErr := range lErrs {
+			if lErr != nil && !errors.Is(lErr, ErrDownloadInProgress) {
+				t.Errorf("err = %v", lErr)
+			}
+		}
+	})
+}
+
+// writeJSON stores pValue as JSON in pPath.
+func writeJSON(t *testing.T, pPath string, pValue interface{}) {
+	t.Helper()
+	lData, lErr := json.Marshal(pValue)
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	if lErr := os.WriteFile(pPath, lData, 0o644); lErr != nil {
+		t.Fatal(lErr)
+	}
+}
@@ -0,0 +1,147 @@
+package readfiles
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//------------------------------------------------------- Download Storage ----------------------------------------------------------
+
+// DefaultMemoryLimit is the largest download kept in memory when ReadOptions.MemoryLimit is zero.
+const DefaultMemoryLimit int64 = 32 << 20
+
+// spooledDownload holds a downloaded body either in memory or in a file.
+// Close must always be called; it removes the file when it is a private temporary file.
+type spooledDownload struct {
+	data      []byte
+	file      *os.File
+	size      int64
+	temporary bool
+}
+
+// ReaderAt returns random access to the downloaded bytes, as needed by zip.NewReader.
+func (s *spooledDownload) ReaderAt() io.ReaderAt {
+	if s.file != nil {
+		return s.file
+	}
+	return bytes.NewReader(s.data)
+}
+
+// Size returns the number of downloaded bytes.
+func (s *spooledDownload) Size() int64 {
+	return s.size
+}
+
+// Close releases the download and deletes its temporary file, if any.
+func (s *spooledDownload) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	lName := s.file.Name()
+	lErr := s.file.Close()
+	s.file = nil
+	if s.temporary {
+		lErr = os.Remove(lName)
+	}
+	return lErr
+}
+
+// downloadArchive fetches pUrl and returns its body ready for random access.
+// With a DownloadCache the body comes from, or is stored in, the cache; with a resume directory it is
+// downloaded there in resumable steps; otherwise it is spooled into memory or a private temporary file
+// as described at spoolDownload.
+func downloadArchive(pUrl string, pFilename string, pOptions ReadOptions) (*spooledDownload, error) {
+	lDownload, lErr := fetchArchive(pUrl, pFilename, pOptions)
+	if lErr != nil || !pOptions.Verify.enabled() {
+		return lDownload, lErr
+	}
+
+	lErr = verifyDownload(pUrl, lDownload, pOptions)
+	if lErr != nil {
+		lDownload.Close()
+		if pOptions.Cache != nil {
+			// Do not serve the same bad copy again.
+			pOptions.Cache.Remove(pUrl)
+		}
+		return nil, lErr
+	}
+	return lDownload, nil
+}
+
+// fetchArchive gets the archive from the cache, a resumable download or a plain download, as configured.
+func fetchArchive(pUrl string, pFilename string, pOptions ReadOptions) (*spooledDownload, error) {
+	if pOptions.Cache != nil {
+		return pOptions.Cache.fetch(pUrl, pOptions)
+	}
+	if pOptions.Resume.Dir != "" {
+		return resumableDownload(pUrl, pOptions)
+	}
+
+	lResponse, lErr := fetchURL(pUrl, nil, pOptions)
+	if lErr != nil {
+		return nil, lErr
+	}
+	defer lResponse.Body.Close()
+
+	lBody := progressBody(lResponse.Body, pUrl, 0, lResponse.ContentLength, pOptions)
+	return spoolDownload(lBody, lResponse.ContentLength, pOptions.MemoryLimit, pFilename)
+}
+
+// spoolDownload copies pBody into memory when it fits in pLimit bytes, and into a new
+// os.CreateTemp file otherwise. pSizeHint is the expected length (-1 when unknown); a hint
+// above the limit goes straight to disk. pName is only used to make the temporary file name readable.
+
+// Step-by-Step Process:
+// 1. Default the limit and, unless the size hint already exceeds it, read up to limit+1 bytes into memory.
+// 2. If the body ended within the limit, return it as an in-memory download.
+// 3. Otherwise create a private temporary file, write the buffered bytes and copy the rest of the body.
+// 4. Remove the temporary file again if any step fails.
+func spoolDownload(pBody io.Reader, pSizeHint int64, pLimit int64, pName string) (*spooledDownload, error) {
+	if pLimit <= 0 {
+		pLimit = DefaultMemoryLimit
+	}
+
+	var lBuffer bytes.Buffer
+	if pSizeHint < 0 || pSizeHint <= pLimit {
+		lCount, lErr := io.CopyN(&lBuffer, pBody, pLimit+1)
+		if lErr != nil && lErr != io.EOF {
+			return nil, fmt.Errorf("spoolDownload:001 %w", lErr)
+		}
+		if lCount <= pLimit {
+			return &spooledDownload{data: lBuffer.Bytes(), size: lCount}, nil
+		}
+	}
+
+	lFile, lErr := os.CreateTemp("", tempPattern(pName))
+	if lErr != nil {
+		return nil, fmt.Errorf("spoolDownload:002 %w", lErr)
+	}
+	lSpool := &spooledDownload{file: lFile, temporary: true}
+
+	lSize, lErr := io.Copy(lFile, io.MultiReader(&lBuffer, pBody))
+	if lErr != nil {
+		lSpool.Close()
+		return nil, fmt.Errorf("spoolDownload:003 %w", lErr)
+	}
+	lSpool.size = lSize
+	return lSpool, nil
+}
+
+// tempPattern builds an os.CreateTemp pattern such as "readfiles-*-bhav.zip" from a file name.
+func tempPattern(pName string) string {
+	lBase := filepath.Base(pName)
+	if lBase == "." || lBase == string(filepath.Separator) {
+		lBase = ""
+	}
+	lBase = strings.ReplaceAll(lBase, "*", "")
+	if lBase == "" {
+		return "readfiles-*"
+	}
+	return "readfiles-*-" + lBase
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,86 @@
+package readfiles
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSpoolDownload(t *testing.T) {
+	lCases := []struct {
+		name   string
+		body   string
+		hint   int64
+		limit  int64
+		onDisk bool
+	}{
+		{"fits in memory", "0123456789", -1, 10, false},
+		{"one byte over the limit", "0123456789A", -1, 10, true},
+		{"size hint over the limit", "0123", 100, 10, true},
+		{"default limit", "small", 5, 0, false},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			lTemp := t.TempDir()
+			t.Setenv("TMPDIR", lTemp)
+
+			lSpool, lErr := spoolDownload(strings.NewReader(lCase.body), lCase.hint, lCase.limit, "bhav*.zip")
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			if (lSpool.file != nil) != lCase.onDisk {
+				t.Errorf("on disk = %v, want %v", lSpool.file != nil, lCase.onDisk)
+			}
+			if lSpool.Size() != int64(len(lCase.body)) {
+				t.Errorf("size = %d, want %d", lSpool.Size(), len(lCase.body))
+			}
+			lData, lErr := io.ReadAll(io.NewSectionReader(lSpool.ReaderAt(), 0, lSpool.Size()))
+			if lErr != nil || string(lData) != lCase.body {
+				t.Errorf("content = %q, %v", lData, lErr)
+			}
+
+			if lErr := lSpool.Close(); lErr != nil {
+				t.Fatal(lErr)
+			}
+			if lLeft, _ := os.ReadDir(lTemp); len(lLeft) != 0 {
+				t.Errorf("temporary files left behind: %v", lLeft)
+			}
+		})
+	}
+}
+
+func TestTempPattern(t *testing.T) {
+	lCases := map[string]string{
+		"":               "readfiles-*",
+		"bhav.zip":       "readfiles-*-bhav.zip",
+		"dir/bhav*.zip":  "readfiles-*-bhav.zip",
+		"../../etc/x.gz": "readfiles-*-x.gz",
+		"/":              "readfiles-*",
+	}
+	for lName, lWant := range lCases {
+		if lGot := tempPattern(lName); lGot != lWant {
+			t.Errorf("tempPattern(%q) = %q, want %q", lName, lGot, lWant)
+		}
+	}
+}
+
+func TestReadZipWithOptionsTempFile(t *testing.T) {
+	lArchive := zipBytes(t, testFile{"a.csv", strings.Repeat("1,2\n", 1000)})
+	lServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(lArchive)
+	}))
+	defer lServer.Close()
+
+	lTemp := t.TempDir()
+	t.Setenv("TMPDIR", lTemp)
+	lResults, lErr := ReadZipWithOptions(lServer.URL+"/a.zip", "a.zip", ReadOptions{MemoryLimit: 64})
+	if lErr != nil || len(lResults) != 1 || len(lResults[0].Rows) != 1000 {
+		t.Fatalf("results = %v, %v", lResults, lErr)
+	}
+	if lLeft, _ := os.ReadDir(lTemp); len(lLeft) != 0 {
+		t.Errorf("temporary files left behind: %v", lLeft)
+	}
+}
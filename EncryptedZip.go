@@ -0,0 +1,296 @@
+package readfiles
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+//----------------------------------------------------------- Encrypted ZIP ---------------------------------------------------------
+
+// ErrPasswordRequired is recorded for an encrypted entry when no password is configured for it.
+var ErrPasswordRequired = errors.New("entry is encrypted and no password was given")
+
+// ErrWrongPassword is recorded for an encrypted entry when the password does not match.
+// For legacy ZipCrypto the check covers one byte, so about one wrong password in 256 is only
+// caught later, by the CRC-32, and reported as zip.ErrChecksum or a decompression error instead.
+var ErrWrongPassword = errors.New("wrong password for encrypted entry")
+
+const (
+	zipFlagEncrypted = 0x1
+	zipFlagDataDesc  = 0x8
+	zipMethodAES     = 99
+	zipExtraAES      = 0x9901
+	aesAuthCodeSize  = 10
+)
+
+// openZipFile opens an entry for reading, decrypting it first when it is encrypted with ZipCrypto or WinZip AES.
+// The password comes from pOptions.PasswordFor for the entry's path, or else from pOptions.Password.
+// Like openCountedZip, it also returns the count of compressed bytes read so far, or -1 for a stored entry.
+func openZipFile(pFile *zip.File, pPath string, pOptions ReadOptions) (io.ReadCloser, func() int64, error) {
+	if pFile.Flags&zipFlagEncrypted == 0 {
+		return openCountedZip(pFile)
+	}
+
+	lPassword := pOptions.Password
+	if pOptions.PasswordFor != nil {
+		if lEntryPassword, lOk := pOptions.PasswordFor(pPath); lOk {
+			lPassword = lEntryPassword
+		}
+	}
+	if lPassword == "" {
+		return nil, nil, fmt.Errorf("openZipFile:001 %s: %w", pPath, ErrPasswordRequired)
+	}
+
+	lOpened, lErr := pFile.OpenRaw()
+	if lErr != nil {
+		return nil, nil, fmt.Errorf("openZipFile:002 %w", lErr)
+	}
+	lRaw := &countingReader{reader: lOpened}
+
+	lMethod := pFile.Method
+	lCheckCRC := true
+	var lPlain io.Reader
+	if pFile.Method == zipMethodAES {
+		lVersion, lStrength, lActualMethod, lOk := aesExtra(pFile.Extra)
+		if !lOk {
+			return nil, nil, fmt.Errorf("openZipFile:003 %s: %w", pPath, zip.ErrFormat)
+		}
+		lPlain, lErr = newAESReader(lRaw, int64(pFile.CompressedSize64), lStrength, lPassword)
+		if lErr != nil {
+			return nil, nil, fmt.Errorf("openZipFile:004 %s: %w", pPath, lErr)
+		}
+		lMethod = lActualMethod
+		// AE-2 leaves the CRC at zero and relies on the authentication code alone.
+		lCheckCRC = lVersion == 1
+	} else {
+		lCheck := byte(pFile.CRC32 >> 24)
+		if pFile.Flags&zipFlagDataDesc != 0 {
+			lCheck = byte(pFile.ModifiedTime >> 8)
+		}
+		lPlain, lErr = newZipCryptoReader(lRaw, lPassword, lCheck)
+		if lErr != nil {
+			return nil, nil, fmt.Errorf("openZipFile:005 %s: %w", pPath, lErr)
+		}
+	}
+
+	var lData io.ReadCloser
+	switch lMethod {
+	case zip.Store:
+		lData = io.NopCloser(lPlain)
+	case zip.Deflate:
+		lData = &drainReader{ReadCloser: flate.NewReader(lPlain), source: lPlain}
+	default:
+		return nil, nil, fmt.Errorf("openZipFile:006 %s: %w", pPath, zip.ErrAlgorithm)
+	}
+	lCompressed := func() int64 {
+		if lMethod == zip.Store {
+			return -1
+		}
+		return lRaw.count
+	}
+	if !lCheckCRC {
+		return lData, lCompressed, nil
+	}
+	return &crcReader{reader: lData, want: pFile.CRC32, hash: crc32.NewIEEE()}, lCompressed, nil
+}
+
+// aesExtra reads the WinZip AES extra field: vendor version (1 for AE-1, 2 for AE-2),
+// key strength (1, 2 or 3 for AES-128, 192 or 256) and the compression method used before encryption.
+func aesExtra(pExtra []byte) (int, int, uint16, bool) {
+	for len(pExtra) >= 4 {
+		lTag := binary.LittleEndian.Uint16(pExtra)
+		lSize := int(binary.LittleEndian.Uint16(pExtra[2:]))
+		if len(pExtra) < 4+lSize {
+			break
+		}
+		lData := pExtra[4 : 4+lSize]
+		if lTag == zipExtraAES && lSize >= 7 && string(lData[2:4]) == "AE" {
+			lStrength := int(lData[4])
+			if lStrength < 1 || lStrength > 3 {
+				break
+			}
+			return int(binary.LittleEndian.Uint16(lData)), lStrength, binary.LittleEndian.Uint16(lData[5:]), true
+		}
+		pExtra = pExtra[4+lSize:]
+	}
+	return 0, 0, 0, false
+}
+
+//---- ZipCrypto ----
+
+// zipCryptoKeys is the traditional PKWARE stream cipher state.
+type zipCryptoKeys [3]uint32
+
+func newZipCryptoKeys(pPassword string) *zipCryptoKeys {
+	lKeys := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for i := 0; i < len(pPassword); i++ {
+		lKeys.update(pPassword[i])
+	}
+	return lKeys
+}
+
+func (k *zipCryptoKeys) update(pByte byte) {
+	k[0] = crc32Update(k[0], pByte)
+	k[1] = (k[1]+(k[0]&0xff))*134775813 + 1
+	k[2] = crc32Update(k[2], byte(k[1]>>24))
+}
+
+func (k *zipCryptoKeys) decrypt(pByte byte) byte {
+	lTemp := k[2] | 2
+	lPlain := pByte ^ byte((lTemp*(lTemp^1))>>8)
+	k.update(lPlain)
+	return lPlain
+}
+
+func crc32Update(pCrc uint32, pByte byte) uint32 {
+	return crc32.IEEETable[byte(pCrc)^pByte] ^ (pCrc >> 8)
+}
+
+// zipCryptoReader decrypts a ZipCrypto entry.
+type zipCryptoReader struct {
+	reader io.Reader
+	keys   *zipCryptoKeys
+}
+
+// newZipCryptoReader decrypts the 12-byte encryption header and checks its last byte against pCheck.
+func newZipCryptoReader(pReader io.Reader, pPassword string, pCheck byte) (io.Reader, error) {
+	lKeys := newZipCryptoKeys(pPassword)
+	lHeader := make([]byte, 12)
+	_, lErr := io.ReadFull(pReader, lHeader)
+	if lErr != nil {
+		return nil, lErr
+	}
+	for i := range lHeader {
+		lHeader[i] = lKeys.decrypt(lHeader[i])
+	}
+	if lHeader[11] != pCheck {
+		return nil, ErrWrongPassword
+	}
+	return &zipCryptoReader{reader: pReader, keys: lKeys}, nil
+}
+
+func (r *zipCryptoReader) Read(p []byte) (int, error) {
+	lCount, lErr := r.reader.Read(p)
+	for i := 0; i < lCount; i++ {
+		p[i] = r.keys.decrypt(p[i])
+	}
+	return lCount, lErr
+}
+
+//---- WinZip AES ----
+
+// aesReader decrypts a WinZip AES entry: AES in counter mode with a little-endian counter starting at 1,
+// authenticated with HMAC-SHA1 over the encrypted bytes, truncated to 10 bytes and stored after them.
+type aesReader struct {
+	reader  io.Reader
+	raw     io.Reader
+	block   cipher.Block
+	counter [aes.BlockSize]byte
+	stream  [aes.BlockSize]byte
+	used    int
+	mac     hash.Hash
+	done    bool
+}
+
+// newAESReader derives the keys from the password and the salt at the start of the entry,
+// and checks the two-byte password verifier that follows the salt.
+func newAESReader(pRaw io.Reader, pSize int64, pStrength int, pPassword string) (io.Reader, error) {
+	lKeySize := 8 + 8*pStrength
+	lSaltSize := lKeySize / 2
+	lDataSize := pSize - int64(lSaltSize) - 2 - aesAuthCodeSize
+	if lDataSize < 0 {
+		return nil, zip.ErrFormat
+	}
+
+	lHeader := make([]byte, lSaltSize+2)
+	_, lErr := io.ReadFull(pRaw, lHeader)
+	if lErr != nil {
+		return nil, lErr
+	}
+	lKeys, lErr := pbkdf2.Key(sha1.New, pPassword, lHeader[:lSaltSize], 1000, 2*lKeySize+2)
+	if lErr != nil {
+		return nil, lErr
+	}
+	if !bytes.Equal(lKeys[2*lKeySize:], lHeader[lSaltSize:]) {
+		return nil, ErrWrongPassword
+	}
+
+	lBlock, lErr := aes.NewCipher(lKeys[:lKeySize])
+	if lErr != nil {
+		return nil, lErr
+	}
+	return &aesReader{
+		reader: io.LimitReader(pRaw, lDataSize),
+		raw:    pRaw,
+		block:  lBlock,
+		used:   aes.BlockSize,
+		mac:    hmac.New(sha1.New, lKeys[lKeySize:2*lKeySize]),
+	}, nil
+}
+
+func (r *aesReader) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, io.EOF
+	}
+	lCount, lErr := r.reader.Read(p)
+	r.mac.Write(p[:lCount])
+	for i := 0; i < lCount; i++ {
+		if r.used == aes.BlockSize {
+			for j := range r.counter {
+				r.counter[j]++
+				if r.counter[j] != 0 {
+					break
+				}
+			}
+			r.block.Encrypt(r.stream[:], r.counter[:])
+			r.used = 0
+		}
+		p[i] ^= r.stream[r.used]
+		r.used++
+	}
+
+	if lErr == io.EOF {
+		r.done = true
+		lCode := make([]byte, aesAuthCodeSize)
+		_, lReadErr := io.ReadFull(r.raw, lCode)
+		if lReadErr != nil {
+			return lCount, lReadErr
+		}
+		if !hmac.Equal(lCode, r.mac.Sum(nil)[:aesAuthCodeSize]) {
+			return lCount, zip.ErrChecksum
+		}
+	}
+	return lCount, lErr
+}
+
+// drainReader reads its source to the end once the decompressor is done, since flate stops at its final block
+// and the AES authentication code is only checked when the encrypted data has been read in full.
+type drainReader struct {
+	io.ReadCloser
+	source io.Reader
+}
+
+func (r *drainReader) Read(p []byte) (int, error) {
+	lCount, lErr := r.ReadCloser.Read(p)
+	if lErr == io.EOF {
+		_, lDrainErr := io.Copy(io.Discard, r.source)
+		if lDrainErr != nil {
+			return lCount, lDrainErr
+		}
+	}
+	return lCount, lErr
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,158 @@
+package readfiles
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// encryptedFixture opens an archive from testdata/encrypted, made by mkfixtures.sh with the password "secret".
+// pTamper, if set, may change the raw bytes using the entries of the untouched archive.
+func encryptedFixture(t *testing.T, pName string, pTamper func([]byte, *zip.Reader)) *zip.Reader {
+	t.Helper()
+	lData, lErr := os.ReadFile(filepath.Join("testdata", "encrypted", pName))
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lReader, lErr := zip.NewReader(bytes.NewReader(lData), int64(len(lData)))
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	if pTamper == nil {
+		return lReader
+	}
+	pTamper(lData, lReader)
+	lReader, lErr = zip.NewReader(bytes.NewReader(lData), int64(len(lData)))
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	return lReader
+}
+
+// flipByte returns a tamper function that inverts one byte of the first entry, pFromEnd bytes before its end.
+func flipByte(pFromEnd int64) func([]byte, *zip.Reader) {
+	return func(pData []byte, pReader *zip.Reader) {
+		lFile := pReader.File[0]
+		lOffset, _ := lFile.DataOffset()
+		pData[lOffset+int64(lFile.CompressedSize64)-pFromEnd] ^= 0xff
+	}
+}
+
+func TestEncryptedZip(t *testing.T) {
+	lFixtures := []struct {
+		name string
+		aes  bool
+	}{
+		{"zipcrypto.zip", false},            // stored, no data descriptor: CRC check byte
+		{"zipcrypto-dd.zip", false},         // Info-ZIP, data descriptor: time check byte
+		{"zipcrypto-libarchive.zip", false}, // libarchive, data descriptor
+		{"aes128.zip", true},                // AE-1 for trades.csv, AE-2 for t.csv
+		{"aes256.zip", true},                // AE-1 and AE-2, AES-256
+		{"aes256-store.zip", true},          // AES-256 without compression
+	}
+	lWantRows := [][][]string{
+		{{"SYMBOL", "QTY"}, {"INFY", "10"}, {"TCS", "20"}},
+		{{"x"}},
+	}
+
+	for _, lFixture := range lFixtures {
+		t.Run(lFixture.name, func(t *testing.T) {
+			lReader := encryptedFixture(t, lFixture.name, nil)
+			if lFixture.aes && lReader.File[0].Method != zipMethodAES {
+				t.Fatalf("method = %d, want WinZip AES", lReader.File[0].Method)
+			}
+
+			lResults, lErr := ReadZipEntries(lReader, ReadOptions{Password: "secret", StopOnError: true})
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			if len(lResults) != 2 {
+				t.Fatalf("results = %v", lResults)
+			}
+			for lIndex, lResult := range lResults {
+				if !reflect.DeepEqual(lResult.Rows, lWantRows[lIndex]) {
+					t.Errorf("%s rows = %q, want %q", lResult.Name, lResult.Rows, lWantRows[lIndex])
+				}
+			}
+
+			lCases := []struct {
+				name    string
+				options ReadOptions
+				wantErr error
+			}{
+				{"wrong password", ReadOptions{Password: "wrong"}, ErrWrongPassword},
+				{"no password", ReadOptions{}, ErrPasswordRequired},
+				{"per-entry password wins", ReadOptions{Password: "secret", PasswordFor: func(string) (string, bool) { return "wrong", true }}, ErrWrongPassword},
+			}
+			for _, lCase := range lCases {
+				lResults, lErr := ReadZipEntries(lReader, lCase.options)
+				if lErr != nil {
+					t.Fatal(lErr)
+				}
+				for _, lResult := range lResults {
+					if !errors.Is(lResult.Err, lCase.wantErr) {
+						t.Errorf("%s: %s err = %v, want %v", lCase.name, lResult.Name, lResult.Err, lCase.wantErr)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncryptedZipPasswordFor(t *testing.T) {
+	lReader := encryptedFixture(t, "aes256.zip", nil)
+	lOptions := ReadOptions{
+		Password: "wrong",
+		PasswordFor: func(pPath string) (string, bool) {
+			return "secret", pPath == "t.csv"
+		},
+	}
+	lResults, lErr := ReadZipEntries(lReader, lOptions)
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	if !errors.Is(lResults[0].Err, ErrWrongPassword) || lResults[1].Err != nil {
+		t.Fatalf("errors = %v, %v; want only trades.csv to fail", lResults[0].Err, lResults[1].Err)
+	}
+}
+
+func TestEncryptedZipTampered(t *testing.T) {
+	lCases := []struct {
+		name     string
+		fixture  string
+		fromEnd  int64
+		checksum bool
+	}{
+		// Stored data: the CRC catches the change.
+		{"ZipCrypto data byte", "zipcrypto.zip", 1, true},
+		// The HMAC covers the encrypted bytes and is stored in the last 10 bytes of the entry.
+		{"AES-256 stored data byte", "aes256-store.zip", aesAuthCodeSize + 1, true},
+		{"AES-128 authentication code", "aes128.zip", 1, true},
+		{"AES-256 authentication code", "aes256.zip", aesAuthCodeSize, true},
+		// A changed deflate byte may break the stream before the HMAC is reached; it must fail either way.
+		{"AES-256 deflated data byte", "aes256.zip", aesAuthCodeSize + 1, false},
+		{"ZipCrypto deflated data byte", "zipcrypto-libarchive.zip", 1, false},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			lReader := encryptedFixture(t, lCase.fixture, flipByte(lCase.fromEnd))
+			lResults, lErr := ReadZipEntries(lReader, ReadOptions{Password: "secret"})
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			if lResults[0].Err == nil {
+				t.Fatalf("tampered entry read as %q", lResults[0].Rows)
+			}
+			if lCase.checksum && !errors.Is(lResults[0].Err, zip.ErrChecksum) {
+				t.Errorf("err = %v, want zip.ErrChecksum", lResults[0].Err)
+			}
+			if lResults[1].Err != nil {
+				t.Errorf("untouched entry failed: %v", lResults[1].Err)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package readfiles
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+//---------------------------------------------------------- Entry Selection --------------------------------------------------------
+
+// EntryFilter chooses which entries of an archive are read.
+// Patterns are matched against the entry's name inside its own archive, such as "reports/BHAV.CSV".
+// A glob pattern without "/" is also matched against the base name, so "*.csv" finds CSV files in any folder.
+type EntryFilter struct {
+	// Include, if set, lists the patterns of the data files to read; other data files are skipped.
+	// Nested archives are always opened, so that the files inside them can be matched.
+	Include []string
+	// Exclude lists the patterns of entries to skip, archives included. Exclude wins over Include.
+	Exclude []string
+	// Regex treats the patterns as regular expressions instead of path.Match globs.
+	Regex bool
+	// IgnoreCase matches the patterns without regard to case.
+	IgnoreCase bool
+	// KeepHidden reads entries under __MACOSX and entries whose name or folder starts with ".",
+	// which are skipped by default.
+	KeepHidden bool
+}
+
+// EntryParser reads the rows of an entry for a custom extension registered in ReadOptions.Parsers.
+type EntryParser func(pReader io.Reader) ([][]string, error)
+
+// DelimitedParser returns an EntryParser for delimited text, such as '\t' for .tsv files or ';' for European CSV.
+func DelimitedParser(pComma rune) EntryParser {
+	return func(pReader io.Reader) ([][]string, error) {
+		return readDelimitedRows(pReader, pComma)
+	}
+}
+
+// entryPatterns caches compiled regular expressions by pattern, as the same filter is applied to every entry.
+var entryPatterns sync.Map
+
+// selectEntry reports whether the entry pName of format pFormat passes the hidden-file rule and pOptions.Select.
+// An invalid pattern is returned as an error, as it would fail for every entry.
+func selectEntry(pName string, pFormat FileFormat, pOptions ReadOptions) (bool, error) {
+	lFilter := pOptions.Select
+	if !lFilter.KeepHidden && hiddenEntry(pName) {
+		return false, nil
+	}
+
+	for _, lPattern := range lFilter.Exclude {
+		lMatch, lErr := matchEntry(lPattern, pName, lFilter)
+		if lErr != nil {
+			return false, lErr
+		}
+		if lMatch {
+			return false, nil
+		}
+	}
+
+	_, lCustom := pOptions.parser(pName)
+	if len(lFilter.Include) == 0 || (!lCustom && (pFormat == FormatZip || pFormat == FormatTar || pFormat == FormatGzip)) {
+		return true, nil
+	}
+	for _, lPattern := range lFilter.Include {
+		lMatch, lErr := matchEntry(lPattern, pName, lFilter)
+		if lErr != nil {
+			return false, lErr
+		}
+		if lMatch {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchEntry matches one glob or regular expression against an entry name.
+func matchEntry(pPattern string, pName string, pFilter EntryFilter) (bool, error) {
+	if pFilter.Regex {
+		lSource := pPattern
+		if pFilter.IgnoreCase {
+			lSource = "(?i)" + lSource
+		}
+		lCached, lOk := entryPatterns.Load(lSource)
+		if !lOk {
+			lCompiled, lErr := regexp.Compile(lSource)
+			if lErr != nil {
+				return false, fmt.Errorf("matchEntry:001 %w", lErr)
+			}
+			lCached, _ = entryPatterns.LoadOrStore(lSource, lCompiled)
+		}
+		return lCached.(*regexp.Regexp).MatchString(pName), nil
+	}
+
+	lPattern, lName := pPattern, pName
+	if pFilter.IgnoreCase {
+		lPattern, lName = strings.ToLower(lPattern), strings.ToLower(lName)
+	}
+	lMatch, lErr := path.Match(lPattern, lName)
+	if lErr != nil {
+		return false, fmt.Errorf("matchEntry:002 %q: %w", pPattern, lErr)
+	}
+	if !lMatch && !strings.Contains(lPattern, "/") {
+		lMatch, _ = path.Match(lPattern, path.Base(lName))
+	}
+	return lMatch, nil
+}
+
+// hiddenEntry reports whether an entry is macOS metadata (__MACOSX, "._name") or lies in a dotfile or dot folder.
+func hiddenEntry(pName string) bool {
+	for _, lPart := range strings.Split(strings.Trim(pName, "/"), "/") {
+		if lPart == "__MACOSX" || (strings.HasPrefix(lPart, ".") && lPart != "." && lPart != "..") {
+			return true
+		}
+	}
+	return false
+}
+
+// parser returns the custom parser registered for the extension of pName, if any.
+// Keys of ReadOptions.Parsers may be given with or without the leading dot, in any case.
+func (o ReadOptions) parser(pName string) (EntryParser, bool) {
+	if len(o.Parsers) == 0 {
+		return nil, false
+	}
+	lExt := strings.ToLower(path.Ext(pName))
+	if lExt == "" {
+		return nil, false
+	}
+	for lKey, lParser := range o.Parsers {
+		if strings.ToLower("."+strings.TrimPrefix(lKey, ".")) == lExt {
+			return lParser, true
+		}
+	}
+	return nil, false
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,116 @@
+package readfiles
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelectEntry(t *testing.T) {
+	lCases := []struct {
+		name   string
+		format FileFormat
+		filter EntryFilter
+		want   bool
+	}{
+		{"reports/BHAV.csv", FormatCSV, EntryFilter{}, true},
+		{"__MACOSX/._BHAV.csv", FormatCSV, EntryFilter{}, false},
+		{"._BHAV.csv", FormatCSV, EntryFilter{}, false},
+		{".git/config.txt", FormatText, EntryFilter{}, false},
+		{".git/config.txt", FormatText, EntryFilter{KeepHidden: true}, true},
+		{"reports/BHAV.csv", FormatCSV, EntryFilter{Include: []string{"*.csv"}}, true},
+		{"reports/notes.txt", FormatText, EntryFilter{Include: []string{"*.csv"}}, false},
+		{"reports/BHAV.CSV", FormatCSV, EntryFilter{Include: []string{"*.csv"}}, false},
+		{"reports/BHAV.CSV", FormatCSV, EntryFilter{Include: []string{"*.csv"}, IgnoreCase: true}, true},
+		{"reports/BHAV.csv", FormatCSV, EntryFilter{Include: []string{"reports/*.csv"}}, true},
+		{"other/BHAV.csv", FormatCSV, EntryFilter{Include: []string{"reports/*.csv"}}, false},
+		{"inner.zip", FormatZip, EntryFilter{Include: []string{"*.csv"}}, true},
+		{"inner.zip", FormatZip, EntryFilter{Include: []string{"*.csv"}, Exclude: []string{"inner.*"}}, false},
+		{"BHAV.csv", FormatCSV, EntryFilter{Include: []string{"*.csv"}, Exclude: []string{"bhav*"}, IgnoreCase: true}, false},
+		{"fo20240102.csv", FormatCSV, EntryFilter{Include: []string{`^fo\d{8}\.csv$`}, Regex: true}, true},
+		{"cm20240102.csv", FormatCSV, EntryFilter{Include: []string{`^fo\d{8}\.csv$`}, Regex: true}, false},
+		{"FO20240102.CSV", FormatCSV, EntryFilter{Include: []string{`^fo\d{8}\.csv$`}, Regex: true, IgnoreCase: true}, true},
+	}
+	for _, lCase := range lCases {
+		lGot, lErr := selectEntry(lCase.name, lCase.format, ReadOptions{Select: lCase.filter})
+		if lErr != nil || lGot != lCase.want {
+			t.Errorf("selectEntry(%q, %+v) = %v, %v; want %v", lCase.name, lCase.filter, lGot, lErr, lCase.want)
+		}
+	}
+
+	for _, lFilter := range []EntryFilter{{Include: []string{"[a-"}}, {Exclude: []string{"(unclosed"}, Regex: true}} {
+		if _, lErr := selectEntry("a.csv", FormatCSV, ReadOptions{Select: lFilter}); lErr == nil {
+			t.Errorf("invalid pattern %+v was accepted", lFilter)
+		}
+	}
+}
+
+func TestCustomParsers(t *testing.T) {
+	lArchive := zipBytes(t,
+		testFile{"a.tsv", "1\t2\n"},
+		testFile{"b.DAT", "x;y\n"},
+		testFile{"c.csv", "3,4\n"},
+		testFile{"d.zip", "not really a zip"},
+	)
+	lReader, lErr := zip.NewReader(bytes.NewReader(lArchive), int64(len(lArchive)))
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lOptions := ReadOptions{Parsers: map[string]EntryParser{
+		".tsv": DelimitedParser('\t'),
+		"dat":  DelimitedParser(';'),
+		".zip": func(pReader io.Reader) ([][]string, error) {
+			lData, lErr := io.ReadAll(pReader)
+			return [][]string{{strings.ToUpper(string(lData))}}, lErr
+		},
+	}}
+	lResults, lErr := ReadZipEntries(lReader, lOptions)
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+
+	lWant := []struct {
+		format FileFormat
+		rows   [][]string
+	}{
+		{"tsv", [][]string{{"1", "2"}}},
+		{"dat", [][]string{{"x", "y"}}},
+		{FormatCSV, [][]string{{"3", "4"}}},
+		{"zip", [][]string{{"NOT REALLY A ZIP"}}},
+	}
+	if len(lResults) != len(lWant) {
+		t.Fatalf("results = %v", lResults)
+	}
+	for lIndex, lResult := range lResults {
+		if lResult.Err != nil || lResult.Format != lWant[lIndex].format || !reflect.DeepEqual(lResult.Rows, lWant[lIndex].rows) {
+			t.Errorf("%s = %s %q, %v; want %s %q", lResult.Name, lResult.Format, lResult.Rows, lResult.Err, lWant[lIndex].format, lWant[lIndex].rows)
+		}
+	}
+}
+
+func TestReadZipEntriesSelect(t *testing.T) {
+	lArchive := zipBytes(t,
+		testFile{"reports/cm.csv", "1,2\n"},
+		testFile{"reports/fo.csv", "3,4\n"},
+		testFile{"__MACOSX/reports/._cm.csv", "junk"},
+		testFile{"inner.zip", string(zipBytes(t, testFile{"fo_inner.csv", "5,6\n"}, testFile{"cm_inner.csv", "7,8\n"}))},
+	)
+	lReader, lErr := zip.NewReader(bytes.NewReader(lArchive), int64(len(lArchive)))
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lResults, lErr := ReadZipEntries(lReader, ReadOptions{Select: EntryFilter{Include: []string{"fo*.csv"}}})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	var lPaths []string
+	for _, lResult := range lResults {
+		lPaths = append(lPaths, lResult.Path)
+	}
+	if lWant := []string{"reports/fo.csv", "inner.zip/fo_inner.csv"}; !reflect.DeepEqual(lPaths, lWant) {
+		t.Errorf("paths = %q, want %q", lPaths, lWant)
+	}
+}
@@ -0,0 +1,196 @@
+package readfiles
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+//---------------------------------------------------------- Extract Archive --------------------------------------------------------
+
+// ExtractZip writes the files of a ZIP archive under pDir and returns their paths.
+// Entries with an absolute path or a ".." element are rejected with ErrUnsafePath ("zip slip"),
+// symbolic links with ErrUnsupportedEntry, and pOptions.Limits is enforced while the files are written.
+// Hidden entries and pOptions.Select are honoured as for reading.
+
+// Step-by-Step Process:
+// 1. Loop through the entries and count them against MaxEntries.
+// 2. Resolve each entry name to a path that is checked to lie inside pDir.
+// 3. Create folders for directory entries, and write files through the size and ratio limits.
+// 4. On any error, remove the file being written and stop.
+func ExtractZip(pArchive *zip.Reader, pDir string, pOptions ReadOptions) ([]string, error) {
+	log.Println("ExtractZip(+)")
+
+	var lWritten []string
+	pOptions = pOptions.withBudget()
+
+	for _, lFile := range pArchive.File {
+		lTarget, lErr := safeExtractPath(pDir, lFile.Name)
+		if lErr != nil {
+			return lWritten, fmt.Errorf("ExtractZip:001 %w", lErr)
+		}
+		if lFile.FileInfo().IsDir() {
+			lErr = os.MkdirAll(lTarget, 0o755)
+			if lErr != nil {
+				return lWritten, fmt.Errorf("ExtractZip:002 %w", lErr)
+			}
+			continue
+		}
+		if !lFile.Mode().IsRegular() {
+			return lWritten, fmt.Errorf("ExtractZip:003 %s: %w", lFile.Name, ErrUnsupportedEntry)
+		}
+		lErr = pOptions.budget.addEntry(lFile.Name)
+		if lErr != nil {
+			return lWritten, fmt.Errorf("ExtractZip:004 %w", lErr)
+		}
+		lSelected, lErr := selectEntry(lFile.Name, "", pOptions)
+		if lErr != nil {
+			return lWritten, fmt.Errorf("ExtractZip:005 %w", lErr)
+		}
+		if !lSelected {
+			continue
+		}
+
+		lReader, lCompressed, lErr := openZipFile(lFile, lFile.Name, pOptions)
+		if lErr != nil {
+			return lWritten, fmt.Errorf("ExtractZip:006 %s: %w", lFile.Name, lErr)
+		}
+		lErr = writeExtracted(lTarget, limitEntry(lReader, lFile.Name, pOptions, lCompressed))
+		lReader.Close()
+		if lErr != nil {
+			return lWritten, fmt.Errorf("ExtractZip:007 %s: %w", lFile.Name, lErr)
+		}
+		lWritten = append(lWritten, lTarget)
+	}
+
+	log.Println("ExtractZip(-)")
+	return lWritten, nil
+}
+
+// ExtractTar writes the files of a tar, tar.gz or tgz stream under pDir and returns their paths.
+// It applies the same path checks and limits as ExtractZip; links and device files are rejected with ErrUnsupportedEntry.
+func ExtractTar(pReader io.Reader, pDir string, pOptions ReadOptions) ([]string, error) {
+	log.Println("ExtractTar(+)")
+
+	var lWritten []string
+	pOptions = pOptions.withBudget()
+
+	lStream := bufio.NewReader(pReader)
+	lPeek, _ := lStream.Peek(2)
+	var lSource io.Reader = lStream
+	if sniffFormat(lPeek) == FormatGzip {
+		lCompressed := &countingReader{reader: lStream}
+		lInner, lErr := gzip.NewReader(lCompressed)
+		if lErr != nil {
+			return nil, fmt.Errorf("ExtractTar:001 %w", lErr)
+		}
+		defer lInner.Close()
+		lLimited := limitEntry(lInner, "", pOptions, func() int64 { return lCompressed.count })
+		lLimited.container = true
+		lSource = lLimited
+	}
+
+	lArchive := tar.NewReader(lSource)
+	for {
+		lHeader, lErr := lArchive.Next()
+		if lErr == io.EOF {
+			break
+		}
+		if lErr != nil {
+			return lWritten, fmt.Errorf("ExtractTar:002 %w", lErr)
+		}
+		if lHeader.Typeflag == tar.TypeXGlobalHeader {
+			// Archive-wide PAX metadata, such as the commit ID git archive writes, is not a file.
+			continue
+		}
+		lTarget, lErr := safeExtractPath(pDir, lHeader.Name)
+		if lErr != nil {
+			return lWritten, fmt.Errorf("ExtractTar:003 %w", lErr)
+		}
+
+		switch lHeader.Typeflag {
+		case tar.TypeDir:
+			lErr = os.MkdirAll(lTarget, 0o755)
+			if lErr != nil {
+				return lWritten, fmt.Errorf("ExtractTar:004 %w", lErr)
+			}
+			continue
+		case tar.TypeReg:
+		default:
+			return lWritten, fmt.Errorf("ExtractTar:005 %s: %w", lHeader.Name, ErrUnsupportedEntry)
+		}
+
+		lErr = pOptions.budget.addEntry(lHeader.Name)
+		if lErr != nil {
+			return lWritten, fmt.Errorf("ExtractTar:006 %w", lErr)
+		}
+		lSelected, lErr := selectEntry(lHeader.Name, "", pOptions)
+		if lErr != nil {
+			return lWritten, fmt.Errorf("ExtractTar:007 %w", lErr)
+		}
+		if !lSelected {
+			continue
+		}
+
+		lErr = writeExtracted(lTarget, limitEntry(lArchive, lHeader.Name, pOptions, nil))
+		if lErr != nil {
+			return lWritten, fmt.Errorf("ExtractTar:008 %s: %w", lHeader.Name, lErr)
+		}
+		lWritten = append(lWritten, lTarget)
+	}
+
+	log.Println("ExtractTar(-)")
+	return lWritten, nil
+}
+
+// safeExtractPath resolves an entry name under pDir. Absolute names, drive letters and names that climb
+// out of pDir with ".." are rejected with ErrUnsafePath, as are backslash-separated variants of the same.
+func safeExtractPath(pDir string, pName string) (string, error) {
+	lName := strings.ReplaceAll(pName, `\`, "/")
+	if lName == "" || strings.HasPrefix(lName, "/") || (len(lName) >= 2 && lName[1] == ':') {
+		return "", fmt.Errorf("%w: %q", ErrUnsafePath, pName)
+	}
+	for _, lPart := range strings.Split(lName, "/") {
+		if lPart == ".." {
+			return "", fmt.Errorf("%w: %q", ErrUnsafePath, pName)
+		}
+	}
+
+	lTarget := filepath.Join(pDir, filepath.FromSlash(path.Clean(lName)))
+	lRelative, lErr := filepath.Rel(pDir, lTarget)
+	if lErr != nil || lRelative == ".." || strings.HasPrefix(lRelative, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q", ErrUnsafePath, pName)
+	}
+	return lTarget, nil
+}
+
+// writeExtracted copies pReader to pTarget, creating its folder, and removes the file if the copy fails.
+func writeExtracted(pTarget string, pReader io.Reader) error {
+	lErr := os.MkdirAll(filepath.Dir(pTarget), 0o755)
+	if lErr != nil {
+		return lErr
+	}
+	lFile, lErr := os.OpenFile(pTarget, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if lErr != nil {
+		return lErr
+	}
+	_, lErr = io.Copy(lFile, pReader)
+	lCloseErr := lFile.Close()
+	if lErr == nil {
+		lErr = lCloseErr
+	}
+	if lErr != nil {
+		os.Remove(pTarget)
+	}
+	return lErr
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
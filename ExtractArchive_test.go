@@ -0,0 +1,262 @@
+package readfiles
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSafeExtractPath(t *testing.T) {
+	lDir := t.TempDir()
+	lCases := []struct {
+		name string
+		want string
+	}{
+		{"a.csv", "a.csv"},
+		{"reports/2024/a.csv", "reports/2024/a.csv"},
+		{"reports/./a.csv", "reports/a.csv"},
+		{`reports\a.csv`, "reports/a.csv"},
+		{"../evil.csv", ""},
+		{"reports/../../evil.csv", ""},
+		{`..\evil.csv`, ""},
+		{"/etc/passwd", ""},
+		{`C:\Windows\evil.dll`, ""},
+		{"c:evil.dll", ""},
+		{"", ""},
+	}
+	for _, lCase := range lCases {
+		lTarget, lErr := safeExtractPath(lDir, lCase.name)
+		if lCase.want == "" {
+			if !errors.Is(lErr, ErrUnsafePath) {
+				t.Errorf("safeExtractPath(%q) = %q, %v; want ErrUnsafePath", lCase.name, lTarget, lErr)
+			}
+			continue
+		}
+		if lErr != nil || lTarget != filepath.Join(lDir, filepath.FromSlash(lCase.want)) {
+			t.Errorf("safeExtractPath(%q) = %q, %v; want %q", lCase.name, lTarget, lErr, lCase.want)
+		}
+	}
+}
+
+// zipWithHeaders builds a ZIP archive from headers, so that tests can set modes and hostile names.
+func zipWithHeaders(t *testing.T, pHeaders []*zip.FileHeader, pBodies []string) *zip.Reader {
+	t.Helper()
+	var lBuffer bytes.Buffer
+	lWriter := zip.NewWriter(&lBuffer)
+	for lIndex, lHeader := range pHeaders {
+		lEntry, lErr := lWriter.CreateHeader(lHeader)
+		if lErr != nil {
+			t.Fatal(lErr)
+		}
+		lEntry.Write([]byte(pBodies[lIndex]))
+	}
+	if lErr := lWriter.Close(); lErr != nil {
+		t.Fatal(lErr)
+	}
+	lReader, lErr := zip.NewReader(bytes.NewReader(lBuffer.Bytes()), int64(lBuffer.Len()))
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	return lReader
+}
+
+func TestExtractZip(t *testing.T) {
+	lLink := &zip.FileHeader{Name: "link"}
+	lLink.SetMode(os.ModeSymlink | 0o777)
+
+	lCases := []struct {
+		name      string
+		headers   []*zip.FileHeader
+		bodies    []string
+		options   ReadOptions
+		wantFiles []string
+		wantErr   error
+	}{
+		{
+			name:      "files and folders",
+			headers:   []*zip.FileHeader{{Name: "reports/"}, {Name: "reports/a.csv", Method: zip.Deflate}, {Name: "b.txt"}, {Name: "__MACOSX/._b.txt"}},
+			bodies:    []string{"", "1,2\n", "x", "junk"},
+			wantFiles: []string{"reports/a.csv", "b.txt"},
+		},
+		{
+			name:      "selected entries only",
+			headers:   []*zip.FileHeader{{Name: "a.csv"}, {Name: "b.txt"}},
+			bodies:    []string{"1", "2"},
+			options:   ReadOptions{Select: EntryFilter{Include: []string{"*.csv"}}},
+			wantFiles: []string{"a.csv"},
+		},
+		{
+			name:      "zip slip",
+			headers:   []*zip.FileHeader{{Name: "ok.csv"}, {Name: "../evil.csv"}},
+			bodies:    []string{"1", "2"},
+			wantFiles: []string{"ok.csv"},
+			wantErr:   ErrUnsafePath,
+		},
+		{
+			name:    "absolute path",
+			headers: []*zip.FileHeader{{Name: "/tmp/evil.csv"}},
+			bodies:  []string{"1"},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name:    "symbolic link",
+			headers: []*zip.FileHeader{lLink},
+			bodies:  []string{"/etc/passwd"},
+			wantErr: ErrUnsupportedEntry,
+		},
+		{
+			name:    "entry too large",
+			headers: []*zip.FileHeader{{Name: "big.csv", Method: zip.Deflate}},
+			bodies:  []string{strings.Repeat("1,2\n", 100)},
+			options: ReadOptions{Limits: ArchiveLimits{MaxEntryBytes: 100}},
+			wantErr: ErrEntryTooLarge,
+		},
+		{
+			name:      "too many entries",
+			headers:   []*zip.FileHeader{{Name: "a.csv"}, {Name: "b.csv"}},
+			bodies:    []string{"1", "2"},
+			options:   ReadOptions{Limits: ArchiveLimits{MaxEntries: 1}},
+			wantFiles: []string{"a.csv"},
+			wantErr:   ErrTooManyEntries,
+		},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			// Files written before the failing entry are kept and returned.
+			lDir := t.TempDir()
+			lWritten, lErr := ExtractZip(zipWithHeaders(t, lCase.headers, lCase.bodies), lDir, lCase.options)
+			checkExtracted(t, lDir, lWritten, lErr, lCase.wantFiles, lCase.wantErr)
+		})
+	}
+}
+
+func TestExtractTar(t *testing.T) {
+	lCases := []struct {
+		name      string
+		headers   []tar.Header
+		bodies    []string
+		gzip      bool
+		options   ReadOptions
+		wantFiles []string
+		wantErr   error
+	}{
+		{
+			name:      "tar",
+			headers:   []tar.Header{{Name: "dir/", Typeflag: tar.TypeDir}, {Name: "dir/a.csv", Typeflag: tar.TypeReg}},
+			bodies:    []string{"", "1,2\n"},
+			wantFiles: []string{"dir/a.csv"},
+		},
+		{
+			name:      "tar.gz",
+			headers:   []tar.Header{{Name: "a.csv", Typeflag: tar.TypeReg}, {Name: "b.txt", Typeflag: tar.TypeReg}},
+			bodies:    []string{"1,2\n", "x"},
+			gzip:      true,
+			wantFiles: []string{"a.csv", "b.txt"},
+		},
+		{
+			// git archive starts every tarball with a pax_global_header entry; it is skipped and not counted.
+			name:      "PAX global header",
+			headers:   []tar.Header{{Name: "pax_global_header", Typeflag: tar.TypeXGlobalHeader, PAXRecords: map[string]string{"comment": "0123abcd"}}, {Name: "a.csv", Typeflag: tar.TypeReg}},
+			bodies:    []string{"", "1,2\n"},
+			options:   ReadOptions{Limits: ArchiveLimits{MaxEntries: 1}},
+			wantFiles: []string{"a.csv"},
+		},
+		{
+			name:    "tar slip",
+			headers: []tar.Header{{Name: "../../evil.sh", Typeflag: tar.TypeReg}},
+			bodies:  []string{"rm -rf"},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name:    "symbolic link",
+			headers: []tar.Header{{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}},
+			bodies:  []string{""},
+			wantErr: ErrUnsupportedEntry,
+		},
+		{
+			name:    "hard link",
+			headers: []tar.Header{{Name: "link", Typeflag: tar.TypeLink, Linkname: "a.csv"}},
+			bodies:  []string{""},
+			wantErr: ErrUnsupportedEntry,
+		},
+		{
+			name:      "total too large",
+			headers:   []tar.Header{{Name: "a.csv", Typeflag: tar.TypeReg}, {Name: "b.csv", Typeflag: tar.TypeReg}},
+			bodies:    []string{strings.Repeat("x", 60), strings.Repeat("y", 60)},
+			gzip:      true,
+			options:   ReadOptions{Limits: ArchiveLimits{MaxTotalBytes: 100}},
+			wantFiles: []string{"a.csv"},
+			wantErr:   ErrArchiveTooLarge,
+		},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			var lBuffer bytes.Buffer
+			lWriter := tar.NewWriter(&lBuffer)
+			for lIndex, lHeader := range lCase.headers {
+				if lHeader.Typeflag == tar.TypeReg {
+					lHeader.Size = int64(len(lCase.bodies[lIndex]))
+				}
+				if lHeader.Typeflag != tar.TypeXGlobalHeader {
+					lHeader.Mode = 0o644
+				}
+				if lErr := lWriter.WriteHeader(&lHeader); lErr != nil {
+					t.Fatal(lErr)
+				}
+				lWriter.Write([]byte(lCase.bodies[lIndex]))
+			}
+			lWriter.Close()
+			lData := lBuffer.Bytes()
+			if lCase.gzip {
+				lData = gzipBytes(t, "x.tar", lData)
+			}
+
+			lDir := t.TempDir()
+			lWritten, lErr := ExtractTar(bytes.NewReader(lData), lDir, lCase.options)
+			checkExtracted(t, lDir, lWritten, lErr, lCase.wantFiles, lCase.wantErr)
+		})
+	}
+}
+
+// checkExtracted compares the result of an extraction with the expected files or error,
+// and checks that nothing was written outside pDir.
+func checkExtracted(t *testing.T, pDir string, pWritten []string, pErr error, pWantFiles []string, pWantErr error) {
+	t.Helper()
+	if pWantErr != nil {
+		if !errors.Is(pErr, pWantErr) {
+			t.Fatalf("err = %v, want %v", pErr, pWantErr)
+		}
+	} else if pErr != nil {
+		t.Fatal(pErr)
+	}
+	if len(pWritten) != len(pWantFiles) {
+		t.Fatalf("written = %q, want %q", pWritten, pWantFiles)
+	}
+	for lIndex, lWant := range pWantFiles {
+		if pWritten[lIndex] != filepath.Join(pDir, filepath.FromSlash(lWant)) {
+			t.Errorf("written[%d] = %q, want %q", lIndex, pWritten[lIndex], lWant)
+		}
+		if _, lErr := os.Stat(pWritten[lIndex]); lErr != nil {
+			t.Error(lErr)
+		}
+	}
+	// A file that failed half way is removed, so only the returned files are on disk.
+	lOnDisk := 0
+	filepath.WalkDir(pDir, func(pPath string, pEntry os.DirEntry, pErr error) error {
+		if pErr == nil && !pEntry.IsDir() {
+			lOnDisk++
+		}
+		return nil
+	})
+	if lOnDisk != len(pWantFiles) {
+		t.Errorf("%d files on disk, want %d", lOnDisk, len(pWantFiles))
+	}
+	if lMatches, _ := filepath.Glob(filepath.Join(filepath.Dir(pDir), "evil*")); len(lMatches) != 0 {
+		t.Errorf("files escaped the destination: %q", lMatches)
+	}
+}
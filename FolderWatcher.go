@@ -0,0 +1,349 @@
+package readfiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+//----------------------------------------------------------- Folder Watcher --------------------------------------------------------
+
+// WatchOptions configures a FolderWatcher.
+type WatchOptions struct {
+	// Dir is the folder that files are dropped into.
+	Dir string
+	// ProcessedDir and FailedDir receive the files after processing. They default to Dir/processed and Dir/failed,
+	// and may be on another file system than Dir.
+	ProcessedDir string
+	FailedDir    string
+	// StateFile records the progress of each file so that a restart neither loses nor repeats work.
+	// It defaults to Dir/.readfiles-state.json.
+	StateFile string
+	// PollInterval is how often the folder is scanned. Zero means 2 seconds.
+	PollInterval time.Duration
+	// StableFor is how long a file's size and modification time must stay unchanged before it is read,
+	// so that files still being copied are left alone. Zero means 2 seconds.
+	StableFor time.Duration
+	// Notify uses file system events (inotify on Linux) to scan as soon as something changes, besides polling.
+	Notify bool
+	// Filter chooses the files that are picked up; hidden files are skipped unless Filter.KeepHidden is set.
+	// Files ending in ".tmp" are taken to be partial copies and are always skipped.
+	Filter EntryFilter
+	// Read is passed to Read for each file.
+	Read ReadOptions
+	// OnResult receives the results of each file. A returned error moves the file to FailedDir.
+	// When OnResult is nil, a file moves to FailedDir only if Read returns an error.
+	OnResult func(pPath string, pResults []EntryResult, pErr error) error
+}
+
+// FolderWatcher picks up files dropped into a folder, reads them and moves them aside.
+//
+// The state file makes processing restart-safe: a file is marked "processing" before it is read and
+// "processed" or "failed" before it is moved. After a restart, a file whose outcome is known is only moved,
+// without calling OnResult again, and a file caught mid-processing is read again (at-least-once delivery).
+type FolderWatcher struct {
+	options WatchOptions
+
+	mu      sync.Mutex
+	state   map[string]watchedFile
+	pending map[string]pendingFile
+}
+
+// watchedFile is the state file record of one file.
+type watchedFile struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Status  string    `json:"status"`
+	Updated time.Time `json:"updated"`
+}
+
+// pendingFile tracks a file that has not been stable for long enough yet.
+type pendingFile struct {
+	size    int64
+	modTime time.Time
+	since   time.Time
+}
+
+const (
+	watchProcessing = "processing"
+	watchProcessed  = "processed"
+	watchFailed     = "failed"
+)
+
+// NewFolderWatcher creates the output folders and loads the state left by an earlier run.
+func NewFolderWatcher(pOptions WatchOptions) (*FolderWatcher, error) {
+	if pOptions.ProcessedDir == "" {
+		pOptions.ProcessedDir = filepath.Join(pOptions.Dir, "processed")
+	}
+	if pOptions.FailedDir == "" {
+		pOptions.FailedDir = filepath.Join(pOptions.Dir, "failed")
+	}
+	if pOptions.StateFile == "" {
+		pOptions.StateFile = filepath.Join(pOptions.Dir, ".readfiles-state.json")
+	}
+	if pOptions.PollInterval <= 0 {
+		pOptions.PollInterval = 2 * time.Second
+	}
+	if pOptions.StableFor <= 0 {
+		pOptions.StableFor = 2 * time.Second
+	}
+
+	for _, lDir := range []string{pOptions.ProcessedDir, pOptions.FailedDir} {
+		lErr := os.MkdirAll(lDir, 0o755)
+		if lErr != nil {
+			return nil, fmt.Errorf("NewFolderWatcher:001 %w", lErr)
+		}
+	}
+
+	lWatcher := &FolderWatcher{options: pOptions, state: make(map[string]watchedFile), pending: make(map[string]pendingFile)}
+	lData, lErr := os.ReadFile(pOptions.StateFile)
+	if lErr == nil {
+		lErr = json.Unmarshal(lData, &lWatcher.state)
+		if lErr != nil {
+			return nil, fmt.Errorf("NewFolderWatcher:002 %s: %w", pOptions.StateFile, lErr)
+		}
+	} else if !os.IsNotExist(lErr) {
+		return nil, fmt.Errorf("NewFolderWatcher:003 %w", lErr)
+	}
+	return lWatcher, nil
+}
+
+// Run watches the folder until pStop is closed.
+
+// Step-by-Step Process:
+// 1. Optionally subscribe to file system events for the folder.
+// 2. On every poll tick or event, scan the folder for stable files.
+// 3. Process each stable file: read, hand to OnResult, record the outcome, move it to processed/ or failed/.
+func (w *FolderWatcher) Run(pStop <-chan struct{}) error {
+	log.Println("FolderWatcher.Run(+)")
+
+	var lEvents <-chan fsnotify.Event
+	var lErrors <-chan error
+	if w.options.Notify {
+		lNotify, lErr := fsnotify.NewWatcher()
+		if lErr != nil {
+			return fmt.Errorf("FolderWatcher:001 %w", lErr)
+		}
+		defer lNotify.Close()
+		lErr = lNotify.Add(w.options.Dir)
+		if lErr != nil {
+			return fmt.Errorf("FolderWatcher:002 %w", lErr)
+		}
+		lEvents = lNotify.Events
+		lErrors = lNotify.Errors
+	}
+
+	lTicker := time.NewTicker(w.options.PollInterval)
+	defer lTicker.Stop()
+
+	for {
+		lErr := w.Scan()
+		if lErr != nil {
+			log.Println("FolderWatcher:", lErr)
+		}
+		select {
+		case <-pStop:
+			log.Println("FolderWatcher.Run(-)")
+			return nil
+		case <-lTicker.C:
+		case <-lEvents:
+		case lErr := <-lErrors:
+			// An overflow or a watch error loses events, but polling still finds the files.
+			log.Println("FolderWatcher:", lErr)
+		}
+	}
+}
+
+// Scan looks at the folder once and processes the files that have been stable for StableFor.
+// Run calls it on every tick; it can also be called directly, for example from a scheduler.
+// A file that cannot be processed is logged and left for a later scan, and the other files go on;
+// only a folder that cannot be listed or an invalid Filter is returned as an error.
+func (w *FolderWatcher) Scan() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	lEntries, lErr := os.ReadDir(w.options.Dir)
+	if lErr != nil {
+		return fmt.Errorf("FolderWatcher:003 %w", lErr)
+	}
+
+	lNow := time.Now()
+	lSeen := make(map[string]bool)
+	var lReady []string
+	for _, lEntry := range lEntries {
+		lName := lEntry.Name()
+		if !lEntry.Type().IsRegular() || filepath.Join(w.options.Dir, lName) == w.options.StateFile || strings.HasSuffix(lName, ".tmp") {
+			continue
+		}
+		lKeep, lErr := selectEntry(lName, "", ReadOptions{Select: w.options.Filter})
+		if lErr != nil {
+			return fmt.Errorf("FolderWatcher:004 %w", lErr)
+		}
+		if !lKeep {
+			continue
+		}
+		lInfo, lErr := lEntry.Info()
+		if lErr != nil {
+			continue
+		}
+		lSeen[lName] = true
+
+		lPending, lOk := w.pending[lName]
+		if !lOk || lPending.size != lInfo.Size() || !lPending.modTime.Equal(lInfo.ModTime()) {
+			w.pending[lName] = pendingFile{size: lInfo.Size(), modTime: lInfo.ModTime(), since: lNow}
+			continue
+		}
+		if lNow.Sub(lPending.since) >= w.options.StableFor {
+			lReady = append(lReady, lName)
+		}
+	}
+	for lName := range w.pending {
+		if !lSeen[lName] {
+			delete(w.pending, lName)
+		}
+	}
+
+	sort.Strings(lReady)
+	for _, lName := range lReady {
+		lPending := w.pending[lName]
+		delete(w.pending, lName)
+		lErr = w.process(lName, lPending)
+		if lErr != nil {
+			log.Println("FolderWatcher:", lName, lErr)
+		}
+	}
+	return nil
+}
+
+// process reads one stable file and moves it to the processed or failed folder, recording each step in the state file.
+func (w *FolderWatcher) process(pName string, pFile pendingFile) error {
+	lPath := filepath.Join(w.options.Dir, pName)
+
+	// A file whose outcome was recorded before a restart only needs to be moved.
+	lRecord, lKnown := w.state[pName]
+	lSameFile := lKnown && lRecord.Size == pFile.size && lRecord.ModTime.Equal(pFile.modTime)
+	lStatus := lRecord.Status
+	if !lSameFile || lStatus == watchProcessing {
+		lErr := w.record(pName, pFile, watchProcessing)
+		if lErr != nil {
+			return lErr
+		}
+
+		lResults, lReadErr := Read(FromFile(lPath), w.options.Read)
+		lStatus = watchProcessed
+		if w.options.OnResult != nil {
+			if w.options.OnResult(lPath, lResults, lReadErr) != nil {
+				lStatus = watchFailed
+			}
+		} else if lReadErr != nil {
+			lStatus = watchFailed
+		}
+
+		lErr = w.record(pName, pFile, lStatus)
+		if lErr != nil {
+			return lErr
+		}
+	}
+
+	lTarget := w.options.ProcessedDir
+	if lStatus == watchFailed {
+		lTarget = w.options.FailedDir
+	}
+	lErr := moveFile(lPath, uniquePath(filepath.Join(lTarget, pName)))
+	if lErr != nil {
+		return fmt.Errorf("FolderWatcher:005 %w", lErr)
+	}
+	log.Println("FolderWatcher:", pName, lStatus)
+
+	delete(w.state, pName)
+	return w.saveState()
+}
+
+// record stores the status of a file in the state file.
+func (w *FolderWatcher) record(pName string, pFile pendingFile, pStatus string) error {
+	w.state[pName] = watchedFile{Size: pFile.size, ModTime: pFile.modTime, Status: pStatus, Updated: time.Now()}
+	return w.saveState()
+}
+
+// saveState writes the state file atomically.
+func (w *FolderWatcher) saveState() error {
+	lData, lErr := json.MarshalIndent(w.state, "", "  ")
+	if lErr != nil {
+		return fmt.Errorf("FolderWatcher:006 %w", lErr)
+	}
+	lTemp := w.options.StateFile + ".tmp"
+	lErr = os.WriteFile(lTemp, lData, 0o644)
+	if lErr == nil {
+		lErr = os.Rename(lTemp, w.options.StateFile)
+	}
+	if lErr != nil {
+		return fmt.Errorf("FolderWatcher:007 %w", lErr)
+	}
+	return nil
+}
+
+// moveFile renames pFrom to pTo. When the rename fails with a link error, as it does with EXDEV when pTo is on
+// another file system, the file is copied and the original removed instead.
+func moveFile(pFrom string, pTo string) error {
+	lErr := os.Rename(pFrom, pTo)
+	var lLinkErr *os.LinkError
+	if lErr == nil || !errors.As(lErr, &lLinkErr) {
+		return lErr
+	}
+	if _, lStatErr := os.Stat(pFrom); lStatErr != nil {
+		return lErr
+	}
+	return copyAndRemove(pFrom, pTo)
+}
+
+// copyAndRemove copies pFrom to pTo with its permissions and removes pFrom. A partial copy is removed.
+func copyAndRemove(pFrom string, pTo string) error {
+	lSource, lErr := os.Open(pFrom)
+	if lErr != nil {
+		return lErr
+	}
+	defer lSource.Close()
+	lInfo, lErr := lSource.Stat()
+	if lErr != nil {
+		return lErr
+	}
+	lTarget, lErr := os.OpenFile(pTo, os.O_CREATE|os.O_EXCL|os.O_WRONLY, lInfo.Mode().Perm())
+	if lErr != nil {
+		return lErr
+	}
+	_, lErr = io.Copy(lTarget, lSource)
+	if lErr == nil {
+		lErr = lTarget.Sync()
+	}
+	lCloseErr := lTarget.Close()
+	if lErr == nil {
+		lErr = lCloseErr
+	}
+	if lErr != nil {
+		os.Remove(pTo)
+		return lErr
+	}
+	lSource.Close()
+	return os.Remove(pFrom)
+}
+
+// uniquePath adds a timestamp to pPath when a file of that name already exists, so that nothing is overwritten.
+func uniquePath(pPath string) string {
+	if _, lErr := os.Stat(pPath); os.IsNotExist(lErr) {
+		return pPath
+	}
+	lExt := filepath.Ext(pPath)
+	return strings.TrimSuffix(pPath, lExt) + "-" + time.Now().Format("20060102-150405.000000000") + lExt
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,258 @@
+package readfiles
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// folderFiles returns the sorted names of the files in pDir, leaving out folders.
+func folderFiles(t *testing.T, pDir string) []string {
+	t.Helper()
+	lEntries, lErr := os.ReadDir(pDir)
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	var lNames []string
+	for _, lEntry := range lEntries {
+		if !lEntry.IsDir() {
+			lNames = append(lNames, lEntry.Name())
+		}
+	}
+	sort.Strings(lNames)
+	return lNames
+}
+
+// scanTwice scans once to see the files and again to process the ones that stayed unchanged.
+func scanTwice(t *testing.T, pWatcher *FolderWatcher) {
+	t.Helper()
+	for i := 0; i < 2; i++ {
+		if lErr := pWatcher.Scan(); lErr != nil {
+			t.Fatal(lErr)
+		}
+	}
+}
+
+func TestFolderWatcherScan(t *testing.T) {
+	lDir := t.TempDir()
+	lFiles := map[string]string{
+		"a.csv":      "1,2\n",
+		"b.zip":      string(zipBytes(t, testFile{"b.csv", "3,4\n"})),
+		"reject.csv": "5,6\n",
+		"junk.bin":   "not an archive",
+		"copy.tmp":   "partial",
+		".hidden":    "x",
+		"notes.txt":  "skipped by the filter",
+	}
+	for lName, lBody := range lFiles {
+		if lErr := os.WriteFile(filepath.Join(lDir, lName), []byte(lBody), 0o644); lErr != nil {
+			t.Fatal(lErr)
+		}
+	}
+
+	lSeen := make(map[string][]EntryResult)
+	lWatcher, lErr := NewFolderWatcher(WatchOptions{
+		Dir:       lDir,
+		StableFor: time.Nanosecond,
+		Filter:    EntryFilter{Exclude: []string{"*.txt"}},
+		OnResult: func(pPath string, pResults []EntryResult, pErr error) error {
+			lSeen[filepath.Base(pPath)] = pResults
+			if filepath.Base(pPath) == "reject.csv" {
+				return errors.New("rejected")
+			}
+			return pErr
+		},
+	})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+
+	// The first scan only notes the files.
+	if lErr := lWatcher.Scan(); lErr != nil {
+		t.Fatal(lErr)
+	}
+	if len(lSeen) != 0 {
+		t.Fatalf("files read on the first scan: %v", lSeen)
+	}
+	scanTwice(t, lWatcher)
+
+	if lGot, lWant := folderFiles(t, filepath.Join(lDir, "processed")), []string{"a.csv", "b.zip"}; !reflect.DeepEqual(lGot, lWant) {
+		t.Errorf("processed = %q, want %q", lGot, lWant)
+	}
+	if lGot, lWant := folderFiles(t, filepath.Join(lDir, "failed")), []string{"junk.bin", "reject.csv"}; !reflect.DeepEqual(lGot, lWant) {
+		t.Errorf("failed = %q, want %q", lGot, lWant)
+	}
+	if lGot, lWant := folderFiles(t, lDir), []string{".hidden", ".readfiles-state.json", "copy.tmp", "notes.txt"}; !reflect.DeepEqual(lGot, lWant) {
+		t.Errorf("left in the folder = %q, want %q", lGot, lWant)
+	}
+	if lResults := lSeen["b.zip"]; len(lResults) != 1 || !reflect.DeepEqual(lResults[0].Rows, [][]string{{"3", "4"}}) {
+		t.Errorf("b.zip results = %+v", lResults)
+	}
+}
+
+func TestFolderWatcherUnstableFile(t *testing.T) {
+	lDir := t.TempDir()
+	lPath := filepath.Join(lDir, "a.csv")
+	os.WriteFile(lPath, []byte("1,2\n"), 0o644)
+
+	lWatcher, lErr := NewFolderWatcher(WatchOptions{Dir: lDir, StableFor: time.Nanosecond})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lWatcher.Scan()
+	// The copy is still going on: the size changes between the scans.
+	os.WriteFile(lPath, []byte("1,2\n3,4\n"), 0o644)
+	lWatcher.Scan()
+	if _, lErr := os.Stat(lPath); lErr != nil {
+		t.Fatal("a growing file was processed")
+	}
+	lWatcher.Scan()
+	if _, lErr := os.Stat(filepath.Join(lDir, "processed", "a.csv")); lErr != nil {
+		t.Fatal("the file was not processed once it was stable")
+	}
+}
+
+func TestFolderWatcherContinuesAfterFailure(t *testing.T) {
+	lDir := t.TempDir()
+	for _, lName := range []string{"a.csv", "b.csv"} {
+		os.WriteFile(filepath.Join(lDir, lName), []byte("1,2\n"), 0o644)
+	}
+	lWatcher, lErr := NewFolderWatcher(WatchOptions{
+		Dir:       lDir,
+		StableFor: time.Nanosecond,
+		OnResult: func(pPath string, pResults []EntryResult, pErr error) error {
+			// Removing the file makes the move of a.csv fail.
+			if filepath.Base(pPath) == "a.csv" {
+				os.Remove(pPath)
+			}
+			return pErr
+		},
+	})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	scanTwice(t, lWatcher)
+	if lGot := folderFiles(t, filepath.Join(lDir, "processed")); !reflect.DeepEqual(lGot, []string{"b.csv"}) {
+		t.Errorf("processed = %q, want b.csv despite the failure of a.csv", lGot)
+	}
+}
+
+func TestFolderWatcherMoveFile(t *testing.T) {
+	lDir := t.TempDir()
+	lFrom := filepath.Join(lDir, "a.csv")
+	os.WriteFile(lFrom, []byte("1,2\n"), 0o640)
+
+	// copyAndRemove is what moveFile falls back to across file systems.
+	lTo := filepath.Join(lDir, "done", "a.csv")
+	os.Mkdir(filepath.Dir(lTo), 0o755)
+	if lErr := copyAndRemove(lFrom, lTo); lErr != nil {
+		t.Fatal(lErr)
+	}
+	if _, lErr := os.Stat(lFrom); !os.IsNotExist(lErr) {
+		t.Errorf("source still there: %v", lErr)
+	}
+	if lData, lErr := os.ReadFile(lTo); lErr != nil || string(lData) != "1,2\n" {
+		t.Errorf("copy = %q, %v", lData, lErr)
+	}
+	if lInfo, _ := os.Stat(lTo); lInfo.Mode().Perm() != 0o640 {
+		t.Errorf("mode = %v, want 0640", lInfo.Mode().Perm())
+	}
+	// An existing target is never overwritten, and the source is kept.
+	os.WriteFile(lFrom, []byte("3,4\n"), 0o644)
+	if lErr := copyAndRemove(lFrom, lTo); lErr == nil {
+		t.Error("an existing target was overwritten")
+	}
+	if _, lErr := os.Stat(lFrom); lErr != nil {
+		t.Errorf("source removed after a failed copy: %v", lErr)
+	}
+
+	// A real cross-device move needs a second file system; /dev/shm is a tmpfs on most Linux systems.
+	lOther, lErr := os.MkdirTemp("/dev/shm", "watch")
+	if lErr != nil {
+		t.Skip("no second file system:", lErr)
+	}
+	defer os.RemoveAll(lOther)
+	lMoved := filepath.Join(lOther, "a.csv")
+	if lErr := moveFile(lFrom, lMoved); lErr != nil {
+		t.Fatal(lErr)
+	}
+	if lData, lErr := os.ReadFile(lMoved); lErr != nil || string(lData) != "3,4\n" {
+		t.Errorf("moved = %q, %v", lData, lErr)
+	}
+	if _, lErr := os.Stat(lFrom); !os.IsNotExist(lErr) {
+		t.Errorf("source still there: %v", lErr)
+	}
+}
+
+func TestFolderWatcherRestart(t *testing.T) {
+	lDir := t.TempDir()
+	lState := make(map[string]watchedFile)
+	for lName, lStatus := range map[string]string{"done.csv": watchProcessed, "bad.csv": watchFailed, "midway.csv": watchProcessing} {
+		lPath := filepath.Join(lDir, lName)
+		os.WriteFile(lPath, []byte("1,2\n"), 0o644)
+		lInfo, _ := os.Stat(lPath)
+		lState[lName] = watchedFile{Size: lInfo.Size(), ModTime: lInfo.ModTime(), Status: lStatus}
+	}
+	lData, _ := json.Marshal(lState)
+	os.WriteFile(filepath.Join(lDir, ".readfiles-state.json"), lData, 0o644)
+
+	var lCalls []string
+	lWatcher, lErr := NewFolderWatcher(WatchOptions{
+		Dir:       lDir,
+		StableFor: time.Nanosecond,
+		OnResult: func(pPath string, pResults []EntryResult, pErr error) error {
+			lCalls = append(lCalls, filepath.Base(pPath))
+			return pErr
+		},
+	})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	scanTwice(t, lWatcher)
+
+	// Files with a known outcome are only moved; the one caught mid-processing is read again.
+	if !reflect.DeepEqual(lCalls, []string{"midway.csv"}) {
+		t.Errorf("OnResult called for %q, want only midway.csv", lCalls)
+	}
+	if lGot := folderFiles(t, filepath.Join(lDir, "processed")); !reflect.DeepEqual(lGot, []string{"done.csv", "midway.csv"}) {
+		t.Errorf("processed = %q", lGot)
+	}
+	if lGot := folderFiles(t, filepath.Join(lDir, "failed")); !reflect.DeepEqual(lGot, []string{"bad.csv"}) {
+		t.Errorf("failed = %q", lGot)
+	}
+	lData, _ = os.ReadFile(filepath.Join(lDir, ".readfiles-state.json"))
+	if string(lData) != "{}" {
+		t.Errorf("state file = %s, want it empty", lData)
+	}
+}
+
+func TestFolderWatcherRun(t *testing.T) {
+	lDir := t.TempDir()
+	lWatcher, lErr := NewFolderWatcher(WatchOptions{Dir: lDir, PollInterval: 10 * time.Millisecond, StableFor: 20 * time.Millisecond, Notify: true})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lStop := make(chan struct{})
+	lDone := make(chan error)
+	go func() { lDone <- lWatcher.Run(lStop) }()
+
+	os.WriteFile(filepath.Join(lDir, "a.csv"), []byte("1,2\n"), 0o644)
+	lProcessed := filepath.Join(lDir, "processed", "a.csv")
+	for lDeadline := time.Now().Add(5 * time.Second); time.Now().Before(lDeadline); time.Sleep(10 * time.Millisecond) {
+		if _, lErr := os.Stat(lProcessed); lErr == nil {
+			break
+		}
+	}
+	close(lStop)
+	if lErr := <-lDone; lErr != nil {
+		t.Fatal(lErr)
+	}
+	if _, lErr := os.Stat(lProcessed); lErr != nil {
+		t.Fatal("Run did not process the dropped file")
+	}
+}
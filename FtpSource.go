@@ -0,0 +1,201 @@
+package readfiles
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+//------------------------------------------------------------ FTP Source -----------------------------------------------------------
+
+// FTPOptions configures an FTP or FTPS connection.
+type FTPOptions struct {
+	// Address is "host:port"; the port defaults to 21.
+	Address string
+	// User and Password log in; an empty User logs in as "anonymous".
+	User     string
+	Password string
+	// TLS, if set, secures the connection with AUTH TLS (explicit FTPS), or from the first byte when ImplicitTLS is set.
+	// ImplicitTLS without TLS is an error.
+	TLS         *tls.Config
+	ImplicitTLS bool
+	// Timeout bounds connecting and each command. Zero means 30 seconds.
+	Timeout time.Duration
+	// DisableEPSV uses PASV for data connections, for servers and firewalls that do not handle EPSV.
+	DisableEPSV bool
+	// Dial, if set, opens the control and data connections instead of a plain TCP dial, for example through a proxy.
+	// It returns the raw connection; TLS is added on top as configured. The control connection must be TCP,
+	// as the server's IP is taken from its remote address.
+	Dial func(pCtx context.Context, pAddress string) (net.Conn, error)
+}
+
+// ErrImplicitTLSConfig is returned when FTPOptions.ImplicitTLS is set without a TLS config.
+var ErrImplicitTLSConfig = errors.New("ftp: ImplicitTLS needs a TLS config")
+
+// RemoteFile is a file found on an FTP or SFTP server.
+type RemoteFile struct {
+	Path     string
+	Name     string
+	Size     int64
+	Modified time.Time
+}
+
+// ListFTP returns the files of the folder pDir that pass pFilter, using the same Include/Exclude rules
+// as archive entries. Folders and links are left out.
+func ListFTP(pOptions FTPOptions, pDir string, pFilter EntryFilter) ([]RemoteFile, error) {
+	lConn, lErr := dialFTP(pOptions)
+	if lErr != nil {
+		return nil, fmt.Errorf("ListFTP:001 %w", lErr)
+	}
+	defer lConn.Quit()
+
+	lEntries, lErr := lConn.List(pDir)
+	if lErr != nil {
+		return nil, fmt.Errorf("ListFTP:002 %w", lErr)
+	}
+
+	var lFiles []RemoteFile
+	for _, lEntry := range lEntries {
+		if lEntry.Type != ftp.EntryTypeFile {
+			continue
+		}
+		lFile := RemoteFile{Path: path.Join(pDir, lEntry.Name), Name: lEntry.Name, Size: int64(lEntry.Size), Modified: lEntry.Time}
+		lKeep, lErr := keepRemoteFile(lFile, pFilter)
+		if lErr != nil {
+			return nil, fmt.Errorf("ListFTP:003 %w", lErr)
+		}
+		if lKeep {
+			lFiles = append(lFiles, lFile)
+		}
+	}
+	return lFiles, nil
+}
+
+// FromFTP is the file at pPath on an FTP or FTPS server. It is downloaded when Read opens it.
+func FromFTP(pOptions FTPOptions, pPath string) Source {
+	return ftpSource{options: pOptions, path: pPath}
+}
+
+type ftpSource struct {
+	options FTPOptions
+	path    string
+}
+
+func (s ftpSource) Name() string {
+	return path.Base(s.path)
+}
+
+func (s ftpSource) Open(pOptions ReadOptions) (io.ReadCloser, int64, error) {
+	lConn, lErr := dialFTP(s.options)
+	if lErr != nil {
+		return nil, 0, lErr
+	}
+	lSize, lErr := lConn.FileSize(s.path)
+	if lErr != nil {
+		// SIZE is optional; the download works without it.
+		lSize = -1
+	}
+	lResponse, lErr := lConn.Retr(s.path)
+	if lErr != nil {
+		lConn.Quit()
+		return nil, 0, lErr
+	}
+	lBody := progressBody(lResponse, "ftp://"+s.options.Address+"/"+s.path, 0, lSize, pOptions)
+	return &ftpReader{Reader: lBody, response: lResponse, conn: lConn}, lSize, nil
+}
+
+// ftpReader closes the data connection and logs out when the download is closed.
+type ftpReader struct {
+	io.Reader
+	response *ftp.Response
+	conn     *ftp.ServerConn
+}
+
+func (r *ftpReader) Close() error {
+	lErr := r.response.Close()
+	r.conn.Quit()
+	return lErr
+}
+
+// dialFTP connects and logs in.
+func dialFTP(pOptions FTPOptions) (*ftp.ServerConn, error) {
+	if pOptions.ImplicitTLS && pOptions.TLS == nil {
+		// Without a config the connection would silently be plain FTP.
+		return nil, fmt.Errorf("dialFTP:001 %w", ErrImplicitTLSConfig)
+	}
+	lAddress := pOptions.Address
+	if _, _, lErr := net.SplitHostPort(lAddress); lErr != nil {
+		lAddress = net.JoinHostPort(lAddress, "21")
+	}
+	lTimeout := pOptions.Timeout
+	if lTimeout <= 0 {
+		lTimeout = 30 * time.Second
+	}
+
+	lDialOptions := []ftp.DialOption{ftp.DialWithTimeout(lTimeout), ftp.DialWithDisabledEPSV(pOptions.DisableEPSV)}
+	if pOptions.TLS != nil {
+		if pOptions.ImplicitTLS {
+			lDialOptions = append(lDialOptions, ftp.DialWithTLS(pOptions.TLS))
+		} else {
+			lDialOptions = append(lDialOptions, ftp.DialWithExplicitTLS(pOptions.TLS))
+		}
+	}
+	if pOptions.Dial != nil {
+		lDialOptions = append(lDialOptions, ftp.DialWithDialFunc(ftpDialFunc(pOptions, lTimeout)))
+	}
+	lConn, lErr := ftp.Dial(lAddress, lDialOptions...)
+	if lErr != nil {
+		return nil, fmt.Errorf("dialFTP:002 %w", lErr)
+	}
+
+	lUser, lPassword := pOptions.User, pOptions.Password
+	if lUser == "" {
+		lUser, lPassword = "anonymous", "anonymous"
+	}
+	lErr = lConn.Login(lUser, lPassword)
+	if lErr != nil {
+		lConn.Quit()
+		return nil, fmt.Errorf("dialFTP:003 %w", lErr)
+	}
+	return lConn, nil
+}
+
+// ftpDialFunc adapts FTPOptions.Dial to the FTP library. With a custom dial function the library only
+// upgrades the control connection after AUTH TLS, so implicit FTPS and the data connections are wrapped here.
+// The library dials the control connection first and the data connections after it, one at a time.
+func ftpDialFunc(pOptions FTPOptions, pTimeout time.Duration) func(string, string) (net.Conn, error) {
+	lControlDialed := false
+	return func(pNetwork string, pAddress string) (net.Conn, error) {
+		lCtx, lCancel := context.WithTimeout(context.Background(), pTimeout)
+		defer lCancel()
+		lConn, lErr := pOptions.Dial(lCtx, pAddress)
+		if lErr != nil {
+			return nil, lErr
+		}
+
+		lControl := !lControlDialed
+		lControlDialed = true
+		if _, lOk := lConn.RemoteAddr().(*net.TCPAddr); lControl && !lOk {
+			lConn.Close()
+			return nil, fmt.Errorf("ftpDialFunc:001 connection to %s is not TCP", pAddress)
+		}
+		if pOptions.TLS != nil && (pOptions.ImplicitTLS || !lControl) {
+			return tls.Client(lConn, pOptions.TLS), nil
+		}
+		return lConn, nil
+	}
+}
+
+// keepRemoteFile applies an EntryFilter to a listed file name.
+func keepRemoteFile(pFile RemoteFile, pFilter EntryFilter) (bool, error) {
+	return selectEntry(pFile.Name, "", ReadOptions{Select: pFilter})
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
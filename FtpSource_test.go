@@ -0,0 +1,321 @@
+package readfiles
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ftpTestServer is a small FTP server for tests: it logs in "ops" with "secret", lists and serves files,
+// and supports EPSV, PASV, explicit FTPS (AUTH TLS) and implicit FTPS.
+type ftpTestServer struct {
+	listener net.Listener
+	files    map[string]string
+	tls      *tls.Config
+	implicit bool
+}
+
+// startFTPServer serves pFiles, keyed by absolute path, until the test ends.
+// pTLS enables FTPS, from the first byte when pImplicit is set.
+func startFTPServer(t *testing.T, pFiles map[string]string, pTLS *tls.Config, pImplicit bool) *ftpTestServer {
+	t.Helper()
+	lListener, lErr := net.Listen("tcp", "127.0.0.1:0")
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lServer := &ftpTestServer{listener: lListener, files: pFiles, tls: pTLS, implicit: pImplicit}
+	t.Cleanup(func() { lListener.Close() })
+	go func() {
+		for {
+			lConn, lErr := lListener.Accept()
+			if lErr != nil {
+				return
+			}
+			go lServer.serve(lConn)
+		}
+	}()
+	return lServer
+}
+
+func (s *ftpTestServer) serve(pConn net.Conn) {
+	lConn := pConn
+	if s.implicit {
+		lConn = tls.Server(pConn, s.tls)
+	}
+	defer lConn.Close()
+	lReader := bufio.NewReader(lConn)
+	lReply := func(pFormat string, pArgs ...any) {
+		fmt.Fprintf(lConn, pFormat+"\r\n", pArgs...)
+	}
+
+	lReply("220 ready")
+	var lPassive net.Listener
+	lProtected := false
+	for {
+		lLine, lErr := lReader.ReadString('\n')
+		if lErr != nil {
+			return
+		}
+		lCommand, lArg, _ := strings.Cut(strings.TrimRight(lLine, "\r\n"), " ")
+		switch strings.ToUpper(lCommand) {
+		case "USER":
+			lReply("331 password please")
+		case "PASS":
+			if lArg != "secret" {
+				lReply("530 login incorrect")
+				continue
+			}
+			lReply("230 logged in")
+		case "FEAT":
+			lReply("211-Features:\r\n SIZE\r\n211 End")
+		case "TYPE", "PBSZ":
+			lReply("200 ok")
+		case "PROT":
+			lProtected = lArg == "P"
+			lReply("200 ok")
+		case "AUTH":
+			if s.tls == nil || s.implicit {
+				lReply("502 not available")
+				continue
+			}
+			lReply("234 start TLS")
+			lConn = tls.Server(pConn, s.tls)
+			lReader = bufio.NewReader(lConn)
+		case "EPSV", "PASV":
+			lPassive, lErr = net.Listen("tcp", "127.0.0.1:0")
+			if lErr != nil {
+				lReply("425 %v", lErr)
+				continue
+			}
+			lPort := lPassive.Addr().(*net.TCPAddr).Port
+			if strings.ToUpper(lCommand) == "EPSV" {
+				lReply("229 Entering Extended Passive Mode (|||%d|)", lPort)
+			} else {
+				lReply("227 Entering Passive Mode (127,0,0,1,%d,%d)", lPort/256, lPort%256)
+			}
+		case "SIZE":
+			lBody, lOk := s.files[lArg]
+			if !lOk {
+				lReply("550 not found")
+				continue
+			}
+			lReply("213 %d", len(lBody))
+		case "LIST", "RETR":
+			lBody, lOk := s.listOrFile(strings.ToUpper(lCommand), lArg)
+			if !lOk || lPassive == nil {
+				lReply("550 not found")
+				continue
+			}
+			lData, lErr := lPassive.Accept()
+			lPassive.Close()
+			lPassive = nil
+			if lErr != nil {
+				lReply("425 %v", lErr)
+				continue
+			}
+			lReply("150 sending")
+			if lProtected {
+				lData = tls.Server(lData, s.tls)
+			}
+			io.WriteString(lData, lBody)
+			lData.Close()
+			lReply("226 done")
+		case "QUIT":
+			lReply("221 bye")
+			return
+		default:
+			lReply("502 %s not implemented", lCommand)
+		}
+	}
+}
+
+// listOrFile returns the body of a RETR, or a Unix-style listing of a folder for LIST.
+func (s *ftpTestServer) listOrFile(pCommand string, pArg string) (string, bool) {
+	if pCommand == "RETR" {
+		lBody, lOk := s.files[pArg]
+		return lBody, lOk
+	}
+	var lPaths, lLines []string
+	for lPath := range s.files {
+		lPaths = append(lPaths, lPath)
+	}
+	sort.Strings(lPaths)
+	lFolders := make(map[string]bool)
+	for _, lPath := range lPaths {
+		lBody := s.files[lPath]
+		lDir, lName := path.Split(lPath)
+		switch {
+		case path.Clean(lDir) == path.Clean(pArg):
+			lLines = append(lLines, fmt.Sprintf("-rw-r--r-- 1 ops ops %d Jan 02 15:04 %s", len(lBody), lName))
+		case strings.HasPrefix(lDir, path.Clean(pArg)+"/"):
+			lSub := strings.SplitN(strings.TrimPrefix(lDir, path.Clean(pArg)+"/"), "/", 2)[0]
+			if !lFolders[lSub] {
+				lFolders[lSub] = true
+				lLines = append(lLines, "drwxr-xr-x 2 ops ops 4096 Jan 02 15:04 "+lSub)
+			}
+		}
+	}
+	return strings.Join(lLines, "\r\n") + "\r\n", true
+}
+
+// testTLSConfigs returns a server config with a self-signed certificate for ftp.example.com
+// and a client config that trusts it.
+func testTLSConfigs(t *testing.T) (*tls.Config, *tls.Config) {
+	t.Helper()
+	lKey, lErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		DNSNames:              []string{"ftp.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	lDER, lErr := x509.CreateCertificate(rand.Reader, lTemplate, lTemplate, &lKey.PublicKey, lKey)
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lCert, lErr := x509.ParseCertificate(lDER)
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lPool := x509.NewCertPool()
+	lPool.AddCert(lCert)
+	lServer := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{lDER}, PrivateKey: lKey}}}
+	lClient := &tls.Config{RootCAs: lPool, ServerName: "ftp.example.com"}
+	return lServer, lClient
+}
+
+var ftpTestFiles = map[string]string{
+	"/pub/cm01JAN2024bhav.csv.zip": "",
+	"/pub/fo01JAN2024bhav.csv.zip": "",
+	"/pub/readme.txt":              "hello\n",
+	"/pub/old/cm01JAN2023.zip":     "",
+}
+
+func TestFTPSource(t *testing.T) {
+	lFiles := make(map[string]string)
+	for lPath, lBody := range ftpTestFiles {
+		lFiles[lPath] = lBody
+	}
+	lFiles["/pub/cm01JAN2024bhav.csv.zip"] = string(zipBytes(t, testFile{"cm01JAN2024bhav.csv", "SYMBOL,CLOSE\nINFY,1450\n"}))
+	lFiles["/pub/fo01JAN2024bhav.csv.zip"] = string(zipBytes(t, testFile{"fo01JAN2024bhav.csv", "SYMBOL,OI\nINFY,9\n"}))
+
+	lServerTLS, lClientTLS := testTLSConfigs(t)
+	lPlain := startFTPServer(t, lFiles, nil, false)
+	lExplicit := startFTPServer(t, lFiles, lServerTLS, false)
+	lImplicit := startFTPServer(t, lFiles, lServerTLS, true)
+
+	// lViaHook sends the control connection for ftp.example.com to pServer and data connections where the server says.
+	lViaHook := func(pServer *ftpTestServer, pDialed *int) func(context.Context, string) (net.Conn, error) {
+		return func(pCtx context.Context, pAddress string) (net.Conn, error) {
+			*pDialed++
+			if pAddress == "ftp.example.com:21" {
+				pAddress = pServer.listener.Addr().String()
+			}
+			var lDialer net.Dialer
+			return lDialer.DialContext(pCtx, "tcp", pAddress)
+		}
+	}
+
+	lCases := []struct {
+		name    string
+		options func(*int) FTPOptions
+		hook    bool
+	}{
+		{name: "plain", options: func(*int) FTPOptions {
+			return FTPOptions{Address: lPlain.listener.Addr().String(), User: "ops", Password: "secret"}
+		}},
+		{name: "plain with PASV", options: func(*int) FTPOptions {
+			return FTPOptions{Address: lPlain.listener.Addr().String(), User: "ops", Password: "secret", DisableEPSV: true}
+		}},
+		{name: "dial hook", hook: true, options: func(pDialed *int) FTPOptions {
+			return FTPOptions{Address: "ftp.example.com", User: "ops", Password: "secret", Dial: lViaHook(lPlain, pDialed)}
+		}},
+		{name: "explicit FTPS through the dial hook", hook: true, options: func(pDialed *int) FTPOptions {
+			return FTPOptions{Address: "ftp.example.com", User: "ops", Password: "secret", TLS: lClientTLS, Dial: lViaHook(lExplicit, pDialed)}
+		}},
+		{name: "implicit FTPS through the dial hook", hook: true, options: func(pDialed *int) FTPOptions {
+			return FTPOptions{Address: "ftp.example.com", User: "ops", Password: "secret", TLS: lClientTLS, ImplicitTLS: true, Dial: lViaHook(lImplicit, pDialed)}
+		}},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			lDialed := 0
+			lOptions := lCase.options(&lDialed)
+
+			lListed, lErr := ListFTP(lOptions, "/pub", EntryFilter{Include: []string{"*bhav.csv.zip"}})
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			var lPaths []string
+			for _, lFile := range lListed {
+				lPaths = append(lPaths, lFile.Path)
+			}
+			if lWant := []string{"/pub/cm01JAN2024bhav.csv.zip", "/pub/fo01JAN2024bhav.csv.zip"}; !reflect.DeepEqual(lPaths, lWant) {
+				t.Fatalf("listed %q, want %q", lPaths, lWant)
+			}
+			if lListed[0].Size != int64(len(lFiles[lPaths[0]])) {
+				t.Errorf("size = %d, want %d", lListed[0].Size, len(lFiles[lPaths[0]]))
+			}
+
+			lResults, lErr := Read(FromFTP(lOptions, lListed[0].Path), ReadOptions{})
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			if lWant := [][]string{{"SYMBOL", "CLOSE"}, {"INFY", "1450"}}; len(lResults) != 1 || !reflect.DeepEqual(lResults[0].Rows, lWant) {
+				t.Fatalf("results = %+v, want %q", lResults, lWant)
+			}
+			// Each session dials a control and a data connection.
+			if lCase.hook && lDialed != 4 {
+				t.Errorf("dial hook called %d times, want 4", lDialed)
+			}
+		})
+	}
+}
+
+func TestFTPSourceErrors(t *testing.T) {
+	lServer := startFTPServer(t, ftpTestFiles, nil, false)
+	lAddress := lServer.listener.Addr().String()
+
+	_, lErr := ListFTP(FTPOptions{Address: lAddress, ImplicitTLS: true}, "/pub", EntryFilter{})
+	if !errors.Is(lErr, ErrImplicitTLSConfig) {
+		t.Errorf("ImplicitTLS without TLS: err = %v, want ErrImplicitTLSConfig", lErr)
+	}
+	if _, lErr := ListFTP(FTPOptions{Address: lAddress, User: "ops", Password: "wrong"}, "/pub", EntryFilter{}); lErr == nil {
+		t.Error("a wrong password logged in")
+	}
+	if _, lErr := Read(FromFTP(FTPOptions{Address: lAddress, User: "ops", Password: "secret"}, "/pub/missing.zip"), ReadOptions{}); lErr == nil {
+		t.Error("a missing file was read")
+	}
+
+	// A non-TCP connection is refused instead of tripping up the FTP library.
+	lPipe := func(context.Context, string) (net.Conn, error) {
+		lClient, lServer := net.Pipe()
+		lServer.Close()
+		return lClient, nil
+	}
+	if _, lErr := ListFTP(FTPOptions{Address: "ftp.example.com", Dial: lPipe}, "/pub", EntryFilter{}); lErr == nil {
+		t.Error("a pipe was accepted as the control connection")
+	}
+}
@@ -0,0 +1,420 @@
+package readfiles
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"mime"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+//----------------------------------------------------------- HTTP Fetch ------------------------------------------------------------
+
+// DefaultUserAgent is sent with every download unless HTTPOptions sets another one.
+var DefaultUserAgent = "Mozilla/5.0 (compatible; readfiles)"
+
+// HTTPOptions configures the HTTP client used for downloads.
+type HTTPOptions struct {
+	// Client is used for the requests instead of a new client. It is copied, never modified.
+	Client *http.Client
+	// Timeout limits each request, including reading the body. Zero keeps the client's timeout.
+	Timeout time.Duration
+	// Proxy is a proxy URL such as "http://proxy:3128". Empty uses the HTTP_PROXY/HTTPS_PROXY environment.
+	// It is ignored when Client is set.
+	Proxy string
+	// UserAgent replaces DefaultUserAgent.
+	UserAgent string
+	// Headers are added to every request and override the default ones.
+	// Setting Accept-Encoding here turns off Go's transparent gzip handling; the body is then
+	// decoded here according to Content-Encoding (gzip, deflate or br).
+	Headers http.Header
+	// CookieJar keeps cookies between requests of one download. Jar, if set, is used instead of a new jar.
+	CookieJar bool
+	Jar       http.CookieJar
+	// PrimeURL is fetched before the download so that the server can set its session cookies,
+	// as NSE does on its home page. It implies CookieJar.
+	PrimeURL string
+}
+
+// RetryPolicy configures how failed downloads are retried.
+// Network errors, 429 and 5xx responses are retried; other failures are returned at once.
+// The zero value makes a single attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first. Values below 1 mean 1.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry; it doubles on every further retry. Zero means 500ms.
+	BaseDelay time.Duration
+	// MaxDelay caps the wait between attempts, including waits asked for by Retry-After. Zero means 30s.
+	MaxDelay time.Duration
+}
+
+// DownloadError is returned when a download fails for good, after any retries.
+// StatusCode is zero when no HTTP response was received; Err then holds the network error.
+// Header holds the headers of the last response, when there was one.
+type DownloadError struct {
+	URL         string
+	StatusCode  int
+	ContentType string
+	Header      http.Header
+	Attempts    int
+	Err         error
+}
+
+func (e *DownloadError) Error() string {
+	lMessage := fmt.Sprintf("download of %s failed after %d attempt(s)", e.URL, e.Attempts)
+	if e.StatusCode != 0 {
+		lMessage += fmt.Sprintf(": HTTP %d", e.StatusCode)
+	}
+	if e.Err != nil {
+		lMessage += ": " + e.Err.Error()
+	}
+	return lMessage
+}
+
+func (e *DownloadError) Unwrap() error {
+	return e.Err
+}
+
+// ErrUnexpectedContentType is wrapped by a DownloadError when the server answers with an HTML page
+// or a Content-Type outside ReadOptions.AcceptContentTypes.
+var ErrUnexpectedContentType = errors.New("unexpected content type")
+
+// fetchURL sends a GET request for pUrl and returns the successful response, retrying as pOptions.Retry allows.
+// pHeaders are added to the request; when they make it conditional, a 304 Not Modified response is also returned.
+// The caller must close the response body.
+
+// Step-by-Step Process:
+// 1. Build the HTTP client and fetch the prime URL if any (see fetchWithClient for the rest).
+// 2. Build and send the request.
+// 3. On a network error, or a 429/5xx status, wait (Retry-After or jittered exponential backoff) and try again.
+// 4. On any other non-2xx status, stop with a DownloadError.
+// 5. On a 2xx status, check the Content-Type, decode a manually requested Content-Encoding and return the response.
+// 6. When the attempts run out, return a DownloadError describing the last failure.
+func fetchURL(pUrl string, pHeaders http.Header, pOptions ReadOptions) (*http.Response, error) {
+	lClient, lErr := newSessionClient(pOptions.HTTP)
+	if lErr != nil {
+		return nil, &DownloadError{URL: pUrl, Attempts: 1, Err: lErr}
+	}
+	return fetchWithClient(lClient, pUrl, pHeaders, pOptions)
+}
+
+// fetchWithClient is fetchURL with a client prepared by newSessionClient, so that several requests can share
+// one session.
+func fetchWithClient(pClient *http.Client, pUrl string, pHeaders http.Header, pOptions ReadOptions) (*http.Response, error) {
+	lPolicy := normalizedRetry(pOptions.Retry)
+
+	lFailure := &DownloadError{URL: pUrl}
+	for lAttempt := 1; lAttempt <= lPolicy.MaxAttempts; lAttempt++ {
+		lFailure.Attempts = lAttempt
+
+		lRequest, lErr := newDownloadRequest(pUrl, pOptions.HTTP)
+		if lErr != nil {
+			lFailure.Err = lErr
+			return nil, lFailure
+		}
+		for lName, lValues := range pHeaders {
+			lRequest.Header[lName] = lValues
+		}
+
+		lWait := time.Duration(0)
+		lResponse, lErr := pClient.Do(lRequest)
+		if lErr != nil {
+			lFailure.StatusCode, lFailure.Header, lFailure.Err = 0, nil, lErr
+		} else if lResponse.StatusCode == http.StatusNotModified {
+			return lResponse, nil
+		} else if lResponse.StatusCode >= 200 && lResponse.StatusCode <= 299 {
+			lErr = checkContentType(lResponse, pOptions.AcceptContentTypes)
+			if lErr == nil {
+				lErr = decodeBody(lResponse)
+			}
+			if lErr != nil {
+				lResponse.Body.Close()
+				lFailure.StatusCode, lFailure.ContentType, lFailure.Header, lFailure.Err = lResponse.StatusCode, lResponse.Header.Get("Content-Type"), lResponse.Header, lErr
+				return nil, lFailure
+			}
+			return lResponse, nil
+		} else {
+			lFailure.StatusCode, lFailure.ContentType, lFailure.Header = lResponse.StatusCode, lResponse.Header.Get("Content-Type"), lResponse.Header
+			lFailure.Err = nil
+			lWait = retryAfter(lResponse.Header.Get("Retry-After"))
+			// Drain a little of the body so the connection can be reused.
+			io.CopyN(io.Discard, lResponse.Body, 4<<10)
+			lResponse.Body.Close()
+			if !retryableStatus(lResponse.StatusCode) {
+				return nil, lFailure
+			}
+		}
+
+		if lAttempt == lPolicy.MaxAttempts {
+			break
+		}
+		if lWait <= 0 {
+			lWait = backoffDelay(lPolicy, lAttempt)
+		}
+		if lWait > lPolicy.MaxDelay {
+			lWait = lPolicy.MaxDelay
+		}
+		log.Println("fetchURL: attempt", lAttempt, "failed:", lFailure, "- retrying in", lWait)
+		time.Sleep(lWait)
+	}
+	return nil, lFailure
+}
+
+// newSessionClient returns the client described by pOptions, with its session primed when PrimeURL is set.
+func newSessionClient(pOptions HTTPOptions) (*http.Client, error) {
+	lClient, lErr := newHTTPClient(pOptions)
+	if lErr != nil {
+		return nil, lErr
+	}
+	if pOptions.PrimeURL != "" {
+		lErr = primeSession(lClient, pOptions)
+		if lErr != nil {
+			return nil, lErr
+		}
+	}
+	return lClient, nil
+}
+
+// newHTTPClient returns the client described by pOptions.
+func newHTTPClient(pOptions HTTPOptions) (*http.Client, error) {
+	var lClient http.Client
+	if pOptions.Client != nil {
+		lClient = *pOptions.Client
+	} else {
+		lTransport, lErr := proxyTransport(pOptions.Proxy)
+		if lErr != nil {
+			return nil, fmt.Errorf("newHTTPClient:001 %w", lErr)
+		}
+		lClient.Transport = lTransport
+	}
+
+	if pOptions.Timeout > 0 {
+		lClient.Timeout = pOptions.Timeout
+	}
+
+	if pOptions.Jar != nil {
+		lClient.Jar = pOptions.Jar
+	} else if (pOptions.CookieJar || pOptions.PrimeURL != "") && lClient.Jar == nil {
+		lJar, lErr := cookiejar.New(nil)
+		if lErr != nil {
+			return nil, fmt.Errorf("newHTTPClient:002 %w", lErr)
+		}
+		lClient.Jar = lJar
+	}
+	return &lClient, nil
+}
+
+// proxyTransports holds one Transport per proxy URL, shared by every download through that proxy so
+// that keep-alive connections are reused instead of piling up in a Transport per fetch.
+var (
+	proxyTransportsMu sync.Mutex
+	proxyTransports   = make(map[string]*http.Transport)
+)
+
+// proxyTransport returns the shared Transport for pProxy; an empty pProxy means http.DefaultTransport.
+func proxyTransport(pProxy string) (http.RoundTripper, error) {
+	if pProxy == "" {
+		return http.DefaultTransport, nil
+	}
+	proxyTransportsMu.Lock()
+	defer proxyTransportsMu.Unlock()
+	if lTransport, lFound := proxyTransports[pProxy]; lFound {
+		return lTransport, nil
+	}
+
+	lProxy, lErr := url.Parse(pProxy)
+	if lErr != nil {
+		return nil, fmt.Errorf("proxyTransport:001 %w", lErr)
+	}
+	lTransport := http.DefaultTransport.(*http.Transport).Clone()
+	lTransport.Proxy = http.ProxyURL(lProxy)
+	proxyTransports[pProxy] = lTransport
+	return lTransport, nil
+}
+
+// primeSession fetches pOptions.PrimeURL with pClient so that its cookie jar picks up the session cookies.
+func primeSession(pClient *http.Client, pOptions HTTPOptions) error {
+	lRequest, lErr := newDownloadRequest(pOptions.PrimeURL, pOptions)
+	if lErr != nil {
+		return fmt.Errorf("primeSession:001 %w", lErr)
+	}
+	lResponse, lErr := pClient.Do(lRequest)
+	if lErr != nil {
+		return fmt.Errorf("primeSession:002 %w", lErr)
+	}
+	defer lResponse.Body.Close()
+	io.Copy(io.Discard, lResponse.Body)
+
+	if lResponse.StatusCode >= 400 {
+		return fmt.Errorf("primeSession:003 %s returned HTTP %d", pOptions.PrimeURL, lResponse.StatusCode)
+	}
+	return nil
+}
+
+// newDownloadRequest prepares the GET request used for downloads, with the default and configured headers.
+// Accept-Encoding is left to the transport unless the caller sets it, so gzip bodies are decoded transparently.
+func newDownloadRequest(pUrl string, pOptions HTTPOptions) (*http.Request, error) {
+	lRequest, lErr := http.NewRequest(http.MethodGet, pUrl, nil)
+	if lErr != nil {
+		return nil, lErr
+	}
+
+	lUserAgent := pOptions.UserAgent
+	if lUserAgent == "" {
+		lUserAgent = DefaultUserAgent
+	}
+	lRequest.Header.Set("User-Agent", lUserAgent)
+	lRequest.Header.Set("Accept", "*/*")
+	for lName, lValues := range pOptions.Headers {
+		lRequest.Header.Del(lName)
+		for _, lValue := range lValues {
+			lRequest.Header.Add(lName, lValue)
+		}
+	}
+	return lRequest, nil
+}
+
+// decodeBody replaces the response body with its decoded form when the server applied a Content-Encoding
+// that the transport did not already remove, which happens when Accept-Encoding was set by hand.
+func decodeBody(pResponse *http.Response) error {
+	lEncoding := strings.ToLower(strings.TrimSpace(pResponse.Header.Get("Content-Encoding")))
+	if lEncoding == "" || lEncoding == "identity" || pResponse.Uncompressed {
+		return nil
+	}
+
+	var lDecoded io.Reader
+	switch lEncoding {
+	case "gzip", "x-gzip":
+		lReader, lErr := gzip.NewReader(pResponse.Body)
+		if lErr != nil {
+			return fmt.Errorf("decodeBody:001 %w", lErr)
+		}
+		lDecoded = lReader
+	case "deflate":
+		lReader, lErr := newDeflateReader(pResponse.Body)
+		if lErr != nil {
+			return fmt.Errorf("decodeBody:002 %w", lErr)
+		}
+		lDecoded = lReader
+	case "br":
+		lDecoded = brotli.NewReader(pResponse.Body)
+	default:
+		return fmt.Errorf("decodeBody:003 unsupported Content-Encoding %q", lEncoding)
+	}
+
+	pResponse.Body = decodedBody{Reader: lDecoded, Closer: pResponse.Body}
+	pResponse.Header.Del("Content-Encoding")
+	pResponse.Header.Del("Content-Length")
+	pResponse.ContentLength = -1
+	pResponse.Uncompressed = true
+	return nil
+}
+
+// newDeflateReader reads an HTTP "deflate" body. The standard says zlib-wrapped data,
+// but some servers send raw DEFLATE, so the zlib header is checked first.
+func newDeflateReader(pBody io.Reader) (io.Reader, error) {
+	lHeader := make([]byte, 2)
+	lCount, _ := io.ReadFull(pBody, lHeader)
+	lBody := io.MultiReader(bytes.NewReader(lHeader[:lCount]), pBody)
+
+	if lCount == 2 && lHeader[0]&0x0f == 8 && (uint16(lHeader[0])<<8|uint16(lHeader[1]))%31 == 0 {
+		return zlib.NewReader(lBody)
+	}
+	return flate.NewReader(lBody), nil
+}
+
+// decodedBody pairs a decoding reader with the original body so that Close still reaches the connection.
+type decodedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// normalizedRetry fills in the defaults of a RetryPolicy.
+func normalizedRetry(pPolicy RetryPolicy) RetryPolicy {
+	if pPolicy.MaxAttempts < 1 {
+		pPolicy.MaxAttempts = 1
+	}
+	if pPolicy.BaseDelay <= 0 {
+		pPolicy.BaseDelay = 500 * time.Millisecond
+	}
+	if pPolicy.MaxDelay <= 0 {
+		pPolicy.MaxDelay = 30 * time.Second
+	}
+	return pPolicy
+}
+
+// retryableStatus reports whether a response status is worth retrying.
+func retryableStatus(pStatus int) bool {
+	return pStatus == http.StatusTooManyRequests || pStatus >= 500
+}
+
+// backoffDelay returns the jittered exponential wait before retry number pAttempt:
+// a random duration between half and all of BaseDelay * 2^(pAttempt-1), capped at MaxDelay.
+func backoffDelay(pPolicy RetryPolicy, pAttempt int) time.Duration {
+	lDelay := pPolicy.BaseDelay
+	for lStep := 1; lStep < pAttempt && lDelay < pPolicy.MaxDelay; lStep++ {
+		lDelay *= 2
+	}
+	if lDelay > pPolicy.MaxDelay {
+		lDelay = pPolicy.MaxDelay
+	}
+	return lDelay/2 + time.Duration(rand.Int63n(int64(lDelay/2)+1))
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an HTTP date.
+// It returns zero when the header is missing or invalid.
+func retryAfter(pValue string) time.Duration {
+	pValue = strings.TrimSpace(pValue)
+	if pValue == "" {
+		return 0
+	}
+	if lSeconds, lErr := strconv.Atoi(pValue); lErr == nil {
+		return time.Duration(lSeconds) * time.Second
+	}
+	if lDate, lErr := http.ParseTime(pValue); lErr == nil {
+		return time.Until(lDate)
+	}
+	return 0
+}
+
+// checkContentType rejects HTML error pages and, when pAccepted is set, any media type not listed in it.
+func checkContentType(pResponse *http.Response, pAccepted []string) error {
+	lHeader := pResponse.Header.Get("Content-Type")
+	if lHeader == "" {
+		return nil
+	}
+	lMediaType, _, lErr := mime.ParseMediaType(lHeader)
+	if lErr != nil {
+		lMediaType = strings.ToLower(strings.TrimSpace(strings.Split(lHeader, ";")[0]))
+	}
+
+	if len(pAccepted) > 0 {
+		for _, lAccepted := range pAccepted {
+			if strings.EqualFold(lMediaType, lAccepted) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w %q", ErrUnexpectedContentType, lMediaType)
+	}
+
+	if lMediaType == "text/html" || lMediaType == "application/xhtml+xml" {
+		return fmt.Errorf("%w %q", ErrUnexpectedContentType, lMediaType)
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
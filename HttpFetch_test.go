@@ -0,0 +1,258 @@
+package readfiles
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// fastRetry retries quickly so that tests do not sleep.
+var fastRetry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestFetchURLRetry(t *testing.T) {
+	lCases := []struct {
+		name        string
+		statuses    []int
+		contentType string
+		accept      []string
+		wantStatus  int
+		wantCalls   int32
+		wantErr     error
+	}{
+		{name: "success first time", statuses: []int{200}, wantCalls: 1},
+		{name: "5xx then success", statuses: []int{503, 502, 200}, wantCalls: 3},
+		{name: "429 then success", statuses: []int{429, 200}, wantCalls: 2},
+		{name: "5xx until attempts run out", statuses: []int{500, 500, 500, 200}, wantStatus: 500, wantCalls: 3},
+		{name: "404 is not retried", statuses: []int{404, 200}, wantStatus: 404, wantCalls: 1},
+		{name: "HTML error page", statuses: []int{200}, contentType: "text/html; charset=utf-8", wantStatus: 200, wantCalls: 1, wantErr: ErrUnexpectedContentType},
+		{name: "type outside the accepted list", statuses: []int{200}, contentType: "text/plain", accept: []string{"application/zip"}, wantStatus: 200, wantCalls: 1, wantErr: ErrUnexpectedContentType},
+		{name: "accepted type", statuses: []int{200}, contentType: "Application/Zip", accept: []string{"application/zip"}, wantCalls: 1},
+	}
+
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			var lCalls atomic.Int32
+			lServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				lCall := lCalls.Add(1)
+				if lCase.contentType != "" {
+					w.Header().Set("Content-Type", lCase.contentType)
+				}
+				if lCase.statuses[lCall-1] == http.StatusTooManyRequests {
+					w.Header().Set("Retry-After", "120")
+				}
+				w.WriteHeader(lCase.statuses[lCall-1])
+				io.WriteString(w, "body")
+			}))
+			defer lServer.Close()
+
+			lResponse, lErr := fetchURL(lServer.URL, nil, ReadOptions{Retry: fastRetry, AcceptContentTypes: lCase.accept})
+			if lCalls.Load() != lCase.wantCalls {
+				t.Errorf("calls = %d, want %d", lCalls.Load(), lCase.wantCalls)
+			}
+			if lCase.wantStatus == 0 && lCase.wantErr == nil {
+				if lErr != nil {
+					t.Fatal(lErr)
+				}
+				lResponse.Body.Close()
+				return
+			}
+
+			var lFailure *DownloadError
+			if !errors.As(lErr, &lFailure) {
+				t.Fatalf("err = %v, want a *DownloadError", lErr)
+			}
+			if lFailure.StatusCode != lCase.wantStatus || lFailure.Attempts != int(lCase.wantCalls) {
+				t.Errorf("failure = %+v, want status %d after %d attempts", lFailure, lCase.wantStatus, lCase.wantCalls)
+			}
+			if lCase.wantErr != nil && !errors.Is(lErr, lCase.wantErr) {
+				t.Errorf("err = %v, want %v", lErr, lCase.wantErr)
+			}
+		})
+	}
+}
+
+func TestFetchURLNetworkError(t *testing.T) {
+	lServer := httptest.NewServer(http.NotFoundHandler())
+	lUrl := lServer.URL
+	lServer.Close()
+
+	_, lErr := fetchURL(lUrl, nil, ReadOptions{Retry: fastRetry})
+	var lFailure *DownloadError
+	if !errors.As(lErr, &lFailure) || lFailure.StatusCode != 0 || lFailure.Attempts != 3 || lFailure.Err == nil {
+		t.Fatalf("err = %#v, want a network DownloadError after 3 attempts", lErr)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	lPolicy := normalizedRetry(RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second})
+	lCases := []struct {
+		attempt  int
+		low, top time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 200 * time.Millisecond, 400 * time.Millisecond},
+		{10, 500 * time.Millisecond, time.Second},
+	}
+	for _, lCase := range lCases {
+		for lRun := 0; lRun < 20; lRun++ {
+			if lDelay := backoffDelay(lPolicy, lCase.attempt); lDelay < lCase.low || lDelay > lCase.top {
+				t.Fatalf("backoffDelay(%d) = %v, want within [%v, %v]", lCase.attempt, lDelay, lCase.low, lCase.top)
+			}
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	lCases := map[string]time.Duration{
+		"":        0,
+		"7":       7 * time.Second,
+		" 2 ":     2 * time.Second,
+		"soon":    0,
+		"-1":      -time.Second,
+		"Mon, 01": 0,
+	}
+	for lValue, lWant := range lCases {
+		if lGot := retryAfter(lValue); lGot != lWant {
+			t.Errorf("retryAfter(%q) = %v, want %v", lValue, lGot, lWant)
+		}
+	}
+	if lGot := retryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); lGot <= 50*time.Second || lGot > time.Minute {
+		t.Errorf("retryAfter(date) = %v, want about a minute", lGot)
+	}
+}
+
+func TestFetchURLHeadersAndSession(t *testing.T) {
+	var lSeen http.Header
+	lServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/home":
+			http.SetCookie(w, &http.Cookie{Name: "nsit", Value: "abc", Path: "/"})
+		case "/file":
+			lSeen = r.Header.Clone()
+			if lCookie, lErr := r.Cookie("nsit"); lErr != nil || lCookie.Value != "abc" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			io.WriteString(w, "data")
+		}
+	}))
+	defer lServer.Close()
+
+	lCases := []struct {
+		name       string
+		options    HTTPOptions
+		wantStatus int
+		wantAgent  string
+	}{
+		{"no session", HTTPOptions{}, http.StatusUnauthorized, DefaultUserAgent},
+		{"primed session", HTTPOptions{PrimeURL: lServer.URL + "/home", UserAgent: "bot/1", Headers: http.Header{"Referer": {"https://example.com/"}}}, 0, "bot/1"},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			lResponse, lErr := fetchURL(lServer.URL+"/file", nil, ReadOptions{HTTP: lCase.options})
+			if lCase.wantStatus != 0 {
+				var lFailure *DownloadError
+				if !errors.As(lErr, &lFailure) || lFailure.StatusCode != lCase.wantStatus {
+					t.Fatalf("err = %v, want HTTP %d", lErr, lCase.wantStatus)
+				}
+			} else {
+				if lErr != nil {
+					t.Fatal(lErr)
+				}
+				lResponse.Body.Close()
+			}
+			if lAgent := lSeen.Get("User-Agent"); lAgent != lCase.wantAgent {
+				t.Errorf("User-Agent = %q, want %q", lAgent, lCase.wantAgent)
+			}
+			for lName := range lCase.options.Headers {
+				if lSeen.Get(lName) != lCase.options.Headers.Get(lName) {
+					t.Errorf("header %s = %q", lName, lSeen.Get(lName))
+				}
+			}
+		})
+	}
+}
+
+func TestFetchURLContentEncoding(t *testing.T) {
+	lPlain := []byte("SYMBOL,QTY\nINFY,10\n")
+	lEncode := func(pNew func(io.Writer) io.WriteCloser) []byte {
+		var lBuffer bytes.Buffer
+		lWriter := pNew(&lBuffer)
+		lWriter.Write(lPlain)
+		lWriter.Close()
+		return lBuffer.Bytes()
+	}
+	lCases := []struct {
+		encoding string
+		body     []byte
+	}{
+		{"gzip", lEncode(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })},
+		{"deflate", lEncode(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) })},
+		{"deflate", lEncode(func(w io.Writer) io.WriteCloser {
+			lWriter, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return lWriter
+		})},
+		{"br", lEncode(func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) })},
+		{"identity", lPlain},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.encoding, func(t *testing.T) {
+			lServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", lCase.encoding)
+				w.Write(lCase.body)
+			}))
+			defer lServer.Close()
+
+			lOptions := ReadOptions{HTTP: HTTPOptions{Headers: http.Header{"Accept-Encoding": {"gzip, deflate, br"}}}}
+			lResponse, lErr := fetchURL(lServer.URL, nil, lOptions)
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			defer lResponse.Body.Close()
+			lData, lErr := io.ReadAll(lResponse.Body)
+			if lErr != nil || !bytes.Equal(lData, lPlain) {
+				t.Fatalf("body = %q, %v", lData, lErr)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	lBase := &http.Client{Timeout: time.Minute}
+	lClient, lErr := newHTTPClient(HTTPOptions{Client: lBase, Timeout: time.Second, CookieJar: true})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	if lClient == lBase || lBase.Timeout != time.Minute || lBase.Jar != nil {
+		t.Error("the caller's client was modified")
+	}
+	if lClient.Timeout != time.Second || lClient.Jar == nil {
+		t.Errorf("client = %+v, want the timeout and a cookie jar", lClient)
+	}
+
+	if _, lErr := newHTTPClient(HTTPOptions{Proxy: "http://[bad"}); lErr == nil {
+		t.Error("a bad proxy URL was accepted")
+	}
+
+	// Clients share one transport per proxy, so connections are reused across downloads.
+	lFirst, _ := newHTTPClient(HTTPOptions{Proxy: "http://proxy.example.com:3128"})
+	lSecond, _ := newHTTPClient(HTTPOptions{Proxy: "http://proxy.example.com:3128"})
+	lOther, _ := newHTTPClient(HTTPOptions{Proxy: "http://other.example.com:3128"})
+	if lFirst.Transport != lSecond.Transport || lFirst.Transport == lOther.Transport {
+		t.Error("clients for the same proxy do not share a transport")
+	}
+	if lDirect, _ := newHTTPClient(HTTPOptions{}); lDirect.Transport != http.DefaultTransport {
+		t.Errorf("transport without a proxy = %T, want http.DefaultTransport", lDirect.Transport)
+	}
+}
@@ -0,0 +1,261 @@
+package readfiles
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//------------------------------------------------------------- Join ----------------------------------------------------------------
+
+// ErrColumnNotFound is returned when a named column is not in a Table's header.
+var ErrColumnNotFound = errors.New("column not found")
+
+// JoinKind chooses which unmatched rows JoinTables keeps.
+type JoinKind int
+
+const (
+	// InnerJoin keeps only rows whose key is on both sides.
+	InnerJoin JoinKind = iota
+	// LeftJoin also keeps left rows without a match, with blank right columns.
+	LeftJoin
+	// RightJoin also keeps right rows without a match, with blank left columns.
+	RightJoin
+	// FullJoin keeps unmatched rows from both sides.
+	FullJoin
+)
+
+// JoinOptions configures JoinTables.
+type JoinOptions struct {
+	Kind JoinKind
+	// Keys are the key column names, found in both headers case-insensitively, for example "SYMBOL" or "SYMBOL", "SERIES".
+	Keys []string
+	// RightKeys, if set, names the right key columns instead of Keys, in the same order,
+	// for example Keys "SYMBOL" with RightKeys "TckrSymb".
+	RightKeys []string
+	// IgnoreCase compares key values case-insensitively. Key values are always compared without surrounding spaces.
+	IgnoreCase bool
+	// LeftSuffix and RightSuffix are appended to a non-key column name found on both sides.
+	// They default to "_left" and "_right".
+	LeftSuffix  string
+	RightSuffix string
+}
+
+// JoinResult is the joined Table and the keys that found no partner.
+type JoinResult struct {
+	Table
+	// UnmatchedLeft and UnmatchedRight hold each distinct key without a match on the other side, in input order,
+	// one value per key column. They are filled for every JoinKind.
+	UnmatchedLeft  [][]string
+	UnmatchedRight [][]string
+}
+
+// JoinTables joins two tables on key columns with a hash join: the right table is indexed by key once,
+// and each left row is looked up in the index. The result has the key columns first, named as on the left,
+// then the other left columns, then the other right columns. Matched rows follow the left table's order,
+// with several right matches in the right table's order; unmatched right rows come last.
+// A row with a blank key cell never matches, as with NULL in SQL.
+
+// Step-by-Step Process:
+// 1. Resolve the key columns on both sides and build the output header, adding suffixes to shared names.
+// 2. Index the right rows by key.
+// 3. Walk the left rows, emitting one row per match, or a padded row for LeftJoin and FullJoin.
+// 4. Emit the right rows that were never matched for RightJoin and FullJoin.
+// 5. Collect the distinct unmatched keys of both sides.
+func JoinTables(pLeft Table, pRight Table, pOptions JoinOptions) (JoinResult, error) {
+	var lResult JoinResult
+
+	lRightKeys := pOptions.RightKeys
+	if len(lRightKeys) == 0 {
+		lRightKeys = pOptions.Keys
+	}
+	if len(pOptions.Keys) == 0 || len(lRightKeys) != len(pOptions.Keys) {
+		return lResult, fmt.Errorf("JoinTables:001 need the same number of left and right keys, got %d and %d", len(pOptions.Keys), len(lRightKeys))
+	}
+	lLeftIndex, lErr := joinColumns(pLeft, pOptions.Keys)
+	if lErr != nil {
+		return lResult, fmt.Errorf("JoinTables:002 left %w", lErr)
+	}
+	lRightIndex, lErr := joinColumns(pRight, lRightKeys)
+	if lErr != nil {
+		return lResult, fmt.Errorf("JoinTables:003 right %w", lErr)
+	}
+
+	lLeftRest := otherColumns(len(pLeft.Header), lLeftIndex)
+	lRightRest := otherColumns(len(pRight.Header), lRightIndex)
+	lResult.Header = joinHeader(pLeft, pRight, lLeftIndex, lLeftRest, lRightRest, pOptions)
+
+	lIndex := make(map[string][]int)
+	for lRow, lValues := range pRight.Rows {
+		if lKey, lOk := joinKey(lValues, lRightIndex, pOptions.IgnoreCase); lOk {
+			lIndex[lKey] = append(lIndex[lKey], lRow)
+		}
+	}
+
+	lMatchedRight := make([]bool, len(pRight.Rows))
+	lUnmatchedLeft := make(map[string]bool)
+	for _, lValues := range pLeft.Rows {
+		lKey, lOk := joinKey(lValues, lLeftIndex, pOptions.IgnoreCase)
+		lMatches := lIndex[lKey]
+		if !lOk || len(lMatches) == 0 {
+			lCells := cellsAt(lValues, lLeftIndex)
+			if lSeen := strings.Join(lCells, "\x00"); !lUnmatchedLeft[lSeen] {
+				lUnmatchedLeft[lSeen] = true
+				lResult.UnmatchedLeft = append(lResult.UnmatchedLeft, lCells)
+			}
+			if pOptions.Kind == LeftJoin || pOptions.Kind == FullJoin {
+				lResult.Rows = append(lResult.Rows, joinRow(lValues, nil, lLeftIndex, lLeftRest, lRightRest))
+			}
+			continue
+		}
+		for _, lMatch := range lMatches {
+			lMatchedRight[lMatch] = true
+			lResult.Rows = append(lResult.Rows, joinRow(lValues, pRight.Rows[lMatch], lLeftIndex, lLeftRest, lRightRest))
+		}
+	}
+
+	lUnmatchedRight := make(map[string]bool)
+	for lRow, lValues := range pRight.Rows {
+		if lMatchedRight[lRow] {
+			continue
+		}
+		lCells := cellsAt(lValues, lRightIndex)
+		if lSeen := strings.Join(lCells, "\x00"); !lUnmatchedRight[lSeen] {
+			lUnmatchedRight[lSeen] = true
+			lResult.UnmatchedRight = append(lResult.UnmatchedRight, lCells)
+		}
+		if pOptions.Kind == RightJoin || pOptions.Kind == FullJoin {
+			// The key columns are taken from the right row, as there is no left one.
+			lRow := joinRow(nil, lValues, lLeftIndex, lLeftRest, lRightRest)
+			for lPosition, lColumn := range lRightIndex {
+				lRow[lPosition] = cellAt(lValues, lColumn)
+			}
+			lResult.Rows = append(lResult.Rows, lRow)
+		}
+	}
+	return lResult, nil
+}
+
+// joinColumns returns the header positions of pNames in pTable.
+func joinColumns(pTable Table, pNames []string) ([]int, error) {
+	lColumns := make([]int, len(pNames))
+	for lPosition, lName := range pNames {
+		lColumns[lPosition] = pTable.ColumnIndex(lName)
+		if lColumns[lPosition] < 0 {
+			return nil, fmt.Errorf("%q: %w", lName, ErrColumnNotFound)
+		}
+	}
+	return lColumns, nil
+}
+
+// otherColumns returns the positions 0..pCount-1 that are not in pKeys.
+func otherColumns(pCount int, pKeys []int) []int {
+	lKey := make(map[int]bool)
+	for _, lColumn := range pKeys {
+		lKey[lColumn] = true
+	}
+	var lOther []int
+	for lColumn := 0; lColumn < pCount; lColumn++ {
+		if !lKey[lColumn] {
+			lOther = append(lOther, lColumn)
+		}
+	}
+	return lOther
+}
+
+// joinHeader builds the output header: left key names, then the other left and right names,
+// suffixed where a name appears on both sides. A right column named like a left key, which happens
+// when RightKeys differ from Keys, is suffixed too, so that no output name is repeated.
+func joinHeader(pLeft Table, pRight Table, pLeftKeys []int, pLeftRest []int, pRightRest []int, pOptions JoinOptions) []string {
+	lLeftSuffix, lRightSuffix := pOptions.LeftSuffix, pOptions.RightSuffix
+	if lLeftSuffix == "" {
+		lLeftSuffix = "_left"
+	}
+	if lRightSuffix == "" {
+		lRightSuffix = "_right"
+	}
+
+	lRightNames := make(map[string]bool)
+	for _, lColumn := range pRightRest {
+		lRightNames[strings.ToLower(strings.TrimSpace(pRight.Header[lColumn]))] = true
+	}
+	lLeftNames := make(map[string]bool)
+	for _, lColumn := range pLeftRest {
+		lLeftNames[strings.ToLower(strings.TrimSpace(pLeft.Header[lColumn]))] = true
+	}
+	lLeftKeyNames := make(map[string]bool)
+	for _, lColumn := range pLeftKeys {
+		lLeftKeyNames[strings.ToLower(strings.TrimSpace(pLeft.Header[lColumn]))] = true
+	}
+
+	var lHeader []string
+	for _, lColumn := range pLeftKeys {
+		lHeader = append(lHeader, pLeft.Header[lColumn])
+	}
+	for _, lColumn := range pLeftRest {
+		lName := pLeft.Header[lColumn]
+		if lRightNames[strings.ToLower(strings.TrimSpace(lName))] {
+			lName += lLeftSuffix
+		}
+		lHeader = append(lHeader, lName)
+	}
+	for _, lColumn := range pRightRest {
+		lName := pRight.Header[lColumn]
+		lKey := strings.ToLower(strings.TrimSpace(lName))
+		if lLeftNames[lKey] || lLeftKeyNames[lKey] {
+			lName += lRightSuffix
+		}
+		lHeader = append(lHeader, lName)
+	}
+	return lHeader
+}
+
+// joinKey returns the lookup key of a row, and false when a key cell is blank.
+func joinKey(pRow []string, pColumns []int, pIgnoreCase bool) (string, bool) {
+	lValues := cellsAt(pRow, pColumns)
+	for lPosition, lValue := range lValues {
+		if lValue == "" {
+			return "", false
+		}
+		if pIgnoreCase {
+			lValues[lPosition] = strings.ToLower(lValue)
+		}
+	}
+	return strings.Join(lValues, "\x00"), true
+}
+
+// cellsAt returns the trimmed cells of pRow at pColumns; missing cells are blank.
+func cellsAt(pRow []string, pColumns []int) []string {
+	lCells := make([]string, len(pColumns))
+	for lPosition, lColumn := range pColumns {
+		if lColumn < len(pRow) {
+			lCells[lPosition] = strings.TrimSpace(pRow[lColumn])
+		}
+	}
+	return lCells
+}
+
+// joinRow lays out one output row; a nil side gives blank cells.
+func joinRow(pLeft []string, pRight []string, pLeftKeys []int, pLeftRest []int, pRightRest []int) []string {
+	lRow := make([]string, 0, len(pLeftKeys)+len(pLeftRest)+len(pRightRest))
+	for _, lColumn := range pLeftKeys {
+		lRow = append(lRow, cellAt(pLeft, lColumn))
+	}
+	for _, lColumn := range pLeftRest {
+		lRow = append(lRow, cellAt(pLeft, lColumn))
+	}
+	for _, lColumn := range pRightRest {
+		lRow = append(lRow, cellAt(pRight, lColumn))
+	}
+	return lRow
+}
+
+// cellAt returns the cell of pRow at pColumn, or "" when the row is shorter.
+func cellAt(pRow []string, pColumn int) string {
+	if pColumn < len(pRow) {
+		return pRow[pColumn]
+	}
+	return ""
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,141 @@
+package readfiles
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestJoinTables(t *testing.T) {
+	lTrades := Table{
+		Header: []string{"SYMBOL", "QTY", "PRICE"},
+		Rows: [][]string{
+			{"INFY", "10", "1450"},
+			{" TCS ", "5", "3500"},
+			{"infy", "2", "1449"},
+			{"", "1", "1"},
+			{"HDFC", "3", "1600"},
+		},
+	}
+	lMaster := Table{
+		Header: []string{"Symbol", "ISIN", "price"},
+		Rows: [][]string{
+			{"INFY", "INE009A01021", "1451"},
+			{"TCS", "INE467B01029", "3499"},
+			{"WIPRO", "INE075A01022", "450"},
+			{"TCS", "INE467B01030", "3500"},
+		},
+	}
+	lHeader := []string{"SYMBOL", "QTY", "PRICE_left", "ISIN", "price_right"}
+	lMatched := [][]string{
+		{"INFY", "10", "1450", "INE009A01021", "1451"},
+		// Several right matches follow the right table's order.
+		{" TCS ", "5", "3500", "INE467B01029", "3499"},
+		{" TCS ", "5", "3500", "INE467B01030", "3500"},
+	}
+	lLeftOnly := [][]string{
+		{"infy", "2", "1449", "", ""},
+		{"", "1", "1", "", ""},
+		{"HDFC", "3", "1600", "", ""},
+	}
+	lRightOnly := [][]string{{"WIPRO", "", "", "INE075A01022", "450"}}
+	lJoin := func(pParts ...[][]string) [][]string {
+		var lRows [][]string
+		for _, lPart := range pParts {
+			lRows = append(lRows, lPart...)
+		}
+		return lRows
+	}
+
+	lCases := []struct {
+		name          string
+		options       JoinOptions
+		wantRows      [][]string
+		wantUnmatched [][]string
+	}{
+		{"inner", JoinOptions{Kind: InnerJoin, Keys: []string{"symbol"}}, lMatched, [][]string{{"infy"}, {""}, {"HDFC"}}},
+		{"left", JoinOptions{Kind: LeftJoin, Keys: []string{"SYMBOL"}}, lJoin(lMatched, lLeftOnly), [][]string{{"infy"}, {""}, {"HDFC"}}},
+		{"right", JoinOptions{Kind: RightJoin, Keys: []string{"SYMBOL"}}, lJoin(lMatched, lRightOnly), [][]string{{"infy"}, {""}, {"HDFC"}}},
+		{"full", JoinOptions{Kind: FullJoin, Keys: []string{"SYMBOL"}}, lJoin(lMatched, lLeftOnly, lRightOnly), [][]string{{"infy"}, {""}, {"HDFC"}}},
+		{
+			name:          "inner, ignoring case",
+			options:       JoinOptions{Kind: InnerJoin, Keys: []string{"SYMBOL"}, IgnoreCase: true},
+			wantRows:      lJoin(lMatched, [][]string{{"infy", "2", "1449", "INE009A01021", "1451"}}),
+			wantUnmatched: [][]string{{""}, {"HDFC"}},
+		},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			lResult, lErr := JoinTables(lTrades, lMaster, lCase.options)
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			if !reflect.DeepEqual(lResult.Header, lHeader) {
+				t.Errorf("header = %q, want %q", lResult.Header, lHeader)
+			}
+			if !reflect.DeepEqual(lResult.Rows, lCase.wantRows) {
+				t.Errorf("rows = %q\nwant   %q", lResult.Rows, lCase.wantRows)
+			}
+			// Unmatched keys are reported whatever the join kind.
+			if !reflect.DeepEqual(lResult.UnmatchedLeft, lCase.wantUnmatched) || !reflect.DeepEqual(lResult.UnmatchedRight, [][]string{{"WIPRO"}}) {
+				t.Errorf("unmatched = %q and %q", lResult.UnmatchedLeft, lResult.UnmatchedRight)
+			}
+		})
+	}
+}
+
+func TestJoinTablesKeys(t *testing.T) {
+	lLeft := Table{
+		Header: []string{"SYMBOL", "SERIES", "QTY"},
+		Rows:   [][]string{{"INFY", "EQ", "10"}, {"INFY", "BE", "4"}},
+	}
+	lRight := Table{
+		Header: []string{"TckrSymb", "SctySrs", "QTY", "CLOSE"},
+		Rows:   [][]string{{"INFY", "BE", "7", "1440"}, {"INFY", "EQ", "9", "1450"}, {"INFY"}},
+	}
+	lResult, lErr := JoinTables(lLeft, lRight, JoinOptions{Keys: []string{"SYMBOL", "SERIES"}, RightKeys: []string{"TckrSymb", "SctySrs"}, LeftSuffix: "_trade", RightSuffix: "_bhav"})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lWant := Table{
+		Header: []string{"SYMBOL", "SERIES", "QTY_trade", "QTY_bhav", "CLOSE"},
+		Rows:   [][]string{{"INFY", "EQ", "10", "9", "1450"}, {"INFY", "BE", "4", "7", "1440"}},
+	}
+	if !reflect.DeepEqual(lResult.Table, lWant) {
+		t.Errorf("table = %q, want %q", lResult.Table, lWant)
+	}
+	// A short row has a blank key cell, so it never matches.
+	if !reflect.DeepEqual(lResult.UnmatchedRight, [][]string{{"INFY", ""}}) {
+		t.Errorf("unmatched right = %q", lResult.UnmatchedRight)
+	}
+
+	// The right table's SYMBOL column is not a key here, so it is suffixed instead of repeating the key name.
+	lResult, lErr = JoinTables(
+		Table{Header: []string{"SYMBOL", "QTY"}, Rows: [][]string{{"INFY", "10"}}},
+		Table{Header: []string{"TckrSymb", "Symbol", "CLOSE"}, Rows: [][]string{{"INFY", "INFOSYS", "1450"}}},
+		JoinOptions{Keys: []string{"SYMBOL"}, RightKeys: []string{"TckrSymb"}},
+	)
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	if lWant := []string{"SYMBOL", "QTY", "Symbol_right", "CLOSE"}; !reflect.DeepEqual(lResult.Header, lWant) {
+		t.Errorf("header = %q, want %q", lResult.Header, lWant)
+	}
+
+	lCases := []struct {
+		name    string
+		options JoinOptions
+		wantErr error
+	}{
+		{"no keys", JoinOptions{}, errAny},
+		{"key counts differ", JoinOptions{Keys: []string{"SYMBOL", "SERIES"}, RightKeys: []string{"TckrSymb"}}, errAny},
+		{"left column missing", JoinOptions{Keys: []string{"ISIN"}, RightKeys: []string{"TckrSymb"}}, ErrColumnNotFound},
+		{"right column missing", JoinOptions{Keys: []string{"SYMBOL"}}, ErrColumnNotFound},
+	}
+	for _, lCase := range lCases {
+		_, lErr := JoinTables(lLeft, lRight, lCase.options)
+		if lErr == nil || lCase.wantErr != errAny && !errors.Is(lErr, lCase.wantErr) {
+			t.Errorf("%s: err = %v, want %v", lCase.name, lErr, lCase.wantErr)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package readfiles
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+//--------------------------------------------------------- Nested Archives ---------------------------------------------------------
+
+// DefaultMaxDepth is the number of nested archive levels opened when ReadOptions.MaxDepth is zero.
+const DefaultMaxDepth = 4
+
+// ErrNestingTooDeep is recorded for a nested archive that lies below ReadOptions.MaxDepth.
+// It never aborts the read, even with StopOnError.
+var ErrNestingTooDeep = errors.New("nested archive exceeds the maximum depth")
+
+// readEntry reads one entry, given as a stream, at nesting level pDepth.
+// Data files are parsed into a single EntryResult; archives are opened and their entries read in turn.
+// The returned error is set only when StopOnError asks to abort.
+func readEntry(pPath string, pName string, pFormat FileFormat, pReader io.Reader, pSize int64, pDepth int, pOptions ReadOptions) ([]EntryResult, error) {
+	lFormat := pFormat
+	if _, lCustom := pOptions.parser(pName); lCustom {
+		// A registered parser takes the entry as data, whatever its extension.
+		lFormat = ""
+	}
+	switch lFormat {
+	case FormatZip, FormatTar:
+		lMaxDepth := pOptions.MaxDepth
+		if lMaxDepth < 0 {
+			return nil, nil
+		}
+		if lMaxDepth == 0 {
+			lMaxDepth = DefaultMaxDepth
+		}
+		if pDepth+1 > lMaxDepth {
+			// The archive is noted but, unlike a damaged entry, does not stop the read.
+			reportParse(pPath, 1, 0, ErrNestingTooDeep, pOptions)
+			return []EntryResult{{Name: pName, Path: pPath, Format: pFormat, Err: ErrNestingTooDeep}}, nil
+		}
+		if pFormat == FormatZip {
+			return readNestedZip(pPath, pName, pReader, pSize, pDepth+1, pOptions)
+		}
+		return readTarStream(pPath, pReader, pDepth+1, pOptions)
+
+	case FormatGzip:
+		return readGzipStream(pPath, pName, pReader, pDepth, pOptions)
+	}
+
+	lResult := EntryResult{Name: pName, Path: pPath, Format: pFormat}
+	parseEntry(&lResult, pReader, pOptions)
+	reportParse(pPath, 1, len(lResult.Rows), lResult.Err, pOptions)
+	if lResult.Err != nil && pOptions.StopOnError {
+		return []EntryResult{lResult}, fmt.Errorf("ReadZipEntries:001 %s: %w", pPath, lResult.Err)
+	}
+	return []EntryResult{lResult}, nil
+}
+
+// readNestedZip stores a ZIP entry in memory or a temporary file, as zip.NewReader needs random access, and reads its entries.
+func readNestedZip(pPath string, pName string, pReader io.Reader, pSize int64, pDepth int, pOptions ReadOptions) ([]EntryResult, error) {
+	lSpool, lErr := spoolDownload(pReader, pSize, pOptions.MemoryLimit, pName)
+	if lErr != nil {
+		return entryFailure(pPath, pName, FormatZip, lErr, pOptions)
+	}
+	defer lSpool.Close()
+
+	lArchive, lErr := zip.NewReader(lSpool.ReaderAt(), lSpool.Size())
+	if lErr != nil {
+		return entryFailure(pPath, pName, FormatZip, lErr, pOptions)
+	}
+	return readZipFiles(pPath, lArchive.File, pDepth, pOptions)
+}
+
+// readTarStream reads the regular files of a tar stream in order.
+func readTarStream(pPath string, pReader io.Reader, pDepth int, pOptions ReadOptions) ([]EntryResult, error) {
+	var lResults []EntryResult
+	pOptions = pOptions.withBudget()
+
+	lArchive := tar.NewReader(pReader)
+	for {
+		lHeader, lErr := lArchive.Next()
+		if lErr == io.EOF {
+			break
+		}
+		if lLimitErr := pOptions.budget.failed(); lLimitErr != nil {
+			return lResults, fmt.Errorf("readTarStream:002 %w", lLimitErr)
+		}
+		if lErr != nil {
+			lFailures, lStop := entryFailure(pPath, path.Base(pPath), FormatTar, lErr, pOptions)
+			return append(lResults, lFailures...), lStop
+		}
+		if lHeader.Typeflag != tar.TypeReg {
+			continue
+		}
+		lPath := entryPath(pPath, lHeader.Name)
+		lErr = pOptions.budget.addEntry(lPath)
+		if lErr != nil {
+			return lResults, fmt.Errorf("readTarStream:003 %w", lErr)
+		}
+		lFormat, lOk := entryFormat(lHeader.Name, pOptions)
+		if !lOk {
+			continue
+		}
+		lSelected, lErr := selectEntry(lHeader.Name, lFormat, pOptions)
+		if lErr != nil {
+			return lResults, fmt.Errorf("readTarStream:001 %w", lErr)
+		}
+		if !lSelected {
+			continue
+		}
+
+		lEntries, lErr := readEntry(lPath, lHeader.Name, lFormat, limitEntry(lArchive, lPath, pOptions, nil), lHeader.Size, pDepth, pOptions)
+		lResults = append(lResults, lEntries...)
+		if lErr != nil {
+			return lResults, lErr
+		}
+		lErr = pOptions.budget.failed()
+		if lErr != nil {
+			return lResults, fmt.Errorf("readTarStream:004 %w", lErr)
+		}
+	}
+	return lResults, nil
+}
+
+// readGzipStream decompresses a .gz entry and reads what is inside: a tar stream for .tar.gz and .tgz,
+// or a single file whose format comes from the name without ".gz" (e.g. "bhav.csv.gz").
+// Gzip is only a compression layer, so it does not count as a nesting level.
+func readGzipStream(pPath string, pName string, pReader io.Reader, pDepth int, pOptions ReadOptions) ([]EntryResult, error) {
+	lCompressed := &countingReader{reader: pReader}
+	lInner, lErr := gzip.NewReader(lCompressed)
+	if lErr != nil {
+		return entryFailure(pPath, pName, FormatGzip, lErr, pOptions)
+	}
+	defer lInner.Close()
+
+	lInnerName := strings.TrimSuffix(pName, path.Ext(pName))
+	if strings.EqualFold(path.Ext(pName), ".tgz") {
+		lInnerName += ".tar"
+	}
+	lFormat, lOk := entryFormat(lInnerName, pOptions)
+	if !lOk || lFormat == FormatGzip {
+		return nil, nil
+	}
+	// The .gz entry itself passed Select as an archive; the file inside must pass Include as data.
+	lSelected, lErr := selectEntry(lInnerName, lFormat, pOptions)
+	if lErr != nil {
+		return nil, fmt.Errorf("readGzipStream:001 %w", lErr)
+	}
+	if !lSelected {
+		return nil, nil
+	}
+	// The ratio of the whole gzip stream is checked here; a tar inside counts its files itself.
+	lLimited := limitEntry(lInner, pPath, pOptions, func() int64 { return lCompressed.count })
+	lLimited.container = lFormat == FormatTar
+	return readEntry(pPath, lInnerName, lFormat, lLimited, -1, pDepth, pOptions)
+}
+
+// entryFailure records an entry that could not be read, reports it to OnParse and, with StopOnError,
+// returns the error that aborts the archive.
+func entryFailure(pPath string, pName string, pFormat FileFormat, pErr error, pOptions ReadOptions) ([]EntryResult, error) {
+	reportParse(pPath, 1, 0, pErr, pOptions)
+	return failedEntry(pPath, pName, pFormat, pErr, pOptions)
+}
+
+// failedEntry is entryFailure for an entry that OnParse has already counted.
+func failedEntry(pPath string, pName string, pFormat FileFormat, pErr error, pOptions ReadOptions) ([]EntryResult, error) {
+	lResult := EntryResult{Name: pName, Path: pPath, Format: pFormat, Err: pErr}
+	if pOptions.StopOnError {
+		return []EntryResult{lResult}, fmt.Errorf("ReadZipEntries:001 %s: %w", pPath, pErr)
+	}
+	return []EntryResult{lResult}, nil
+}
+
+// reportParse adds pEntries entries and pRows rows to the running totals and calls OnParse, if set.
+func reportParse(pPath string, pEntries int, pRows int, pErr error, pOptions ReadOptions) {
+	if pOptions.OnParse == nil {
+		return
+	}
+	lEntries, lRows := pOptions.budget.parsed(pEntries, pRows)
+	pOptions.OnParse(ParseProgress{Path: pPath, Entries: lEntries, Rows: lRows, Err: pErr})
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
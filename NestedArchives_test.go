@@ -0,0 +1,104 @@
+package readfiles
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// nestedZip wraps pInner in pLevels further ZIP archives named level1.zip, level2.zip and so on.
+func nestedZip(t *testing.T, pInner []byte, pLevels int) []byte {
+	t.Helper()
+	lData := pInner
+	for lLevel := pLevels; lLevel >= 1; lLevel-- {
+		lData = zipBytes(t, testFile{fmt.Sprintf("level%d.zip", lLevel), string(lData)})
+	}
+	return lData
+}
+
+func TestReadNestedArchives(t *testing.T) {
+	lInner := zipBytes(t, testFile{"deep.csv", "1,2\n"})
+	lArchive := zipBytes(t,
+		testFile{"top.csv", "a,b\n"},
+		testFile{"inner.zip", string(lInner)},
+		testFile{"bundle.tar", string(tarBytes(t, testFile{"t.txt", "x|y\n"}))},
+		testFile{"bhav.csv.gz", string(gzipBytes(t, "bhav.csv", []byte("g,h\n")))},
+		testFile{"two.zip", string(nestedZip(t, lInner, 1))},
+	)
+
+	lCases := []struct {
+		name      string
+		options   ReadOptions
+		wantPaths []string
+		wantDeep  []string
+	}{
+		{
+			name:      "default depth",
+			options:   ReadOptions{},
+			wantPaths: []string{"top.csv", "inner.zip/deep.csv", "bundle.tar/t.txt", "bhav.csv.gz", "two.zip/level1.zip/deep.csv"},
+		},
+		{
+			name:      "depth one records deeper archives",
+			options:   ReadOptions{MaxDepth: 1, StopOnError: true},
+			wantPaths: []string{"top.csv", "inner.zip/deep.csv", "bundle.tar/t.txt", "bhav.csv.gz", "two.zip/level1.zip"},
+			wantDeep:  []string{"two.zip/level1.zip"},
+		},
+		{
+			name:      "negative depth skips nested archives",
+			options:   ReadOptions{MaxDepth: -1, StopOnError: true},
+			wantPaths: []string{"top.csv", "bhav.csv.gz"},
+		},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			lReader, lErr := zip.NewReader(bytes.NewReader(lArchive), int64(len(lArchive)))
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			lResults, lErr := ReadZipEntries(lReader, lCase.options)
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			var lPaths, lDeep []string
+			for _, lResult := range lResults {
+				lPaths = append(lPaths, lResult.Path)
+				if errors.Is(lResult.Err, ErrNestingTooDeep) {
+					lDeep = append(lDeep, lResult.Path)
+				} else if lResult.Err != nil {
+					t.Errorf("%s: %v", lResult.Path, lResult.Err)
+				}
+			}
+			if !reflect.DeepEqual(lPaths, lCase.wantPaths) {
+				t.Errorf("paths = %q, want %q", lPaths, lCase.wantPaths)
+			}
+			if !reflect.DeepEqual(lDeep, lCase.wantDeep) {
+				t.Errorf("too deep = %q, want %q", lDeep, lCase.wantDeep)
+			}
+		})
+	}
+}
+
+func TestReadZipDepthLimit(t *testing.T) {
+	// Five levels of nesting under the default depth of four.
+	lArchive := zipBytes(t,
+		testFile{"top.csv", "a,b\n"},
+		testFile{"deep.zip", string(nestedZip(t, zipBytes(t, testFile{"x.csv", "1,2\n"}), DefaultMaxDepth))},
+	)
+	lServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(lArchive)
+	}))
+	defer lServer.Close()
+
+	lRows, lErr := ReadZip(lServer.URL+"/nested.zip", "nested.zip")
+	if lErr != nil {
+		t.Fatalf("ReadZip failed on the depth limit: %v", lErr)
+	}
+	if !reflect.DeepEqual(lRows, [][]string{{"a", "b"}}) {
+		t.Errorf("rows = %q", lRows)
+	}
+}
@@ -0,0 +1,82 @@
+package readfiles
+
+import (
+	"io"
+	"time"
+)
+
+//-------------------------------------------------------------- Progress -----------------------------------------------------------
+
+// DefaultProgressInterval is how often progress hooks are called when ReadOptions.ProgressInterval is zero.
+const DefaultProgressInterval = 500 * time.Millisecond
+
+// DownloadProgress is passed to ReadOptions.OnDownload while an archive is downloaded.
+// Total, ETA and Rate are -1 when they are not known yet, for example without a Content-Length.
+// Bytes includes any part kept from an earlier, interrupted attempt; Rate only counts the current one.
+type DownloadProgress struct {
+	URL   string
+	Bytes int64
+	Total int64
+	// Rate is in bytes per second.
+	Rate float64
+	ETA  time.Duration
+	// Done is set on the last call, when the body has been read to the end.
+	Done bool
+}
+
+// ParseProgress is passed to ReadOptions.OnParse each time an entry has been parsed or could not be read.
+// Entries and Rows are running totals across the archive and its nested archives; Err is set for a failed entry.
+type ParseProgress struct {
+	Path    string
+	Entries int
+	Rows    int
+	Err     error
+}
+
+// progressReader calls the download hook as the body is read, at most once per interval and once at the end.
+type progressReader struct {
+	reader   io.Reader
+	url      string
+	start    int64
+	total    int64
+	read     int64
+	began    time.Time
+	last     time.Time
+	interval time.Duration
+	hook     func(DownloadProgress)
+}
+
+// progressBody wraps a response body with the download hook of pOptions, if one is set.
+// pStart is the number of bytes already on disk and pTotal the full size, or -1 when unknown.
+func progressBody(pBody io.Reader, pUrl string, pStart int64, pTotal int64, pOptions ReadOptions) io.Reader {
+	if pOptions.OnDownload == nil {
+		return pBody
+	}
+	lInterval := pOptions.ProgressInterval
+	if lInterval <= 0 {
+		lInterval = DefaultProgressInterval
+	}
+	lNow := time.Now()
+	return &progressReader{reader: pBody, url: pUrl, start: pStart, total: pTotal, began: lNow, last: lNow, interval: lInterval, hook: pOptions.OnDownload}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	lCount, lErr := r.reader.Read(p)
+	r.read += int64(lCount)
+
+	lNow := time.Now()
+	if lErr == io.EOF || lNow.Sub(r.last) >= r.interval {
+		r.last = lNow
+		lProgress := DownloadProgress{URL: r.url, Bytes: r.start + r.read, Total: r.total, Rate: -1, ETA: -1, Done: lErr == io.EOF}
+		if lElapsed := lNow.Sub(r.began).Seconds(); lElapsed > 0 {
+			lProgress.Rate = float64(r.read) / lElapsed
+		}
+		if lProgress.Total > 0 && lProgress.Rate > 0 {
+			lProgress.ETA = time.Duration(float64(lProgress.Total-lProgress.Bytes) / lProgress.Rate * float64(time.Second))
+		}
+		r.hook(lProgress)
+	}
+	return lCount, lErr
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
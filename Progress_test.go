@@ -0,0 +1,144 @@
+package readfiles
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressBody(t *testing.T) {
+	var lCalls []DownloadProgress
+	lOptions := ReadOptions{OnDownload: func(p DownloadProgress) { lCalls = append(lCalls, p) }, ProgressInterval: time.Hour}
+
+	// 10 bytes were kept from an earlier attempt; the body holds the other 6.
+	lBody := progressBody(strings.NewReader("abcdef"), "https://x/a.zip", 10, 16, lOptions)
+	if _, lErr := io.Copy(io.Discard, lBody); lErr != nil {
+		t.Fatal(lErr)
+	}
+	// With a long interval only the final call is made.
+	if len(lCalls) != 1 {
+		t.Fatalf("calls = %+v, want 1", lCalls)
+	}
+	if lLast := lCalls[0]; !lLast.Done || lLast.Bytes != 16 || lLast.Total != 16 || lLast.URL != "https://x/a.zip" || lLast.ETA > 0 {
+		t.Errorf("last call = %+v", lLast)
+	}
+
+	lCalls = nil
+	io.Copy(io.Discard, progressBody(strings.NewReader("abc"), "u", 0, -1, lOptions))
+	if len(lCalls) != 1 || lCalls[0].Total != -1 || lCalls[0].ETA != -1 {
+		t.Errorf("unknown size: calls = %+v, want Total and ETA of -1", lCalls)
+	}
+
+	lReader := strings.NewReader("abc")
+	if progressBody(lReader, "u", 0, 3, ReadOptions{}) != io.Reader(lReader) {
+		t.Error("the body was wrapped without a hook")
+	}
+}
+
+func TestOnDownload(t *testing.T) {
+	lArchive := zipBytes(t, testFile{"a.csv", strings.Repeat("1,2\n", 1000)})
+	lServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(lArchive)
+	}))
+	defer lServer.Close()
+
+	var lCalls []DownloadProgress
+	lOptions := ReadOptions{OnDownload: func(p DownloadProgress) { lCalls = append(lCalls, p) }, ProgressInterval: time.Nanosecond}
+	if _, lErr := ReadZipWithOptions(lServer.URL+"/a.zip", "", lOptions); lErr != nil {
+		t.Fatal(lErr)
+	}
+	if len(lCalls) == 0 {
+		t.Fatal("OnDownload was not called")
+	}
+	for i := 1; i < len(lCalls); i++ {
+		if lCalls[i].Bytes < lCalls[i-1].Bytes {
+			t.Errorf("bytes went back from %d to %d", lCalls[i-1].Bytes, lCalls[i].Bytes)
+		}
+	}
+	lLast := lCalls[len(lCalls)-1]
+	if !lLast.Done || lLast.Bytes != int64(len(lArchive)) || lLast.Total != int64(len(lArchive)) {
+		t.Errorf("last call = %+v, want Done with %d bytes", lLast, len(lArchive))
+	}
+}
+
+func TestOnParse(t *testing.T) {
+	// x.dat is stored, so that a changed byte is a CRC failure, and its parser stops after 4 bytes,
+	// so that the failure is only seen when the rest of the entry is drained.
+	var lBuffer bytes.Buffer
+	lWriter := zip.NewWriter(&lBuffer)
+	lFiles := []struct {
+		name   string
+		method uint16
+		body   []byte
+	}{
+		{"a.csv", zip.Deflate, []byte("1,2\n3,4\n")},
+		{"bad.zip", zip.Deflate, []byte("not a zip")},
+		{"deep.zip", zip.Deflate, zipBytes(t, testFile{"inner.zip", string(zipBytes(t, testFile{"c.csv", "5,6\n"}))})},
+		{"x.dat", zip.Store, []byte("AAAA" + strings.Repeat("B", 64))},
+	}
+	for _, lFile := range lFiles {
+		lEntry, lErr := lWriter.CreateHeader(&zip.FileHeader{Name: lFile.name, Method: lFile.method})
+		if lErr != nil {
+			t.Fatal(lErr)
+		}
+		lEntry.Write(lFile.body)
+	}
+	lWriter.Close()
+	lArchive := lBuffer.Bytes()
+	lArchive[bytes.Index(lArchive, []byte("AAAABBBB"))+10] = 'C'
+
+	lReader, lErr := zip.NewReader(bytes.NewReader(lArchive), int64(len(lArchive)))
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	var lCalls []ParseProgress
+	lOptions := ReadOptions{
+		MaxDepth: 1,
+		OnParse:  func(p ParseProgress) { lCalls = append(lCalls, p) },
+		Parsers: map[string]EntryParser{".dat": func(pReader io.Reader) ([][]string, error) {
+			lHead := make([]byte, 4)
+			_, lErr := io.ReadFull(pReader, lHead)
+			return [][]string{{string(lHead)}}, lErr
+		}},
+	}
+	if _, lErr := ReadZipEntries(lReader, lOptions); lErr != nil {
+		t.Fatal(lErr)
+	}
+
+	lWant := []struct {
+		path    string
+		entries int
+		rows    int
+		err     error
+	}{
+		{"a.csv", 1, 2, nil},
+		{"bad.zip", 2, 2, errAny},
+		{"deep.zip/inner.zip", 3, 2, ErrNestingTooDeep},
+		{"x.dat", 4, 3, nil},
+		// The checksum failure is reported for the same entry, and its row leaves the total.
+		{"x.dat", 4, 2, zip.ErrChecksum},
+	}
+	if len(lCalls) != len(lWant) {
+		t.Fatalf("calls = %+v, want %d", lCalls, len(lWant))
+	}
+	for i, lCall := range lCalls {
+		lOk := lCall.Path == lWant[i].path && lCall.Entries == lWant[i].entries && lCall.Rows == lWant[i].rows
+		switch lWant[i].err {
+		case nil:
+			lOk = lOk && lCall.Err == nil
+		case errAny:
+			lOk = lOk && lCall.Err != nil
+		default:
+			lOk = lOk && errors.Is(lCall.Err, lWant[i].err)
+		}
+		if !lOk {
+			t.Errorf("calls[%d] = %+v, want %+v", i, lCall, lWant[i])
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package readfiles
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//----------------------------------------------------------- Read Archive ----------------------------------------------------------
+
+// ErrUnknownArchiveFormat is returned when the content is neither a ZIP, a tar nor a gzip stream.
+var ErrUnknownArchiveFormat = errors.New("unknown archive format")
+
+// ReadArchiveFile reads a ZIP, tar, tar.gz or tgz archive from the local file system.
+// The format is detected from the first bytes of the file, not from its extension.
+func ReadArchiveFile(pPath string, pOptions ReadOptions) ([]EntryResult, error) {
+	log.Println("ReadArchiveFile(+)")
+
+	lFile, lErr := os.Open(pPath)
+	if lErr != nil {
+		return nil, fmt.Errorf("ReadArchiveFile:001 %w", lErr)
+	}
+	defer lFile.Close()
+
+	lInfo, lErr := lFile.Stat()
+	if lErr != nil {
+		return nil, fmt.Errorf("ReadArchiveFile:002 %w", lErr)
+	}
+
+	lResults, lErr := ReadArchiveReader(filepath.Base(pPath), lFile, lInfo.Size(), pOptions)
+	if lErr != nil {
+		return lResults, fmt.Errorf("ReadArchiveFile:003 %w", lErr)
+	}
+
+	log.Println("ReadArchiveFile(-)")
+	return lResults, nil
+}
+
+// ReadArchiveUpload reads a ZIP, tar, tar.gz or tgz archive uploaded in the form field pFormName.
+func ReadArchiveUpload(r *http.Request, pFormName string, pOptions ReadOptions) ([]EntryResult, error) {
+	log.Println("ReadArchiveUpload(+)")
+
+	lFile, lHeader, lErr := r.FormFile(pFormName)
+	if lErr != nil {
+		return nil, fmt.Errorf("ReadArchiveUpload:001 %w", lErr)
+	}
+	defer lFile.Close()
+
+	lResults, lErr := ReadArchiveReader(lHeader.Filename, lFile, lHeader.Size, pOptions)
+	if lErr != nil {
+		return lResults, fmt.Errorf("ReadArchiveUpload:002 %w", lErr)
+	}
+
+	log.Println("ReadArchiveUpload(-)")
+	return lResults, nil
+}
+
+// ReadArchiveReader reads the archive held in pReader, pSize bytes long, and returns its supported entries.
+// pName is the archive's own name; it starts the Path of every result and, for a plain .gz file,
+// gives the format of the compressed file.
+
+// Step-by-Step Process:
+// 1. Read the first bytes and detect ZIP, gzip or tar from their magic numbers.
+// 2. For ZIP, open the central directory and read the entries.
+// 3. For tar, stream the entries in order.
+// 4. For gzip, decompress and detect again: a tar stream is read as tar, anything else as one file named without ".gz".
+func ReadArchiveReader(pName string, pReader io.ReaderAt, pSize int64, pOptions ReadOptions) ([]EntryResult, error) {
+	pOptions = pOptions.withBudget()
+
+	lHeader := make([]byte, 512)
+	lCount, lErr := pReader.ReadAt(lHeader, 0)
+	if lErr != nil && lErr != io.EOF {
+		return nil, fmt.Errorf("ReadArchiveReader:001 %w", lErr)
+	}
+
+	switch sniffFormat(lHeader[:lCount]) {
+	case FormatZip:
+		lArchive, lErr := zip.NewReader(pReader, pSize)
+		if lErr != nil {
+			return nil, fmt.Errorf("ReadArchiveReader:002 %w", lErr)
+		}
+		return readZipFiles(pName, lArchive.File, 0, pOptions)
+
+	case FormatTar:
+		return readTarStream(pName, io.NewSectionReader(pReader, 0, pSize), 0, pOptions)
+
+	case FormatGzip:
+		lCompressed := &countingReader{reader: io.NewSectionReader(pReader, 0, pSize)}
+		lInner, lErr := gzip.NewReader(lCompressed)
+		if lErr != nil {
+			return nil, fmt.Errorf("ReadArchiveReader:003 %w", lErr)
+		}
+		defer lInner.Close()
+
+		// Sniff before the limits, so that the peeked tar header is not counted as file data.
+		lBuffered := bufio.NewReaderSize(lInner, 512)
+		lPeek, _ := lBuffered.Peek(512)
+		lLimited := limitEntry(lBuffered, pName, pOptions, func() int64 { return lCompressed.count })
+		if sniffFormat(lPeek) == FormatTar {
+			lLimited.container = true
+			return readTarStream(pName, lLimited, 0, pOptions)
+		}
+
+		lInnerName := strings.TrimSuffix(pName, filepath.Ext(pName))
+		lFormat, lOk := entryFormat(lInnerName, pOptions)
+		if !lOk || lFormat == FormatGzip {
+			return nil, fmt.Errorf("ReadArchiveReader:004 %s: %w", pName, ErrUnknownArchiveFormat)
+		}
+		return readEntry(pName, lInnerName, lFormat, lLimited, -1, 0, pOptions)
+	}
+	return nil, fmt.Errorf("ReadArchiveReader:005 %s: %w", pName, ErrUnknownArchiveFormat)
+}
+
+// sniffFormat detects ZIP, gzip and tar content from its first bytes.
+func sniffFormat(pHeader []byte) FileFormat {
+	switch {
+	case bytes.HasPrefix(pHeader, []byte("PK\x03\x04")), bytes.HasPrefix(pHeader, []byte("PK\x05\x06")),
+		bytes.HasPrefix(pHeader, []byte("PK\x07\x08")):
+		return FormatZip
+	case bytes.HasPrefix(pHeader, []byte{0x1f, 0x8b}):
+		return FormatGzip
+	case len(pHeader) >= 262 && bytes.Equal(pHeader[257:262], []byte("ustar")):
+		return FormatTar
+	}
+	return ""
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,139 @@
+package readfiles
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadArchiveReader(t *testing.T) {
+	lTar := tarBytes(t, testFile{"a.csv", "1,2\n"}, testFile{"docs/b.txt", "3|4\n"}, testFile{"skip.bin", "x"})
+
+	lCases := []struct {
+		name      string
+		data      []byte
+		wantPaths []string
+		wantRows  [][]string
+		wantErr   error
+	}{
+		{
+			name:      "daily.zip",
+			data:      zipBytes(t, testFile{"a.csv", "1,2\n"}, testFile{"docs/b.txt", "3|4\n"}),
+			wantPaths: []string{"daily.zip/a.csv", "daily.zip/docs/b.txt"},
+			wantRows:  [][]string{{"1", "2"}, {"3", "4"}},
+		},
+		{
+			name:      "daily.tar",
+			data:      lTar,
+			wantPaths: []string{"daily.tar/a.csv", "daily.tar/docs/b.txt"},
+			wantRows:  [][]string{{"1", "2"}, {"3", "4"}},
+		},
+		{
+			name:      "daily.tgz",
+			data:      gzipBytes(t, "daily.tar", lTar),
+			wantPaths: []string{"daily.tgz/a.csv", "daily.tgz/docs/b.txt"},
+			wantRows:  [][]string{{"1", "2"}, {"3", "4"}},
+		},
+		{
+			name:      "misnamed.zip",
+			data:      gzipBytes(t, "daily.tar", lTar),
+			wantPaths: []string{"misnamed.zip/a.csv", "misnamed.zip/docs/b.txt"},
+			wantRows:  [][]string{{"1", "2"}, {"3", "4"}},
+		},
+		{
+			name:      "bhav.csv.gz",
+			data:      gzipBytes(t, "bhav.csv", []byte("SYMBOL,QTY\nINFY,10\n")),
+			wantPaths: []string{"bhav.csv.gz"},
+			wantRows:  [][]string{{"SYMBOL", "QTY"}, {"INFY", "10"}},
+		},
+		{
+			name:    "bhav.bin.gz",
+			data:    gzipBytes(t, "bhav.bin", []byte("x")),
+			wantErr: ErrUnknownArchiveFormat,
+		},
+		{
+			name:    "plain.csv",
+			data:    []byte("a,b\n"),
+			wantErr: ErrUnknownArchiveFormat,
+		},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			lResults, lErr := ReadArchiveReader(lCase.name, bytes.NewReader(lCase.data), int64(len(lCase.data)), ReadOptions{})
+			if lCase.wantErr != nil {
+				if !errors.Is(lErr, lCase.wantErr) {
+					t.Fatalf("err = %v, want %v", lErr, lCase.wantErr)
+				}
+				return
+			}
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			var lPaths []string
+			for _, lResult := range lResults {
+				lPaths = append(lPaths, lResult.Path)
+			}
+			if !reflect.DeepEqual(lPaths, lCase.wantPaths) {
+				t.Errorf("paths = %q, want %q", lPaths, lCase.wantPaths)
+			}
+			if lRows := joinEntryRows(lResults); !reflect.DeepEqual(lRows, lCase.wantRows) {
+				t.Errorf("rows = %q, want %q", lRows, lCase.wantRows)
+			}
+		})
+	}
+}
+
+func TestReadArchiveFileAndUpload(t *testing.T) {
+	lData := zipBytes(t, testFile{"a.csv", "1,2\n"})
+
+	lPath := filepath.Join(t.TempDir(), "local.zip")
+	if lErr := os.WriteFile(lPath, lData, 0o644); lErr != nil {
+		t.Fatal(lErr)
+	}
+	lResults, lErr := ReadArchiveFile(lPath, ReadOptions{})
+	if lErr != nil || len(lResults) != 1 || lResults[0].Path != "local.zip/a.csv" {
+		t.Fatalf("ReadArchiveFile = %v, %v", lResults, lErr)
+	}
+	if _, lErr := ReadArchiveFile(filepath.Join(t.TempDir(), "missing.zip"), ReadOptions{}); !errors.Is(lErr, os.ErrNotExist) {
+		t.Errorf("missing file err = %v", lErr)
+	}
+
+	var lBody bytes.Buffer
+	lForm := multipart.NewWriter(&lBody)
+	lPart, _ := lForm.CreateFormFile("archive", "upload.zip")
+	lPart.Write(lData)
+	lForm.Close()
+	lRequest := httptest.NewRequest("POST", "/upload", &lBody)
+	lRequest.Header.Set("Content-Type", lForm.FormDataContentType())
+
+	lResults, lErr = ReadArchiveUpload(lRequest, "archive", ReadOptions{})
+	if lErr != nil || len(lResults) != 1 || lResults[0].Path != "upload.zip/a.csv" {
+		t.Fatalf("ReadArchiveUpload = %v, %v", lResults, lErr)
+	}
+}
+
+func TestSniffFormat(t *testing.T) {
+	lTar := tarBytes(t, testFile{"a.csv", "1\n"})
+	lCases := []struct {
+		name   string
+		header []byte
+		want   FileFormat
+	}{
+		{"zip", []byte("PK\x03\x04rest"), FormatZip},
+		{"empty zip", []byte("PK\x05\x06"), FormatZip},
+		{"gzip", []byte{0x1f, 0x8b, 8}, FormatGzip},
+		{"tar", lTar[:512], FormatTar},
+		{"short", []byte("P"), ""},
+		{"text", []byte("SYMBOL,QTY"), ""},
+	}
+	for _, lCase := range lCases {
+		if lGot := sniffFormat(lCase.header); lGot != lCase.want {
+			t.Errorf("%s: sniffFormat = %q, want %q", lCase.name, lGot, lCase.want)
+		}
+	}
+}
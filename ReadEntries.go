@@ -0,0 +1,272 @@
+package readfiles
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//----------------------------------------------------------- Read Entries ----------------------------------------------------------
+
+// FileFormat is the format detected for an archive entry.
+type FileFormat string
+
+const (
+	FormatCSV  FileFormat = "csv"
+	FormatText FileFormat = "txt"
+	FormatXlsx FileFormat = "xlsx"
+	FormatZip  FileFormat = "zip"
+	FormatGzip FileFormat = "gz"
+	FormatTar  FileFormat = "tar"
+)
+
+// ReadOptions controls how the entries of an archive are read.
+type ReadOptions struct {
+	// StopOnError aborts the archive on the first entry that fails to parse.
+	// When false the error is recorded in that entry's EntryResult and the remaining entries are still read.
+	StopOnError bool
+	// Xlsx is passed to ReadXlsxReader for XLSX entries.
+	Xlsx XlsxReadOptions
+	// MemoryLimit is the largest download processed in memory; larger ones go to a temporary file.
+	// Zero means DefaultMemoryLimit.
+	MemoryLimit int64
+	// HTTP configures the client, headers and cookies used for downloads.
+	HTTP HTTPOptions
+	// Retry controls how often a failed download is retried.
+	Retry RetryPolicy
+	// Cache, if set, keeps downloaded archives on disk and revalidates them with conditional requests.
+	Cache *DownloadCache
+	// Resume keeps partial downloads on disk and continues them with Range requests. It is not used with Cache.
+	Resume ResumeOptions
+	// AcceptContentTypes, if set, lists the only media types accepted for a download.
+	// When empty, any type except HTML is accepted.
+	AcceptContentTypes []string
+	// MaxDepth is how many levels of nested archives (.zip, .tar, .tar.gz inside the archive) are opened.
+	// Zero means DefaultMaxDepth; a negative value skips nested archives without recording them.
+	// An archive below the limit is recorded with ErrNestingTooDeep and the read goes on, even with StopOnError.
+	MaxDepth int
+	// Select chooses the entries to read by glob or regular expression and controls hidden-file skipping.
+	Select EntryFilter
+	// Parsers adds or replaces parsers by file extension, such as ".tsv" or ".dat". The entry's Format is the
+	// extension without the dot, and a registered extension is always parsed as data, even ".zip" or ".gz".
+	Parsers map[string]EntryParser
+	// Limits guards against zip bombs. The zero value sets no limit; use DefaultArchiveLimits for untrusted uploads.
+	Limits ArchiveLimits
+	// Verify checks the digest or signature of a downloaded archive before it is read.
+	Verify VerifyOptions
+	// Password decrypts encrypted ZIP entries (ZipCrypto or WinZip AES-128/192/256).
+	Password string
+	// PasswordFor, if set, gives the password for the entry at pPath, such as "outer.zip/report.csv";
+	// when it returns false, Password is used.
+	PasswordFor func(pPath string) (string, bool)
+	// OnDownload, if set, is called with the progress of the archive download.
+	OnDownload func(DownloadProgress)
+	// OnParse, if set, is called after each entry is parsed or fails with the running entry and row counts.
+	OnParse func(ParseProgress)
+	// ProgressInterval is the smallest gap between two OnDownload calls. Zero means DefaultProgressInterval.
+	ProgressInterval time.Duration
+
+	// budget counts entries and bytes against Limits during one read.
+	budget *archiveBudget
+}
+
+// EntryResult is the parsed content of one archive entry.
+// Name is the entry's name in its own archive, and Path its full path through any nested archives,
+// such as "outer.zip/inner.zip/file.csv". The outermost archive name is included when it is known.
+// Rows holds the CSV/TXT records, or the rows of the first sheet for XLSX entries;
+// Table is the same data split into header and rows. Sheets is set for XLSX entries only.
+// A nested archive that cannot be opened is reported with its own format (zip, gz or tar) and Err.
+type EntryResult struct {
+	Name   string
+	Path   string
+	Format FileFormat
+	Rows   [][]string
+	Table  Table
+	Sheets []XlsxSheetData
+	Err    error
+}
+
+// ReadZipEntries reads every supported entry (CSV, TXT, XLSX) of an open ZIP archive.
+
+// Step-by-Step Process:
+// 1. Loop through the files in the archive and detect the format from the extension, ignoring case.
+// 2. Skip directories, hidden entries, entries rejected by Select and entries whose format is not supported.
+// 3. Parse each supported entry into an EntryResult, opening nested archives up to MaxDepth.
+// 4. On a parse error either stop and return the results so far (StopOnError) or record the error and continue.
+// 5. Stop on the first broken archive limit, whatever StopOnError says.
+func ReadZipEntries(pArchive *zip.Reader, pOptions ReadOptions) ([]EntryResult, error) {
+	return readZipFiles("", pArchive.File, 0, pOptions)
+}
+
+// readZipFiles is ReadZipEntries over a chosen subset of the files of an archive.
+// pPrefix is the path of the archive itself and pDepth its nesting level.
+func readZipFiles(pPrefix string, pFiles []*zip.File, pDepth int, pOptions ReadOptions) ([]EntryResult, error) {
+	var lResults []EntryResult
+	pOptions = pOptions.withBudget()
+
+	for _, lFile := range pFiles {
+		if lFile.FileInfo().IsDir() {
+			continue
+		}
+		lPath := entryPath(pPrefix, lFile.Name)
+		lErr := pOptions.budget.addEntry(lPath)
+		if lErr != nil {
+			return lResults, fmt.Errorf("readZipFiles:003 %w", lErr)
+		}
+		lFormat, lOk := entryFormat(lFile.Name, pOptions)
+		if !lOk {
+			continue
+		}
+		lSelected, lErr := selectEntry(lFile.Name, lFormat, pOptions)
+		if lErr != nil {
+			return lResults, fmt.Errorf("readZipFiles:002 %w", lErr)
+		}
+		if !lSelected {
+			continue
+		}
+
+		lReader, lCompressed, lErr := openZipFile(lFile, lPath, pOptions)
+		if lErr != nil {
+			lFailures, lStop := entryFailure(lPath, lFile.Name, lFormat, fmt.Errorf("readZipFiles:001 %w", lErr), pOptions)
+			lResults = append(lResults, lFailures...)
+			if lStop != nil {
+				return lResults, lStop
+			}
+			continue
+		}
+		lLimited := limitEntry(lReader, lPath, pOptions, lCompressed)
+		lEntries, lErr := readEntry(lPath, lFile.Name, lFormat, lLimited, int64(lFile.UncompressedSize64), pDepth, pOptions)
+		if lErr == nil && len(lEntries) == 1 && lEntries[0].Err == nil && lEntries[0].Path == lPath {
+			// The CRC-32 is only checked at the end of the entry; read what the parser left so that it is.
+			_, lDrainErr := io.Copy(io.Discard, lLimited)
+			if errors.Is(lDrainErr, zip.ErrChecksum) {
+				// The entry was reported as parsed; report the failure again, taking its rows back out of the totals.
+				lDrainErr = fmt.Errorf("readZipFiles:005 %w", lDrainErr)
+				reportParse(lPath, 0, -len(lEntries[0].Rows), lDrainErr, pOptions)
+				lEntries, lErr = failedEntry(lPath, lFile.Name, lFormat, lDrainErr, pOptions)
+			}
+		}
+		lReader.Close()
+		lResults = append(lResults, lEntries...)
+		if lErr != nil {
+			return lResults, lErr
+		}
+		lErr = pOptions.budget.failed()
+		if lErr != nil {
+			return lResults, fmt.Errorf("readZipFiles:004 %w", lErr)
+		}
+	}
+	return lResults, nil
+}
+
+// parseEntry parses pReader according to pResult.Format and stores the rows, table, sheets or error in pResult.
+func parseEntry(pResult *EntryResult, pReader io.Reader, pOptions ReadOptions) {
+	var lErr error
+	lParser, lCustom := pOptions.parser(pResult.Name)
+	switch {
+	case lCustom:
+		pResult.Rows, lErr = lParser(pReader)
+	case pResult.Format == FormatCSV:
+		pResult.Rows, lErr = readDelimitedRows(pReader, ',')
+	case pResult.Format == FormatText:
+		pResult.Rows, lErr = readDelimitedRows(pReader, '|')
+	case pResult.Format == FormatXlsx:
+		pResult.Sheets, lErr = ReadXlsxReader(pReader, pOptions.Xlsx)
+		if len(pResult.Sheets) > 0 {
+			pResult.Rows = pResult.Sheets[0].Rows
+		}
+	default:
+		lErr = fmt.Errorf("unsupported format %q", pResult.Format)
+	}
+	if lErr != nil {
+		pResult.Err = fmt.Errorf("parseEntry:001 %w", lErr)
+		return
+	}
+	pResult.Table = NewTable(pResult.Rows)
+}
+
+// entryFormat detects the format of an entry from its file extension, ignoring case.
+// An extension registered in pOptions.Parsers is reported as its own format.
+func entryFormat(pName string, pOptions ReadOptions) (FileFormat, bool) {
+	lExt := strings.ToLower(filepath.Ext(pName))
+	if _, lCustom := pOptions.parser(pName); lCustom {
+		return FileFormat(strings.TrimPrefix(lExt, ".")), true
+	}
+	switch lExt {
+	case ".csv":
+		return FormatCSV, true
+	case ".txt":
+		return FormatText, true
+	case ".xlsx":
+		return FormatXlsx, true
+	case ".zip":
+		return FormatZip, true
+	case ".gz", ".tgz":
+		return FormatGzip, true
+	case ".tar":
+		return FormatTar, true
+	}
+	return "", false
+}
+
+// entryPath joins an archive path and an entry name with "/".
+func entryPath(pPrefix string, pName string) string {
+	if pPrefix == "" {
+		return pName
+	}
+	return pPrefix + "/" + pName
+}
+
+// readDelimitedRows reads all records of a delimited file.
+// Rows may have different numbers of fields, as report files often do; any other parse error is returned.
+func readDelimitedRows(pReader io.Reader, pComma rune) ([][]string, error) {
+	var lRecord [][]string
+
+	lRows := csv.NewReader(pReader)
+	lRows.Comma = pComma
+	lRows.FieldsPerRecord = -1
+
+	for {
+		lRecordRow, lErr := lRows.Read()
+		if lErr == io.EOF {
+			break
+		}
+		if lErr != nil {
+			return lRecord, lErr
+		}
+		lRecord = append(lRecord, lRecordRow)
+	}
+	return lRecord, nil
+}
+
+// joinEntryRows appends the rows of every successful entry into one 2D array, in archive order.
+func joinEntryRows(pResults []EntryResult) [][]string {
+	var lRows [][]string
+	for _, lResult := range pResults {
+		if lResult.Err == nil {
+			lRows = Join2DArray(lRows, lResult.Rows)
+		}
+	}
+	return lRows
+}
+
+// String returns a short description of the entry, used in logs.
+func (r EntryResult) String() string {
+	var lStatus strings.Builder
+	lName := r.Path
+	if lName == "" {
+		lName = r.Name
+	}
+	fmt.Fprintf(&lStatus, "%s (%s): %d rows", lName, r.Format, len(r.Rows))
+	if r.Err != nil {
+		fmt.Fprintf(&lStatus, ", error: %v", r.Err)
+	}
+	return lStatus.String()
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
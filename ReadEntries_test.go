@@ -0,0 +1,117 @@
+package readfiles
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestReadZipEntries(t *testing.T) {
+	lArchive := zipBytes(t,
+		testFile{"trades.CSV", "SYMBOL,QTY\nINFY,10\n"},
+		testFile{"notes.txt", "a|b|c\n1|2\n"},
+		testFile{"broken.csv", "a,\"b\n"},
+		testFile{"readme.md", "ignored"},
+		testFile{"dir/", ""},
+		testFile{"book.xlsx", string(xlsxFixture(t))},
+	)
+	lReader, lErr := zip.NewReader(bytes.NewReader(lArchive), int64(len(lArchive)))
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+
+	t.Run("record errors", func(t *testing.T) {
+		lResults, lErr := ReadZipEntries(lReader, ReadOptions{})
+		if lErr != nil {
+			t.Fatal(lErr)
+		}
+		lCases := []struct {
+			name   string
+			format FileFormat
+			rows   [][]string
+			failed bool
+		}{
+			{"trades.CSV", FormatCSV, [][]string{{"SYMBOL", "QTY"}, {"INFY", "10"}}, false},
+			{"notes.txt", FormatText, [][]string{{"a", "b", "c"}, {"1", "2"}}, false},
+			{"broken.csv", FormatCSV, nil, true},
+			{"book.xlsx", FormatXlsx, [][]string{{"Title"}, {"SYMBOL", "SECRET", "QTY"}, {"HID", "x", "0"}, {"INFY", "y", "10"}}, false},
+		}
+		if len(lResults) != len(lCases) {
+			t.Fatalf("got %d results, want %d: %v", len(lResults), len(lCases), lResults)
+		}
+		for lIndex, lCase := range lCases {
+			lResult := lResults[lIndex]
+			if lResult.Name != lCase.name || lResult.Format != lCase.format || (lResult.Err != nil) != lCase.failed {
+				t.Errorf("result %d = %v, want %s (%s) failed=%v", lIndex, lResult, lCase.name, lCase.format, lCase.failed)
+			}
+			if !lCase.failed && !reflect.DeepEqual(lResult.Rows, lCase.rows) {
+				t.Errorf("%s rows = %q, want %q", lCase.name, lResult.Rows, lCase.rows)
+			}
+		}
+		if lResults[0].Table.Header[0] != "SYMBOL" || len(lResults[0].Table.Rows) != 1 {
+			t.Errorf("table = %+v", lResults[0].Table)
+		}
+		if len(lResults[3].Sheets) != 2 {
+			t.Errorf("xlsx sheets = %d, want 2", len(lResults[3].Sheets))
+		}
+	})
+
+	t.Run("stop on error", func(t *testing.T) {
+		lResults, lErr := ReadZipEntries(lReader, ReadOptions{StopOnError: true})
+		if lErr == nil {
+			t.Fatal("broken.csv did not stop the read")
+		}
+		if len(lResults) != 3 || lResults[2].Err == nil {
+			t.Fatalf("results = %v, want the first two and the failure", lResults)
+		}
+	})
+}
+
+func TestReadZip(t *testing.T) {
+	lArchive := zipBytes(t, testFile{"a.csv", "1,2\n"}, testFile{"b.txt", "3|4\n"})
+	lServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Write(lArchive)
+	}))
+	defer lServer.Close()
+
+	lRows, lErr := ReadZip(lServer.URL+"/daily.zip", "daily.zip")
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	if lWant := [][]string{{"1", "2"}, {"3", "4"}}; !reflect.DeepEqual(lRows, lWant) {
+		t.Fatalf("rows = %q, want %q", lRows, lWant)
+	}
+
+	lResults, lErr := ReadZipWithOptions(lServer.URL+"/daily.zip", "", ReadOptions{})
+	if lErr != nil || len(lResults) != 2 || lResults[1].Path != "daily.zip/b.txt" {
+		t.Fatalf("results = %v, %v", lResults, lErr)
+	}
+}
+
+func TestEntryFormat(t *testing.T) {
+	lCases := []struct {
+		name   string
+		format FileFormat
+		ok     bool
+	}{
+		{"a.csv", FormatCSV, true},
+		{"A.TXT", FormatText, true},
+		{"b.XlSx", FormatXlsx, true},
+		{"c.zip", FormatZip, true},
+		{"d.tar", FormatTar, true},
+		{"e.tar.gz", FormatGzip, true},
+		{"f.tgz", FormatGzip, true},
+		{"g.xls", "", false},
+		{"noext", "", false},
+	}
+	for _, lCase := range lCases {
+		lFormat, lOk := entryFormat(lCase.name, ReadOptions{})
+		if lFormat != lCase.format || lOk != lCase.ok {
+			t.Errorf("entryFormat(%q) = %q, %v; want %q, %v", lCase.name, lFormat, lOk, lCase.format, lCase.ok)
+		}
+	}
+}
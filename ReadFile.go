@@ -201,7 +201,9 @@ func ReadXlsxFile(r *http.Request, pFile string) error {
 // 3. Append the copied row (lRow) to array1, effectively combining the two arrays.
 // 4. Repeat the process for all rows in array2.
 // 5. The final combined array is returned as the output.
-
+//
+// Join2DArray does not match rows by key; use JoinTables for inner, left, right and full joins on key columns,
+// and UnionTables to stack files whose columns are aligned by header name.
 func Join2DArray(pArray1 [][]string, pArray2 [][]string) [][]string {
 	// Iterate through the rows of array2
 	for ArrayIndex := 0; ArrayIndex < len(pArray2); ArrayIndex++ {
@@ -214,27 +216,33 @@ func Join2DArray(pArray1 [][]string, pArray2 [][]string) [][]string {
 
 // Filter2DArray1 filters a 2D array by searching for a specified start value in the first column.
 // It takes a start value (pStartvalue) and a 2D array (pRows) as input.
-// The function iterates through the rows of the input array to find the first occurrence
-// of the start value in the first column. It then creates a new array that includes all
-// rows following the matching row until it encounters a row with an empty or null first column.
+// The function iterates through the rows of the input array and keeps the last row whose first column
+// contains the start value, compared case-insensitively. It then creates a new array that includes the
+// matching row and the rows following it until it encounters a row with an empty first column.
+// When no row matches, it starts from the first row of the input.
 // The resulting filtered array is returned as the output.
 
 // Step-by-Step Process:
 // 1. Initialize variables: lIndex (to store the index of the matching row) and lNewArr (the filtered array).
-// 2. Iterate through the rows of pRows to find the first occurrence of pStartvalue in the first column.
-// 3. When a match is found, store the index in lIndex.
-// 4. Iterate through the rows of pRows starting from the matching row (lIndex).
-// 5. For each row, check if it has data in the first column and if it's not empty or null.
-// 6. If the conditions are met, append the row to lNewArr.
-// 7. Continue this process until an empty or null value is encountered in the first column.
+// 2. Iterate through the rows of pRows to find occurrences of pStartvalue in the first column.
+// 3. When a match is found, store the index in lIndex, so the last match is kept.
+// 4. Iterate through the rows of pRows starting from the matching row (lIndex), or row 0 without a match.
+// 5. For each row, check if it has data in the first column and if it's not empty.
+// 6. If the conditions are met, append the row to lNewArr; rows without any cells are skipped.
+// 7. Continue this process until an empty value is encountered in the first column.
 // 8. Return the filtered array (lNewArr) as the output.
-
+//
+// Deprecated: Use ExtractSection, which chooses the occurrence explicitly, supports exact, contains and
+// regex matching on any column, has configurable end conditions and reports a missing marker.
+// The equivalent of Filter2DArray1 for a marker that is present is
+// SectionOptions{Marker: pStartvalue, IgnoreCase: true, Occurrence: -1, IncludeMarker: true}, except that
+// ExtractSection ends at a fully blank row rather than at an empty first cell.
 func Filter2DArray1(pStartvalue string, pRows [][]string) [][]string {
 	log.Println("Filter2DArray +")
 	var lIndex int
 	var lNewArr [][]string
 
-	// Iterate through the rows of pRows to find the first occurrence of pStartvalue
+	// Iterate through the rows of pRows to find the last occurrence of pStartvalue
 	for i := 0; i < len(pRows); i++ {
 		if len(pRows[i]) > 0 {
 			if strings.Contains(strings.ToLower(pRows[i][0]), strings.ToLower(pStartvalue)) {
@@ -275,7 +283,9 @@ func Filter2DArray1(pStartvalue string, pRows [][]string) [][]string {
 // 6. Continue storing rows until an empty or null value is encountered in the first column.
 // 7. Repeat this process for all matching rows.
 // 8. Return the filtered array (lNewArr) as the output.
-
+//
+// Deprecated: Use ExtractSections, which returns each block separately with its title, header, data and
+// row range, and never copies a row into two blocks.
 func Filter2DArray2(pStartvalue string, pRows [][]string) [][]string {
 	log.Println("Filter2DArray +")
 	var lIndex []int
@@ -0,0 +1,311 @@
+package readfiles
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+
+	"github.com/xuri/excelize/v2"
+)
+
+//------------------------------------------------------- Read XLSX with options ----------------------------------------------------
+
+// HiddenMode tells the XLSX reader what to do with hidden rows, columns or sheets.
+type HiddenMode int
+
+const (
+	// HiddenInclude reads hidden items like visible ones. This is what GetRows does.
+	HiddenInclude HiddenMode = iota
+	// HiddenSkip leaves hidden items out of the result.
+	HiddenSkip
+	// HiddenFlag keeps hidden items and lists them in the sheet metadata.
+	HiddenFlag
+)
+
+// XlsxReadOptions controls how ReadXlsxReader turns a workbook into rows.
+// The zero value reads every sheet exactly as GetRows returns it.
+type XlsxReadOptions struct {
+	// Sheets lists the sheet names to read. Nil reads every sheet in workbook order.
+	Sheets []string
+	// FillMerged copies the value of each merged region into every cell of the region.
+	FillMerged bool
+	// HiddenRows, HiddenColumns and HiddenSheets choose whether hidden items are kept, skipped or flagged.
+	HiddenRows    HiddenMode
+	HiddenColumns HiddenMode
+	HiddenSheets  HiddenMode
+	// Comments and Hyperlinks collect cell comments and hyperlink targets into the sheet metadata.
+	Comments   bool
+	Hyperlinks bool
+}
+
+// XlsxCellNote is a comment or hyperlink attached to a cell.
+// Cell is the original reference in the workbook (e.g. "B4"). Row and Column are the zero-based
+// position of that cell in XlsxSheetData.Rows, or -1 when the row or column was skipped as hidden.
+type XlsxCellNote struct {
+	Cell   string
+	Row    int
+	Column int
+	Author string
+	Text   string
+}
+
+// XlsxSheetData is one worksheet read by ReadXlsxReader.
+type XlsxSheetData struct {
+	Name   string
+	Hidden bool
+	Rows   [][]string
+	// RowNumbers holds the 1-based workbook row number of each entry in Rows.
+	RowNumbers []int
+	// HiddenRows and HiddenColumns hold the 1-based workbook numbers of hidden rows and columns.
+	// They are filled only in HiddenFlag mode.
+	HiddenRows    []int
+	HiddenColumns []int
+	Comments      []XlsxCellNote
+	// Hyperlinks use XlsxCellNote.Text for the link target.
+	Hyperlinks []XlsxCellNote
+}
+
+// ReadXlsxFromZipWithOptions reads an XLSX file stored in a ZIP archive using pOptions.
+// Unlike ReadXlsxFromZip it is not limited to "Sheet1" and returns one XlsxSheetData per sheet.
+func ReadXlsxFromZipWithOptions(file *zip.File, pOptions XlsxReadOptions) ([]XlsxSheetData, error) {
+	lFile, lErr := file.Open()
+	if lErr != nil {
+		return nil, fmt.Errorf("ReadXlsxFromZipWithOptions:001 %w", lErr)
+	}
+	defer lFile.Close()
+
+	lSheets, lErr := ReadXlsxReader(lFile, pOptions)
+	if lErr != nil {
+		return nil, fmt.Errorf("ReadXlsxFromZipWithOptions:002 %w", lErr)
+	}
+	return lSheets, nil
+}
+
+// ReadXlsxReader reads the workbook in pReader and returns the selected sheets.
+
+// Step-by-Step Process:
+// 1. Open the workbook and work out which sheets to read, skipping or flagging hidden sheets.
+// 2. Get the raw rows of each sheet and fill merged regions if requested.
+// 3. Find hidden rows and columns, and collect comments and hyperlinks with their original cell references.
+// 4. Build the output rows, dropping hidden rows and columns in HiddenSkip mode.
+// 5. Map the comment and hyperlink positions onto the output rows.
+func ReadXlsxReader(pReader io.Reader, pOptions XlsxReadOptions) ([]XlsxSheetData, error) {
+	lBook, lErr := excelize.OpenReader(pReader)
+	if lErr != nil {
+		return nil, fmt.Errorf("ReadXlsxReader:001 %w", lErr)
+	}
+	defer lBook.Close()
+
+	lNames := pOptions.Sheets
+	if lNames == nil {
+		lNames = lBook.GetSheetList()
+	}
+
+	var lResult []XlsxSheetData
+	for _, lName := range lNames {
+		lVisible, lErr := lBook.GetSheetVisible(lName)
+		if lErr != nil {
+			return lResult, fmt.Errorf("ReadXlsxReader:002 %w", lErr)
+		}
+		if !lVisible && pOptions.HiddenSheets == HiddenSkip {
+			continue
+		}
+
+		lSheet, lErr := readXlsxSheet(lBook, lName, pOptions)
+		if lErr != nil {
+			return lResult, fmt.Errorf("ReadXlsxReader:003 %w", lErr)
+		}
+		lSheet.Hidden = !lVisible && pOptions.HiddenSheets == HiddenFlag
+		lResult = append(lResult, lSheet)
+	}
+	return lResult, nil
+}
+
+// readXlsxSheet reads a single sheet according to pOptions.
+func readXlsxSheet(pBook *excelize.File, pName string, pOptions XlsxReadOptions) (XlsxSheetData, error) {
+	lSheet := XlsxSheetData{Name: pName}
+
+	lRows, lErr := pBook.GetRows(pName)
+	if lErr != nil {
+		return lSheet, fmt.Errorf("readXlsxSheet:001 %w", lErr)
+	}
+
+	if pOptions.FillMerged {
+		lRows, lErr = fillXlsxMerged(pBook, pName, lRows)
+		if lErr != nil {
+			return lSheet, fmt.Errorf("readXlsxSheet:002 %w", lErr)
+		}
+	}
+
+	lColumnCount := 0
+	for _, lRow := range lRows {
+		if len(lRow) > lColumnCount {
+			lColumnCount = len(lRow)
+		}
+	}
+
+	// Work out hidden rows and columns, by 1-based workbook number.
+	lHiddenRow := make(map[int]bool)
+	if pOptions.HiddenRows != HiddenInclude {
+		for lRowNo := 1; lRowNo <= len(lRows); lRowNo++ {
+			lVisible, lErr := pBook.GetRowVisible(pName, lRowNo)
+			if lErr != nil {
+				return lSheet, fmt.Errorf("readXlsxSheet:003 %w", lErr)
+			}
+			if !lVisible {
+				lHiddenRow[lRowNo] = true
+				if pOptions.HiddenRows == HiddenFlag {
+					lSheet.HiddenRows = append(lSheet.HiddenRows, lRowNo)
+				}
+			}
+		}
+	}
+	lHiddenColumn := make(map[int]bool)
+	if pOptions.HiddenColumns != HiddenInclude {
+		for lColNo := 1; lColNo <= lColumnCount; lColNo++ {
+			lColName, _ := excelize.ColumnNumberToName(lColNo)
+			lVisible, lErr := pBook.GetColVisible(pName, lColName)
+			if lErr != nil {
+				return lSheet, fmt.Errorf("readXlsxSheet:004 %w", lErr)
+			}
+			if !lVisible {
+				lHiddenColumn[lColNo] = true
+				if pOptions.HiddenColumns == HiddenFlag {
+					lSheet.HiddenColumns = append(lSheet.HiddenColumns, lColNo)
+				}
+			}
+		}
+	}
+	lSkipRow := pOptions.HiddenRows == HiddenSkip
+	lSkipColumn := pOptions.HiddenColumns == HiddenSkip
+
+	// Build the output rows and remember where each workbook row and column ended up.
+	lRowPosition := make(map[int]int)
+	lColumnPosition := make(map[int]int)
+	lPosition := 0
+	for lColNo := 1; lColNo <= lColumnCount; lColNo++ {
+		if lSkipColumn && lHiddenColumn[lColNo] {
+			continue
+		}
+		lColumnPosition[lColNo] = lPosition
+		lPosition++
+	}
+	for lIndex, lRow := range lRows {
+		lRowNo := lIndex + 1
+		if lSkipRow && lHiddenRow[lRowNo] {
+			continue
+		}
+		lOutRow := lRow
+		if lSkipColumn && len(lHiddenColumn) > 0 {
+			lOutRow = nil
+			for lColIndex, lValue := range lRow {
+				if !lHiddenColumn[lColIndex+1] {
+					lOutRow = append(lOutRow, lValue)
+				}
+			}
+		}
+		lRowPosition[lRowNo] = len(lSheet.Rows)
+		lSheet.Rows = append(lSheet.Rows, lOutRow)
+		lSheet.RowNumbers = append(lSheet.RowNumbers, lRowNo)
+	}
+
+	lPlace := func(pCell string) (int, int) {
+		lColNo, lRowNo, lErr := excelize.CellNameToCoordinates(pCell)
+		if lErr != nil {
+			return -1, -1
+		}
+		lRow, lRowOk := lRowPosition[lRowNo]
+		lColumn, lColumnOk := lColumnPosition[lColNo]
+		if !lRowOk {
+			lRow = -1
+		}
+		if !lColumnOk {
+			// Cells past the last populated column still keep their natural position.
+			lColumn = -1
+			if lColNo > lColumnCount && !lSkipColumn {
+				lColumn = lColNo - 1
+			}
+		}
+		return lRow, lColumn
+	}
+
+	if pOptions.Comments {
+		lComments, lErr := pBook.GetComments(pName)
+		if lErr != nil {
+			return lSheet, fmt.Errorf("readXlsxSheet:005 %w", lErr)
+		}
+		for _, lComment := range lComments {
+			lText := lComment.Text
+			if lText == "" {
+				for _, lRun := range lComment.Paragraph {
+					lText += lRun.Text
+				}
+			}
+			lRow, lColumn := lPlace(lComment.Cell)
+			lSheet.Comments = append(lSheet.Comments, XlsxCellNote{
+				Cell: lComment.Cell, Row: lRow, Column: lColumn, Author: lComment.Author, Text: lText,
+			})
+		}
+	}
+
+	if pOptions.Hyperlinks {
+		for lIndex, lRow := range lRows {
+			for lColIndex, lValue := range lRow {
+				if lValue == "" {
+					continue
+				}
+				lCell, _ := excelize.CoordinatesToCellName(lColIndex+1, lIndex+1)
+				lFound, lTarget, lErr := pBook.GetCellHyperLink(pName, lCell)
+				if lErr != nil {
+					return lSheet, fmt.Errorf("readXlsxSheet:006 %w", lErr)
+				}
+				if lFound {
+					lRowPos, lColumnPos := lPlace(lCell)
+					lSheet.Hyperlinks = append(lSheet.Hyperlinks, XlsxCellNote{
+						Cell: lCell, Row: lRowPos, Column: lColumnPos, Text: lTarget,
+					})
+				}
+			}
+		}
+	}
+
+	return lSheet, nil
+}
+
+// fillXlsxMerged copies the value of each merged region into all the cells it covers,
+// growing rows where GetRows trimmed the trailing blanks of a region.
+func fillXlsxMerged(pBook *excelize.File, pName string, pRows [][]string) ([][]string, error) {
+	lMerged, lErr := pBook.GetMergeCells(pName)
+	if lErr != nil {
+		return pRows, lErr
+	}
+
+	for _, lRegion := range lMerged {
+		lStartCol, lStartRow, lErr := excelize.CellNameToCoordinates(lRegion.GetStartAxis())
+		if lErr != nil {
+			return pRows, lErr
+		}
+		lEndCol, lEndRow, lErr := excelize.CellNameToCoordinates(lRegion.GetEndAxis())
+		if lErr != nil {
+			return pRows, lErr
+		}
+		lValue := lRegion.GetCellValue()
+
+		for lRowNo := lStartRow; lRowNo <= lEndRow; lRowNo++ {
+			for len(pRows) < lRowNo {
+				pRows = append(pRows, nil)
+			}
+			lRow := pRows[lRowNo-1]
+			for len(lRow) < lEndCol {
+				lRow = append(lRow, "")
+			}
+			for lColNo := lStartCol; lColNo <= lEndCol; lColNo++ {
+				lRow[lColNo-1] = lValue
+			}
+			pRows[lRowNo-1] = lRow
+		}
+	}
+	return pRows, nil
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,136 @@
+package readfiles
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// xlsxFixture builds a workbook with a merged title, a hidden row, a hidden column, a comment,
+// a hyperlink and a hidden second sheet.
+func xlsxFixture(t *testing.T) []byte {
+	t.Helper()
+	lBook := excelize.NewFile()
+	defer lBook.Close()
+
+	lRows := [][]interface{}{
+		{"Title"},
+		{"SYMBOL", "SECRET", "QTY"},
+		{"HID", "x", "0"},
+		{"INFY", "y", "10"},
+	}
+	for lIndex, lRow := range lRows {
+		lCell, _ := excelize.CoordinatesToCellName(1, lIndex+1)
+		if lErr := lBook.SetSheetRow("Sheet1", lCell, &lRow); lErr != nil {
+			t.Fatal(lErr)
+		}
+	}
+	lSteps := []error{
+		lBook.MergeCell("Sheet1", "A1", "C1"),
+		lBook.SetRowVisible("Sheet1", 3, false),
+		lBook.SetColVisible("Sheet1", "B", false),
+		lBook.AddComment("Sheet1", excelize.Comment{Cell: "C4", Author: "ops", Text: "checked"}),
+		lBook.SetCellHyperLink("Sheet1", "A4", "https://example.com/INFY", "External"),
+	}
+	_, lErr := lBook.NewSheet("Secret")
+	lSteps = append(lSteps, lErr, lBook.SetCellValue("Secret", "A1", "s"), lBook.SetSheetVisible("Secret", false))
+	for _, lErr := range lSteps {
+		if lErr != nil {
+			t.Fatal(lErr)
+		}
+	}
+
+	var lBuffer bytes.Buffer
+	if lErr := lBook.Write(&lBuffer); lErr != nil {
+		t.Fatal(lErr)
+	}
+	return lBuffer.Bytes()
+}
+
+func TestReadXlsxReader(t *testing.T) {
+	lData := xlsxFixture(t)
+
+	lCases := []struct {
+		name       string
+		options    XlsxReadOptions
+		sheets     []string
+		rows       [][]string
+		rowNumbers []int
+		hiddenRows []int
+		hiddenCols []int
+		comments   []XlsxCellNote
+		links      []XlsxCellNote
+	}{
+		{
+			name:       "defaults read everything as GetRows does",
+			options:    XlsxReadOptions{},
+			sheets:     []string{"Sheet1", "Secret"},
+			rows:       [][]string{{"Title"}, {"SYMBOL", "SECRET", "QTY"}, {"HID", "x", "0"}, {"INFY", "y", "10"}},
+			rowNumbers: []int{1, 2, 3, 4},
+		},
+		{
+			name:       "fill merged and skip hidden",
+			options:    XlsxReadOptions{FillMerged: true, HiddenRows: HiddenSkip, HiddenColumns: HiddenSkip, HiddenSheets: HiddenSkip, Comments: true, Hyperlinks: true},
+			sheets:     []string{"Sheet1"},
+			rows:       [][]string{{"Title", "Title"}, {"SYMBOL", "QTY"}, {"INFY", "10"}},
+			rowNumbers: []int{1, 2, 4},
+			comments:   []XlsxCellNote{{Cell: "C4", Row: 2, Column: 1, Author: "ops", Text: "checked"}},
+			links:      []XlsxCellNote{{Cell: "A4", Row: 2, Column: 0, Text: "https://example.com/INFY"}},
+		},
+		{
+			name:       "flag hidden keeps positions",
+			options:    XlsxReadOptions{Sheets: []string{"Sheet1"}, HiddenRows: HiddenFlag, HiddenColumns: HiddenFlag, Comments: true},
+			sheets:     []string{"Sheet1"},
+			rows:       [][]string{{"Title"}, {"SYMBOL", "SECRET", "QTY"}, {"HID", "x", "0"}, {"INFY", "y", "10"}},
+			rowNumbers: []int{1, 2, 3, 4},
+			hiddenRows: []int{3},
+			hiddenCols: []int{2},
+			comments:   []XlsxCellNote{{Cell: "C4", Row: 3, Column: 2, Author: "ops", Text: "checked"}},
+		},
+	}
+
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			lSheets, lErr := ReadXlsxReader(bytes.NewReader(lData), lCase.options)
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			var lNames []string
+			for _, lSheet := range lSheets {
+				lNames = append(lNames, lSheet.Name)
+			}
+			if !reflect.DeepEqual(lNames, lCase.sheets) {
+				t.Fatalf("sheets = %v, want %v", lNames, lCase.sheets)
+			}
+
+			lSheet := lSheets[0]
+			if !reflect.DeepEqual(lSheet.Rows, lCase.rows) {
+				t.Errorf("rows = %q, want %q", lSheet.Rows, lCase.rows)
+			}
+			if !reflect.DeepEqual(lSheet.RowNumbers, lCase.rowNumbers) {
+				t.Errorf("row numbers = %v, want %v", lSheet.RowNumbers, lCase.rowNumbers)
+			}
+			if !reflect.DeepEqual(lSheet.HiddenRows, lCase.hiddenRows) || !reflect.DeepEqual(lSheet.HiddenColumns, lCase.hiddenCols) {
+				t.Errorf("hidden rows %v columns %v, want %v %v", lSheet.HiddenRows, lSheet.HiddenColumns, lCase.hiddenRows, lCase.hiddenCols)
+			}
+			if !reflect.DeepEqual(lSheet.Comments, lCase.comments) {
+				t.Errorf("comments = %+v, want %+v", lSheet.Comments, lCase.comments)
+			}
+			if !reflect.DeepEqual(lSheet.Hyperlinks, lCase.links) {
+				t.Errorf("hyperlinks = %+v, want %+v", lSheet.Hyperlinks, lCase.links)
+			}
+		})
+	}
+}
+
+func TestReadXlsxHiddenSheetFlag(t *testing.T) {
+	lSheets, lErr := ReadXlsxReader(bytes.NewReader(xlsxFixture(t)), XlsxReadOptions{HiddenSheets: HiddenFlag})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	if len(lSheets) != 2 || lSheets[0].Hidden || !lSheets[1].Hidden {
+		t.Fatalf("hidden flags = %v, %v; want only Secret hidden", lSheets[0].Hidden, lSheets[1].Hidden)
+	}
+}
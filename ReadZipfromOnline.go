@@ -6,9 +6,6 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"net/http"
-	"os"
-	"path/filepath"
 
 	"github.com/xuri/excelize/v2" 
 )
@@ -16,127 +13,64 @@ import (
 //----------------------------------------------------------- Read ZIP --------------------------------------------------------------
 
 // ReadZip is a function that downloads a ZIP file from a given URL, extracts its contents, and processes supported file types (CSV, TXT, XLSX).
+// The rows of every entry are appended together, in archive order, and returned. The first entry that fails to parse aborts the read.
+// Use ReadZipWithOptions to get the entries separately.
+func ReadZip(pUrl string, pFilename string) ([][]string, error) {
+	lResults, lErr := ReadZipWithOptions(pUrl, pFilename, ReadOptions{StopOnError: true})
+	return joinEntryRows(lResults), lErr
+}
+
+// ReadZipWithOptions downloads a ZIP file from a given URL and returns the parsed content of each supported entry (CSV, TXT, XLSX).
+// Tar, tar.gz and tgz archives are read the same way; the format is detected from the content, not the URL.
+// Nothing is written to the working directory: pFilename only names the temporary file used for large downloads,
+// which is private to the call and always removed, so concurrent calls with the same name are safe.
 
 // Step 1: Initialize variables and data structures
-// Step 2: Prepare and send an HTTP GET request to the specified URL
-// Step 3: Receive the HTTP response
-// Step 4: Check for errors in the HTTP request
-// Step 5: Create a local ZIP file and write the response body to it
-// Step 6: Check for errors in local file creation
-// Step 7: Copy the response body to the local ZIP file
-// Step 8: Read and process the contents of the ZIP file
-// Step 9: Check for errors in ZIP file opening
-// Step 10: Loop through files within the ZIP
-// Step 11: Read and process supported file types (CSV, TXT, XLSX) within the ZIP
-// Step 12: Log and return the result
+// Step 2: Download the archive, retrying network errors, 429 and 5xx responses. The body is kept in memory,
+//         in a temporary file above pOptions.MemoryLimit, or in pOptions.Cache when one is set, then checked against pOptions.Verify
+// Step 3: Check the final response status and Content-Type
+// Step 4: Detect the archive format (ZIP, tar, tar.gz/tgz) from its magic bytes
+// Step 5: Read the supported entries (CSV, TXT, XLSX) within the archive and any nested .zip/.tar/.gz archives
+// Step 6: Check for errors in opening the archive
+// Step 7: Log and return the per-entry results
 
 // Step 1: Initialize variables and data structures
-func ReadZip(pUrl string, pFilename string) ([][]string, error) {
+func ReadZipWithOptions(pUrl string, pFilename string, pOptions ReadOptions) ([]EntryResult, error) {
 	log.Println("ReadZip(+)")
 
-	// Initialize a slice to store the extracted data
-	var lFileData [][]string
-
-	// Define the ZIP file name
-	lZipFileName := pFilename
-
-	// Create an HTTP client and prepare a GET request to the provided URL
-	lClient := http.DefaultClient
-	lRequest, lErr := http.NewRequest(http.MethodGet, pUrl, nil)
-
-	// Step 2: Check for errors in request creation
-	if lErr != nil {
-		return lFileData, fmt.Errorf("ReadZip:001" + lErr.Error())
-	}
-
-	// Set HTTP headers for the request
-	lRequest.Header.Set("User-Agent", "PostmanRuntime/7.26.10")
-	lRequest.Header.Set("Accept", "*/*")
-	lRequest.Header.Set("Accept-Encoding", "gzip, deflate, br")
-	lRequest.Header.Set("Connection", "keep-alive")
-
-	// Step 3: Send the HTTP request and get the response
-	lResponse, lErr := lClient.Do(lRequest)
-
-	// Step 4: Check for errors in the HTTP request
-	if lErr != nil {
-		return lFileData, fmt.Errorf("ReadZip:002" + lErr.Error())
-	}
-
-	// Ensure the response body is closed when done
-	defer lResponse.Body.Close()
+	// Initialize a slice to store the per-entry results
+	var lResults []EntryResult
 
-	// Step 5: Create a local ZIP file and write the response body to it
-	out, lErr := os.Create(lZipFileName)
+	// Step 2: Download the archive, retrying transient failures, into memory, a temporary file or the cache
+	lDownload, lErr := downloadArchive(pUrl, pFilename, pOptions)
 
-	// Step 6: Check for errors when creating the local ZIP file
+	// Step 3: Check for errors in the download; a *DownloadError describes a failed HTTP request
 	if lErr != nil {
-		return lFileData, fmt.Errorf("ReadZip:003" + lErr.Error())
+		return lResults, fmt.Errorf("ReadZip:001 %w", lErr)
 	}
 
-	// Ensure the local ZIP file is closed when done
-	defer out.Close()
+	// Ensure the download is released (and its temporary file removed) when done
+	defer lDownload.Close()
 
-	// Write the response body to the local ZIP file
-	_, lErr = io.Copy(out, lResponse.Body)
-
-	// Step 7: Check for errors when copying the ZIP file
-	if lErr != nil {
-		return lFileData, fmt.Errorf("ReadZip:004" + lErr.Error())
+	// Step 4: Detect the archive format (ZIP, tar, tar.gz/tgz or a single .gz file) from its first bytes
+	// Step 5: Read the supported entries, including nested archives; entry paths start with the archive name
+	lArchiveName := pFilename
+	if lArchiveName == "" {
+		lArchiveName = remoteArchiveName(pUrl)
 	}
+	lResults, lErr = ReadArchiveReader(lArchiveName, lDownload.ReaderAt(), lDownload.Size(), pOptions)
 
-	// Step 8: Read and process the contents of the ZIP file
-	lZipFile, lErr := zip.OpenReader(lZipFileName)
-
-	// Step 9: Check for errors when opening the ZIP file
+	// Step 6: Check for errors when opening the archive or, with StopOnError, reading an entry
 	if lErr != nil {
-		return lFileData, fmt.Errorf("ReadZip:005" + lErr.Error())
+		return lResults, fmt.Errorf("ReadZip:002 %w", lErr)
 	}
 
-	// Ensure the ZIP file is closed when done
-	defer lZipFile.Close()
-
-	// Step 10: Loop through files within the ZIP
-	for _, lFile := range lZipFile.File {
-		if filepath.Ext(lFile.Name) == ".csv" {
-			// Read and process the CSV file
-			lData, lErr := ReadCsvFromZip(lFile)
-
-			// Step 11: Check for errors when reading CSV files
-			if lErr != nil {
-				return lFileData, fmt.Errorf("ReadZip:006" + lErr.Error())
-			} else {
-				// Use conditions to filter your records if needed
-				log.Println(lData)
-			}
-		} else if filepath.Ext(lFile.Name) == ".txt" {
-			// Read and process the Text file
-			lData, lErr := ReadTextFromZip(lFile)
-
-			// Step 11: Check for errors when reading TXT files
-			if lErr != nil {
-				return lFileData, fmt.Errorf("ReadZip:006" + lErr.Error())
-			} else {
-				// Use conditions to filter your records if needed
-				log.Println(lData)
-			}
-		} else if filepath.Ext(lFile.Name) == ".xlsx" {
-			// Read and process the XLSX file
-			lData, lErr := ReadXlsxFromZip(lFile)
-
-			// Step 11: Check for errors when reading XLSX files
-			if lErr != nil {
-				return lFileData, fmt.Errorf("ReadZip:006" + lErr.Error())
-			} else {
-				// Use conditions to filter your records if needed
-				log.Println(lData)
-			}
-		}
+	// Step 7: Log and return the per-entry results
+	for _, lResult := range lResults {
+		log.Println(lResult)
 	}
-
-	// Step 12: Log and return the result
 	log.Println("ReadZip(-)")
-	return lFileData, lErr
+	return lResults, nil
 }
 
 //----------------------------------------------------------------------------------------------------------------------------------
@@ -265,17 +199,17 @@ func ReadXlsxFromZip(file *zip.File) ([][]string, error) {
 	// Step 4: Initialize an empty 2D string array to store the XLSX data
 	var lRecord [][]string
 
-	// Step 5: Create a new XLSX file from the opened file
+	// Step 4: Create a new XLSX file from the opened file
 	xlsxFile, err := excelize.OpenReader(lFile)
 	if err != nil {
-		// Step 7: If there is an error, return an empty 2D string array and an error with an informative message
+		// Step 4: If there is an error, return an empty 2D string array and an error with an informative message
 		return nil, fmt.Errorf("ReadXlsxFromZip: Failed to open XLSX file: %v", err)
 	}
 
-	// Step 8: Specify the tab name in the XLSX file you want to read
+	// Step 5: Specify the tab name in the XLSX file you want to read
 	tabName := "Sheet1" // Replace with your desired tab name
 
-	// Step 9: Get all the rows from the specified tab and append them to the 2D string array
+	// Step 6: Get all the rows from the specified tab and append them to the 2D string array
 	rows, err := xlsxFile.GetRows(tabName)
 	if err != nil {
 		// Step 11: If there is an error, return an empty 2D string array and an error with an informative message
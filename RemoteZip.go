@@ -0,0 +1,339 @@
+package readfiles
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//----------------------------------------------------------- Remote ZIP ------------------------------------------------------------
+
+// ErrRangeNotSupported is returned by NewHTTPReaderAt when the server does not answer Range requests.
+var ErrRangeNotSupported = errors.New("server does not support byte ranges")
+
+// ErrRemoteFileChanged is returned by HTTPReaderAt when the remote file was replaced after it was opened,
+// so that blocks of two versions are never mixed.
+var ErrRemoteFileChanged = errors.New("remote file changed while it was being read")
+
+// HTTPReaderAt is an io.ReaderAt over a remote file, backed by HTTP Range requests.
+// Reads are served from fixed-size blocks that are kept in a small least-recently-used cache,
+// so zip.NewReader can walk the central directory with only a few requests. It is safe for concurrent use.
+// Block requests carry the ETag or Last-Modified seen when the file was opened as If-Range, and fail with
+// ErrRemoteFileChanged when the server reports a different version.
+type HTTPReaderAt struct {
+	url          string
+	client       *http.Client
+	options      ReadOptions
+	size         int64
+	blockSize    int64
+	maxBlocks    int
+	etag         string
+	lastModified string
+
+	mu       sync.Mutex
+	blocks   map[int64][]byte
+	lastUsed map[int64]int64
+	useCount int64
+	requests int
+}
+
+// RemoteZipOptions tunes the block cache of an HTTPReaderAt.
+type RemoteZipOptions struct {
+	// BlockSize is the number of bytes fetched per request. Zero means 256 KiB.
+	BlockSize int64
+	// MaxBlocks is the number of blocks kept in memory. Zero means 64.
+	MaxBlocks int
+}
+
+// ZipEntryInfo describes one entry of a remote ZIP archive.
+type ZipEntryInfo struct {
+	Name             string
+	CompressedSize   uint64
+	UncompressedSize uint64
+	Modified         time.Time
+	IsDir            bool
+}
+
+// NewHTTPReaderAt opens pUrl for random access. It asks for the first byte to learn the file size
+// and returns ErrRangeNotSupported when the server ignores the Range header. An empty remote file,
+// which a server answers with 416 and "Content-Range: bytes */0" or with an empty 200, opens with size 0.
+func NewHTTPReaderAt(pUrl string, pOptions ReadOptions, pRemote RemoteZipOptions) (*HTTPReaderAt, error) {
+	lClient, lErr := newSessionClient(pOptions.HTTP)
+	if lErr != nil {
+		return nil, fmt.Errorf("NewHTTPReaderAt:001 %w", lErr)
+	}
+
+	lReader := &HTTPReaderAt{
+		url:       pUrl,
+		client:    lClient,
+		options:   pOptions,
+		blockSize: pRemote.BlockSize,
+		maxBlocks: pRemote.MaxBlocks,
+		blocks:    make(map[int64][]byte),
+		lastUsed:  make(map[int64]int64),
+	}
+	if lReader.blockSize <= 0 {
+		lReader.blockSize = 256 << 10
+	}
+	if lReader.maxBlocks <= 0 {
+		lReader.maxBlocks = 64
+	}
+
+	lResponse, lErr := lReader.get(0, 0)
+	if lErr != nil {
+		if emptyRemoteFile(lErr) {
+			return lReader, nil
+		}
+		return nil, fmt.Errorf("NewHTTPReaderAt:002 %w", lErr)
+	}
+	defer lResponse.Body.Close()
+	if lResponse.StatusCode == http.StatusOK && lResponse.ContentLength == 0 {
+		return lReader, nil
+	}
+	if lResponse.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("NewHTTPReaderAt:003 %s: %w", pUrl, ErrRangeNotSupported)
+	}
+	_, lTotal, lOk := parseContentRange(lResponse.Header.Get("Content-Range"))
+	if !lOk || lTotal < 0 {
+		return nil, fmt.Errorf("NewHTTPReaderAt:004 %s: unknown size in Content-Range %q", pUrl, lResponse.Header.Get("Content-Range"))
+	}
+	lReader.size = lTotal
+	// A weak ETag cannot be used with If-Range, so Last-Modified is the validator then.
+	if lETag := lResponse.Header.Get("ETag"); !strings.HasPrefix(lETag, "W/") {
+		lReader.etag = lETag
+	}
+	lReader.lastModified = lResponse.Header.Get("Last-Modified")
+	return lReader, nil
+}
+
+// Size returns the length of the remote file.
+func (r *HTTPReaderAt) Size() int64 {
+	return r.size
+}
+
+// Requests returns the number of Range requests made so far, including the initial size probe.
+func (r *HTTPReaderAt) Requests() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *HTTPReaderAt) ReadAt(p []byte, pOffset int64) (int, error) {
+	if pOffset < 0 {
+		return 0, fmt.Errorf("HTTPReaderAt: negative offset %d", pOffset)
+	}
+
+	lRead := 0
+	for lRead < len(p) {
+		lPosition := pOffset + int64(lRead)
+		if lPosition >= r.size {
+			return lRead, io.EOF
+		}
+		lIndex := lPosition / r.blockSize
+		lBlock, lErr := r.block(lIndex)
+		if lErr != nil {
+			return lRead, lErr
+		}
+		lRead += copy(p[lRead:], lBlock[lPosition-lIndex*r.blockSize:])
+	}
+	return lRead, nil
+}
+
+// emptyRemoteFile reports whether the size probe failed because the remote file has no bytes at all.
+func emptyRemoteFile(pErr error) bool {
+	var lFailure *DownloadError
+	if !errors.As(pErr, &lFailure) || lFailure.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		return false
+	}
+	return strings.TrimSpace(lFailure.Header.Get("Content-Range")) == "bytes */0"
+}
+
+// block returns block number pIndex from the cache, fetching it if needed.
+// The lock is not held during the request, so several blocks can be fetched at once;
+// two readers missing the same block may both fetch it.
+func (r *HTTPReaderAt) block(pIndex int64) ([]byte, error) {
+	r.mu.Lock()
+	r.useCount++
+	if lBlock, lOk := r.blocks[pIndex]; lOk {
+		r.lastUsed[pIndex] = r.useCount
+		r.mu.Unlock()
+		return lBlock, nil
+	}
+	r.mu.Unlock()
+
+	lStart := pIndex * r.blockSize
+	lEnd := lStart + r.blockSize - 1
+	if lEnd >= r.size {
+		lEnd = r.size - 1
+	}
+	lResponse, lErr := r.get(lStart, lEnd)
+	if lErr != nil {
+		return nil, lErr
+	}
+	defer lResponse.Body.Close()
+	if lResponse.StatusCode == http.StatusOK && (r.etag != "" || r.lastModified != "") {
+		// The If-Range validator no longer matched, so the server sent the whole new file.
+		return nil, fmt.Errorf("HTTPReaderAt: %s: %w", r.url, ErrRemoteFileChanged)
+	}
+	if lResponse.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("HTTPReaderAt: %s answered HTTP %d to a Range request: %w", r.url, lResponse.StatusCode, ErrRangeNotSupported)
+	}
+	if !r.sameVersion(lResponse.Header) {
+		return nil, fmt.Errorf("HTTPReaderAt: %s: %w", r.url, ErrRemoteFileChanged)
+	}
+	lBlock := make([]byte, lEnd-lStart+1)
+	_, lErr = io.ReadFull(lResponse.Body, lBlock)
+	if lErr != nil {
+		return nil, fmt.Errorf("HTTPReaderAt: %w", lErr)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.useCount++
+	if lCached, lOk := r.blocks[pIndex]; lOk {
+		r.lastUsed[pIndex] = r.useCount
+		return lCached, nil
+	}
+	if len(r.blocks) >= r.maxBlocks {
+		var lOldest int64 = -1
+		for lKey, lUsed := range r.lastUsed {
+			if lOldest < 0 || lUsed < r.lastUsed[lOldest] {
+				lOldest = lKey
+			}
+		}
+		delete(r.blocks, lOldest)
+		delete(r.lastUsed, lOldest)
+	}
+	r.blocks[pIndex] = lBlock
+	r.lastUsed[pIndex] = r.useCount
+	return lBlock, nil
+}
+
+// sameVersion reports whether a block response carries the validators seen when the file was opened.
+// A server ignoring If-Range still gives itself away by sending a different ETag or Last-Modified.
+func (r *HTTPReaderAt) sameVersion(pHeader http.Header) bool {
+	if r.etag != "" {
+		return pHeader.Get("ETag") == r.etag
+	}
+	return r.lastModified == "" || pHeader.Get("Last-Modified") == r.lastModified
+}
+
+// get requests bytes pStart to pEnd (inclusive) of the remote file, conditional on the version seen at open.
+func (r *HTTPReaderAt) get(pStart, pEnd int64) (*http.Response, error) {
+	lHeaders := make(http.Header)
+	lHeaders.Set("Range", "bytes="+strconv.FormatInt(pStart, 10)+"-"+strconv.FormatInt(pEnd, 10))
+	lHeaders.Set("Accept-Encoding", "identity")
+	if r.etag != "" {
+		lHeaders.Set("If-Range", r.etag)
+	} else if r.lastModified != "" {
+		lHeaders.Set("If-Range", r.lastModified)
+	}
+	r.mu.Lock()
+	r.requests++
+	r.mu.Unlock()
+	return fetchWithClient(r.client, r.url, lHeaders, r.options)
+}
+
+// ListRemoteZip returns the entries of the ZIP archive at pUrl without downloading the whole archive.
+func ListRemoteZip(pUrl string) ([]ZipEntryInfo, error) {
+	return ListRemoteZipWithOptions(pUrl, ReadOptions{}, RemoteZipOptions{})
+}
+
+// ListRemoteZipWithOptions is ListRemoteZip with download and block cache options.
+func ListRemoteZipWithOptions(pUrl string, pOptions ReadOptions, pRemote RemoteZipOptions) ([]ZipEntryInfo, error) {
+	lArchive, lReader, lErr := openRemoteZip(pUrl, pOptions, pRemote)
+	if lErr != nil {
+		return nil, fmt.Errorf("ListRemoteZip:001 %w", lErr)
+	}
+
+	var lEntries []ZipEntryInfo
+	for _, lFile := range lArchive.File {
+		lEntries = append(lEntries, ZipEntryInfo{
+			Name:             lFile.Name,
+			CompressedSize:   lFile.CompressedSize64,
+			UncompressedSize: lFile.UncompressedSize64,
+			Modified:         lFile.Modified,
+			IsDir:            lFile.FileInfo().IsDir(),
+		})
+	}
+	log.Println("ListRemoteZip:", len(lEntries), "entries in", lReader.Requests(), "requests")
+	return lEntries, nil
+}
+
+// ReadRemoteZipEntries reads only the named entries of the remote ZIP archive at pUrl.
+// Only the central directory and the bytes of those entries are downloaded. A nil pNames reads every supported entry.
+
+// Step-by-Step Process:
+// 1. Open the archive over an HTTPReaderAt, which reads the central directory with Range requests.
+// 2. Pick the entries listed in pNames; a name that is not in the archive is an error.
+// 3. Read the picked entries through the usual CSV/TXT/XLSX pipeline.
+func ReadRemoteZipEntries(pUrl string, pNames []string, pOptions ReadOptions, pRemote RemoteZipOptions) ([]EntryResult, error) {
+	log.Println("ReadRemoteZipEntries(+)")
+
+	lArchive, lReader, lErr := openRemoteZip(pUrl, pOptions, pRemote)
+	if lErr != nil {
+		return nil, fmt.Errorf("ReadRemoteZipEntries:001 %w", lErr)
+	}
+
+	lFiles := lArchive.File
+	if pNames != nil {
+		lByName := make(map[string]*zip.File, len(lArchive.File))
+		for _, lFile := range lArchive.File {
+			lByName[lFile.Name] = lFile
+		}
+		lFiles = nil
+		for _, lName := range pNames {
+			lFile, lOk := lByName[lName]
+			if !lOk {
+				return nil, fmt.Errorf("ReadRemoteZipEntries:002 entry %q not found in %s", lName, pUrl)
+			}
+			lFiles = append(lFiles, lFile)
+		}
+	}
+
+	lResults, lErr := readZipFiles(remoteArchiveName(pUrl), lFiles, 0, pOptions)
+	if lErr != nil {
+		return lResults, fmt.Errorf("ReadRemoteZipEntries:003 %w", lErr)
+	}
+
+	log.Println("ReadRemoteZipEntries(-)", lReader.Requests(), "requests")
+	return lResults, nil
+}
+
+// remoteArchiveName returns the file name at the end of a URL path, used as the first part of entry paths.
+func remoteArchiveName(pUrl string) string {
+	lParsed, lErr := url.Parse(pUrl)
+	if lErr != nil {
+		return ""
+	}
+	lName := path.Base(lParsed.Path)
+	if lName == "." || lName == "/" {
+		return ""
+	}
+	return lName
+}
+
+// openRemoteZip opens the ZIP archive at pUrl over an HTTPReaderAt.
+func openRemoteZip(pUrl string, pOptions ReadOptions, pRemote RemoteZipOptions) (*zip.Reader, *HTTPReaderAt, error) {
+	lReader, lErr := NewHTTPReaderAt(pUrl, pOptions, pRemote)
+	if lErr != nil {
+		return nil, nil, lErr
+	}
+	lArchive, lErr := zip.NewReader(lReader, lReader.Size())
+	if lErr != nil {
+		return nil, nil, lErr
+	}
+	return lArchive, lReader, nil
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
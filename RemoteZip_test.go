@@ -0,0 +1,286 @@
+package readfiles
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rangeServer serves pContent with http.ServeContent, which answers Range requests.
+func rangeServer(pContent []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(pContent))
+	}))
+}
+
+func TestNewHTTPReaderAt(t *testing.T) {
+	lContent := []byte(strings.Repeat("abcdefghij", 10))
+	lCases := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantSize   int64
+		wantErr    error
+		wantStatus int
+	}{
+		{
+			name: "ranges supported",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(lContent))
+			},
+			wantSize: 100,
+		},
+		{
+			name: "empty file answered with 200",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(nil))
+			},
+			wantSize: 0,
+		},
+		{
+			name: "empty file answered with 416",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Range", "bytes */0")
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			},
+			wantSize: 0,
+		},
+		{
+			name:    "no Accept-Ranges",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.Write(lContent) },
+			wantErr: ErrRangeNotSupported,
+		},
+		{
+			name: "416 for a non-empty file",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Range", "bytes */100")
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			},
+			wantStatus: http.StatusRequestedRangeNotSatisfiable,
+		},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			lServer := httptest.NewServer(lCase.handler)
+			defer lServer.Close()
+
+			lReader, lErr := NewHTTPReaderAt(lServer.URL, ReadOptions{Retry: fastRetry}, RemoteZipOptions{})
+			if lCase.wantErr != nil {
+				if !errors.Is(lErr, lCase.wantErr) {
+					t.Fatalf("err = %v, want %v", lErr, lCase.wantErr)
+				}
+				return
+			}
+			if lCase.wantStatus != 0 {
+				var lFailure *DownloadError
+				if !errors.As(lErr, &lFailure) || lFailure.StatusCode != lCase.wantStatus {
+					t.Fatalf("err = %v, want HTTP %d", lErr, lCase.wantStatus)
+				}
+				return
+			}
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			if lReader.Size() != lCase.wantSize {
+				t.Errorf("size = %d, want %d", lReader.Size(), lCase.wantSize)
+			}
+			if _, lErr := lReader.ReadAt(make([]byte, 1), lCase.wantSize); lErr != io.EOF {
+				t.Errorf("read past the end = %v, want io.EOF", lErr)
+			}
+		})
+	}
+}
+
+func TestHTTPReaderAtBlocks(t *testing.T) {
+	lContent := []byte(strings.Repeat("0123456789", 10))
+	lServer := rangeServer(lContent)
+	defer lServer.Close()
+
+	lReader, lErr := NewHTTPReaderAt(lServer.URL, ReadOptions{}, RemoteZipOptions{BlockSize: 16, MaxBlocks: 2})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lCases := []struct {
+		offset, length int
+		wantRequests   int
+	}{
+		{0, 10, 2},  // the probe and block 0
+		{5, 10, 2},  // block 0 again, from the cache
+		{10, 30, 4}, // blocks 0 to 2; fetching 2 evicts 0
+		{80, 10, 5}, // block 5, which evicts 1
+		{0, 1, 6},   // block 0 is fetched again
+	}
+	for _, lCase := range lCases {
+		lBuffer := make([]byte, lCase.length)
+		lRead, lErr := lReader.ReadAt(lBuffer, int64(lCase.offset))
+		if lErr != nil || lRead != lCase.length || !bytes.Equal(lBuffer, lContent[lCase.offset:lCase.offset+lCase.length]) {
+			t.Fatalf("ReadAt(%d, %d) = %d %q, %v", lCase.offset, lCase.length, lRead, lBuffer, lErr)
+		}
+		if lReader.Requests() != lCase.wantRequests {
+			t.Errorf("after ReadAt(%d, %d) requests = %d, want %d", lCase.offset, lCase.length, lReader.Requests(), lCase.wantRequests)
+		}
+	}
+
+	lBuffer := make([]byte, 20)
+	if lRead, lErr := lReader.ReadAt(lBuffer, 90); lRead != 10 || lErr != io.EOF {
+		t.Errorf("short read at the end = %d, %v; want 10, io.EOF", lRead, lErr)
+	}
+}
+
+func TestHTTPReaderAtRemoteChange(t *testing.T) {
+	lOld := []byte(strings.Repeat("a", 64))
+	lNew := []byte(strings.Repeat("b", 64))
+	lOldTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	lCases := []struct {
+		name          string
+		etag          bool
+		modTime       bool
+		ignoreIfRange bool
+		wantIfRange   string
+	}{
+		{name: "ETag", etag: true, wantIfRange: `"v1"`},
+		{name: "Last-Modified", modTime: true, wantIfRange: lOldTime.Format(http.TimeFormat)},
+		{name: "server ignoring If-Range", etag: true, ignoreIfRange: true, wantIfRange: `"v1"`},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			var lMu sync.Mutex
+			lContent, lVersion, lModTime := lOld, "v1", lOldTime
+			var lIfRanges []string
+			lServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				lMu.Lock()
+				lBody, lTag, lTime := lContent, lVersion, lModTime
+				lIfRanges = append(lIfRanges, r.Header.Get("If-Range"))
+				lMu.Unlock()
+				if lCase.etag {
+					w.Header().Set("ETag", `"`+lTag+`"`)
+				}
+				if !lCase.modTime {
+					lTime = time.Time{}
+				}
+				if lCase.ignoreIfRange {
+					r.Header.Del("If-Range")
+				}
+				http.ServeContent(w, r, "", lTime, bytes.NewReader(lBody))
+			}))
+			defer lServer.Close()
+
+			lReader, lErr := NewHTTPReaderAt(lServer.URL, ReadOptions{}, RemoteZipOptions{BlockSize: 16})
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			lBuffer := make([]byte, 16)
+			if _, lErr := lReader.ReadAt(lBuffer, 0); lErr != nil || !bytes.Equal(lBuffer, lOld[:16]) {
+				t.Fatalf("ReadAt before the change = %q, %v", lBuffer, lErr)
+			}
+
+			lMu.Lock()
+			lContent, lVersion, lModTime = lNew, "v2", lOldTime.Add(time.Hour)
+			lMu.Unlock()
+			if _, lErr := lReader.ReadAt(lBuffer, 32); !errors.Is(lErr, ErrRemoteFileChanged) {
+				t.Errorf("ReadAt after the change = %q, %v; want ErrRemoteFileChanged", lBuffer, lErr)
+			}
+			lMu.Lock()
+			defer lMu.Unlock()
+			if lLast := lIfRanges[len(lIfRanges)-1]; lLast != lCase.wantIfRange {
+				t.Errorf("If-Range = %q, want %q", lLast, lCase.wantIfRange)
+			}
+		})
+	}
+}
+
+func TestHTTPReaderAtConcurrent(t *testing.T) {
+	lContent := []byte(strings.Repeat("0123456789", 40))
+
+	// Every block request waits until another one is in flight, or gives up after a second.
+	var lMu sync.Mutex
+	lActive, lMaxActive := 0, 0
+	lServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "bytes=0-0" {
+			lMu.Lock()
+			lActive++
+			if lActive > lMaxActive {
+				lMaxActive = lActive
+			}
+			lMu.Unlock()
+			for lWaited := 0; lWaited < 100; lWaited++ {
+				lMu.Lock()
+				lDone := lMaxActive > 1
+				lMu.Unlock()
+				if lDone {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			defer func() {
+				lMu.Lock()
+				lActive--
+				lMu.Unlock()
+			}()
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(lContent))
+	}))
+	defer lServer.Close()
+
+	lReader, lErr := NewHTTPReaderAt(lServer.URL, ReadOptions{}, RemoteZipOptions{BlockSize: 100})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	var lWait sync.WaitGroup
+	for lBlock := 0; lBlock < 4; lBlock++ {
+		lWait.Add(1)
+		go func(pOffset int) {
+			defer lWait.Done()
+			lBuffer := make([]byte, 100)
+			if _, lErr := lReader.ReadAt(lBuffer, int64(pOffset)); lErr != nil || !bytes.Equal(lBuffer, lContent[pOffset:pOffset+100]) {
+				t.Errorf("ReadAt(%d) = %q, %v", pOffset, lBuffer, lErr)
+			}
+		}(lBlock * 100)
+	}
+	lWait.Wait()
+	if lMaxActive < 2 {
+		t.Errorf("at most %d block request in flight, want reads to overlap", lMaxActive)
+	}
+}
+
+func TestReadRemoteZipEntries(t *testing.T) {
+	lArchive := zipBytes(t,
+		testFile{"a.csv", "1,2\n"},
+		testFile{"big.csv", strings.Repeat("x,y\n", 50000)},
+		testFile{"b.txt", "3|4\n"},
+	)
+	lServer := rangeServer(lArchive)
+	defer lServer.Close()
+	lUrl := lServer.URL + "/daily.zip"
+
+	lEntries, lErr := ListRemoteZip(lUrl)
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	var lNames []string
+	for _, lEntry := range lEntries {
+		lNames = append(lNames, lEntry.Name)
+	}
+	if !reflect.DeepEqual(lNames, []string{"a.csv", "big.csv", "b.txt"}) {
+		t.Fatalf("entries = %v", lNames)
+	}
+
+	lResults, lErr := ReadRemoteZipEntries(lUrl, []string{"b.txt"}, ReadOptions{}, RemoteZipOptions{BlockSize: 4 << 10})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	if len(lResults) != 1 || lResults[0].Path != "daily.zip/b.txt" || !reflect.DeepEqual(lResults[0].Rows, [][]string{{"3", "4"}}) {
+		t.Fatalf("results = %+v", lResults)
+	}
+
+	if _, lErr := ReadRemoteZipEntries(lUrl, []string{"missing.csv"}, ReadOptions{}, RemoteZipOptions{}); lErr == nil {
+		t.Error("a missing entry was not reported")
+	}
+}
@@ -0,0 +1,295 @@
+package readfiles
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//----------------------------------------------------------- Section ---------------------------------------------------------------
+
+// ErrSectionNotFound is returned by ExtractSection and ExtractSections when no row matches the marker,
+// or fewer rows match than the requested occurrence.
+var ErrSectionNotFound = errors.New("section marker not found")
+
+// ErrEmptyMarker is returned when SectionOptions.Marker is empty, which would match every row.
+var ErrEmptyMarker = errors.New("section marker is empty")
+
+// MatchMode is how a cell is compared with a section marker.
+type MatchMode int
+
+const (
+	// MatchContains matches a cell that contains the marker.
+	MatchContains MatchMode = iota
+	// MatchExact matches a cell equal to the marker, ignoring surrounding spaces.
+	MatchExact
+	// MatchRegex matches a cell against the marker as a regular expression.
+	MatchRegex
+)
+
+// SectionEnd is the condition that ends a section.
+type SectionEnd int
+
+const (
+	// EndAtBlankRow ends the section before the first row whose cells are all empty or spaces.
+	EndAtBlankRow SectionEnd = iota
+	// EndAtNextMarker ends the section before the next row matching EndMarker, or Marker when EndMarker is empty.
+	EndAtNextMarker
+	// EndAfterRows ends the section after SectionOptions.Rows rows following the marker.
+	EndAfterRows
+	// EndAtPredicate ends the section before the first row for which SectionOptions.Until returns true.
+	EndAtPredicate
+	// EndOfData runs the section to the last row.
+	EndOfData
+)
+
+// AnyColumn makes ExtractSection and ExtractSections look for the marker in every cell of a row.
+const AnyColumn = -1
+
+// SectionOptions describes which rows ExtractSection returns.
+// The zero value with a Marker finds the first row whose first cell contains the marker, case-sensitively,
+// and returns the rows after it up to the next blank row.
+type SectionOptions struct {
+	// Marker identifies the row that starts the section.
+	Marker string
+	Match  MatchMode
+	// IgnoreCase compares the marker case-insensitively in every MatchMode.
+	IgnoreCase bool
+	// Column is the zero-based column searched for the marker, or AnyColumn.
+	Column int
+	// Occurrence picks the matching row: 1 (or 0) is the first, 2 the second, -1 the last, -2 the one before it.
+	Occurrence int
+	// IncludeMarker returns the marker row as the first row of the section.
+	IncludeMarker bool
+	End           SectionEnd
+	// EndMarker ends the section for EndAtNextMarker, matched with the same Match, IgnoreCase and Column rules.
+	EndMarker string
+	// Rows is the number of rows after the marker returned for EndAfterRows.
+	Rows int
+	// Until ends the section for EndAtPredicate.
+	Until func(pRow []string) bool
+}
+
+// Section is the result of ExtractSection.
+type Section struct {
+	// Found reports whether the marker was found. When it is false, the other fields are empty and Marker is -1.
+	Found bool
+	// Marker is the index of the marker row in the input.
+	Marker int
+	// Start and End are the input indexes of the section's rows: Rows is pRows[Start:End].
+	Start int
+	End   int
+	// Rows shares its rows with the input; they are not copied.
+	Rows [][]string
+}
+
+// ReportSection is one titled block of a multi-section report, as returned by ExtractSections.
+// Its Table holds the first row after the title as the header and the remaining rows as data.
+type ReportSection struct {
+	// Title is the matched marker cell, without surrounding spaces.
+	Title    string
+	TitleRow []string
+	// Marker is the input index of the title row; Start and End are the input indexes of the header and
+	// data rows, so the Table holds pRows[Start:End].
+	Marker int
+	Start  int
+	End    int
+	Table
+}
+
+// ExtractSection finds a marker row in pRows and returns the rows that follow it up to an end condition.
+// A marker that is not found returns a Section with Found unset and an error wrapping ErrSectionNotFound,
+// instead of falling back to the start of the data.
+
+// Step-by-Step Process:
+// 1. Build the matcher for the marker (and the end marker) from Match and IgnoreCase.
+// 2. Collect the rows whose searched column matches and pick the requested occurrence.
+// 3. Start after the marker row, or at it when IncludeMarker is set.
+// 4. Walk the following rows until the end condition is met and return the range.
+func ExtractSection(pRows [][]string, pOptions SectionOptions) (Section, error) {
+	lNotFound := Section{Marker: -1}
+
+	lMarker, lEndMarker, lErr := sectionMatchers(pOptions)
+	if lErr != nil {
+		return lNotFound, fmt.Errorf("ExtractSection:001 %w", lErr)
+	}
+
+	var lMatches []int
+	for lIndex, lRow := range pRows {
+		if sectionCell(lRow, pOptions.Column, lMarker) >= 0 {
+			lMatches = append(lMatches, lIndex)
+		}
+	}
+	lOccurrence := pOptions.Occurrence
+	if lOccurrence == 0 {
+		lOccurrence = 1
+	}
+	if lOccurrence < 0 {
+		lOccurrence += len(lMatches) + 1
+	}
+	if lOccurrence < 1 || lOccurrence > len(lMatches) {
+		return lNotFound, fmt.Errorf("ExtractSection:002 %q occurrence %d of %d: %w", pOptions.Marker, pOptions.Occurrence, len(lMatches), ErrSectionNotFound)
+	}
+
+	lSection := Section{Found: true, Marker: lMatches[lOccurrence-1]}
+	lSection.Start = lSection.Marker + 1
+	if pOptions.IncludeMarker {
+		lSection.Start = lSection.Marker
+	}
+
+	lSection.End = sectionEnd(pRows, lSection.Marker, len(pRows), pOptions, lEndMarker)
+	lSection.Rows = pRows[lSection.Start:lSection.End]
+	return lSection, nil
+}
+
+// ExtractSections splits pRows into every section that starts at a row matching the marker, in input order.
+// Each section ends at the end condition of pOptions, or before the next marker row, whichever comes first,
+// so no row belongs to two sections. Occurrence and IncludeMarker are not used.
+// When no row matches, it returns an error wrapping ErrSectionNotFound.
+
+// Step-by-Step Process:
+// 1. Build the matchers for the marker and the end marker.
+// 2. Collect every marker row and the matched cell, which becomes the section title.
+// 3. For each marker, find its end, bounded by the next marker row.
+// 4. Split the rows between the marker and the end into a header row and data rows.
+func ExtractSections(pRows [][]string, pOptions SectionOptions) ([]ReportSection, error) {
+	lMarker, lEndMarker, lErr := sectionMatchers(pOptions)
+	if lErr != nil {
+		return nil, fmt.Errorf("ExtractSections:001 %w", lErr)
+	}
+
+	var lMarkers []int
+	for lIndex, lRow := range pRows {
+		if sectionCell(lRow, pOptions.Column, lMarker) >= 0 {
+			lMarkers = append(lMarkers, lIndex)
+		}
+	}
+	if len(lMarkers) == 0 {
+		return nil, fmt.Errorf("ExtractSections:002 %q: %w", pOptions.Marker, ErrSectionNotFound)
+	}
+
+	var lSections []ReportSection
+	for lPosition, lIndex := range lMarkers {
+		lLimit := len(pRows)
+		if lPosition+1 < len(lMarkers) {
+			lLimit = lMarkers[lPosition+1]
+		}
+		lTitleRow := pRows[lIndex]
+		lSection := ReportSection{
+			Title:    strings.TrimSpace(lTitleRow[sectionCell(lTitleRow, pOptions.Column, lMarker)]),
+			TitleRow: lTitleRow,
+			Marker:   lIndex,
+			Start:    lIndex + 1,
+			End:      sectionEnd(pRows, lIndex, lLimit, pOptions, lEndMarker),
+		}
+		lSection.Table = NewTable(pRows[lSection.Start:lSection.End])
+		lSections = append(lSections, lSection)
+	}
+	return lSections, nil
+}
+
+// sectionMatchers returns the marker matcher and the end-marker matcher of pOptions, and checks that
+// the end condition is usable.
+func sectionMatchers(pOptions SectionOptions) (func(string) bool, func(string) bool, error) {
+	if pOptions.Marker == "" || (pOptions.Match == MatchExact && strings.TrimSpace(pOptions.Marker) == "") {
+		// An exact marker is trimmed, so spaces alone would match every blank cell.
+		return nil, nil, ErrEmptyMarker
+	}
+	lMarker, lErr := sectionMatcher(pOptions.Marker, pOptions)
+	if lErr != nil {
+		return nil, nil, lErr
+	}
+	if pOptions.End == EndAtPredicate && pOptions.Until == nil {
+		return nil, nil, fmt.Errorf("EndAtPredicate needs an Until function")
+	}
+	lEndMarker := lMarker
+	if pOptions.End == EndAtNextMarker && pOptions.EndMarker != "" {
+		lEndMarker, lErr = sectionMatcher(pOptions.EndMarker, pOptions)
+		if lErr != nil {
+			return nil, nil, lErr
+		}
+	}
+	return lMarker, lEndMarker, nil
+}
+
+// sectionEnd returns the index one past the last row of the section whose marker is at pMarker,
+// looking no further than pLimit.
+func sectionEnd(pRows [][]string, pMarker int, pLimit int, pOptions SectionOptions, pEndMarker func(string) bool) int {
+	for lIndex := pMarker + 1; lIndex < pLimit; lIndex++ {
+		lRow := pRows[lIndex]
+		lStop := false
+		switch pOptions.End {
+		case EndAtBlankRow:
+			lStop = blankRow(lRow)
+		case EndAtNextMarker:
+			lStop = sectionCell(lRow, pOptions.Column, pEndMarker) >= 0
+		case EndAfterRows:
+			lStop = lIndex-pMarker > pOptions.Rows
+		case EndAtPredicate:
+			lStop = pOptions.Until(lRow)
+		}
+		if lStop {
+			return lIndex
+		}
+	}
+	return pLimit
+}
+
+// sectionMatcher returns a function reporting whether a cell matches pMarker under the rules of pOptions.
+func sectionMatcher(pMarker string, pOptions SectionOptions) (func(string) bool, error) {
+	switch pOptions.Match {
+	case MatchExact:
+		lMarker := strings.TrimSpace(pMarker)
+		if pOptions.IgnoreCase {
+			return func(pCell string) bool { return strings.EqualFold(strings.TrimSpace(pCell), lMarker) }, nil
+		}
+		return func(pCell string) bool { return strings.TrimSpace(pCell) == lMarker }, nil
+	case MatchRegex:
+		lPattern := pMarker
+		if pOptions.IgnoreCase {
+			lPattern = "(?i)" + lPattern
+		}
+		lRegex, lErr := regexp.Compile(lPattern)
+		if lErr != nil {
+			return nil, lErr
+		}
+		return lRegex.MatchString, nil
+	case MatchContains:
+		if pOptions.IgnoreCase {
+			lMarker := strings.ToLower(pMarker)
+			return func(pCell string) bool { return strings.Contains(strings.ToLower(pCell), lMarker) }, nil
+		}
+		return func(pCell string) bool { return strings.Contains(pCell, pMarker) }, nil
+	}
+	return nil, fmt.Errorf("unknown match mode %d", pOptions.Match)
+}
+
+// sectionCell returns the column of the first cell of pRow that matches, searching only pColumn unless it is
+// AnyColumn, or -1 when nothing matches.
+func sectionCell(pRow []string, pColumn int, pMatch func(string) bool) int {
+	if pColumn == AnyColumn {
+		for lIndex, lCell := range pRow {
+			if pMatch(lCell) {
+				return lIndex
+			}
+		}
+		return -1
+	}
+	if pColumn >= 0 && pColumn < len(pRow) && pMatch(pRow[pColumn]) {
+		return pColumn
+	}
+	return -1
+}
+
+// blankRow reports whether every cell of pRow is empty or spaces. A row with no cells is blank.
+func blankRow(pRow []string) bool {
+	for _, lCell := range pRow {
+		if strings.TrimSpace(lCell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
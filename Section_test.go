@@ -0,0 +1,130 @@
+package readfiles
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// statementRows is a broker statement with three titled blocks, a repeated title and blank rows.
+var statementRows = [][]string{
+	{"Client", "AB123"},             // 0
+	{"", ""},                        // 1
+	{"Equity", ""},                  // 2
+	{"SYMBOL", "QTY"},               // 3
+	{"INFY", "10"},                  // 4
+	{"TCS", "5"},                    // 5
+	{" ", ""},                       // 6
+	{"F&O", ""},                     // 7
+	{"SYMBOL", "LOTS"},              // 8
+	{"NIFTY", "2"},                  // 9
+	{"Charges", "see note: Equity"}, // 10
+	{"ITEM", "AMOUNT"},              // 11
+	{"Brokerage", "20"},             // 12
+	{"GST", "3.6"},                  // 13
+	{"Equity", ""},                  // 14
+	{"SYMBOL", "QTY"},               // 15
+	{"WIPRO", "7"},                  // 16
+}
+
+func TestExtractSection(t *testing.T) {
+	lCases := []struct {
+		name      string
+		options   SectionOptions
+		wantStart int
+		wantEnd   int
+		wantErr   error
+	}{
+		{name: "first occurrence to the blank row", options: SectionOptions{Marker: "Equity"}, wantStart: 3, wantEnd: 6},
+		{name: "last occurrence to the end of data", options: SectionOptions{Marker: "Equity", Occurrence: -1}, wantStart: 15, wantEnd: 17},
+		{name: "second occurrence", options: SectionOptions{Marker: "Equity", Occurrence: 2}, wantStart: 15, wantEnd: 17},
+		{name: "occurrence out of range", options: SectionOptions{Marker: "Equity", Occurrence: 3}, wantErr: ErrSectionNotFound},
+		{name: "marker included", options: SectionOptions{Marker: "F&O", IncludeMarker: true, End: EndAfterRows, Rows: 2}, wantStart: 7, wantEnd: 10},
+		{name: "exact match ignores the other column", options: SectionOptions{Marker: " charges ", Match: MatchExact, IgnoreCase: true, End: EndAfterRows, Rows: 2}, wantStart: 11, wantEnd: 13},
+		{name: "contains in any column", options: SectionOptions{Marker: "note", Column: AnyColumn, End: EndOfData}, wantStart: 11, wantEnd: 17},
+		{name: "regex", options: SectionOptions{Marker: `^F&O$`, Match: MatchRegex, End: EndAtNextMarker, EndMarker: "Charges"}, wantStart: 8, wantEnd: 10},
+		{name: "next marker", options: SectionOptions{Marker: "Equity", Match: MatchExact, End: EndAtNextMarker}, wantStart: 3, wantEnd: 14},
+		{name: "predicate", options: SectionOptions{Marker: "Charges", End: EndAtPredicate, Until: func(pRow []string) bool { return pRow[0] == "GST" }}, wantStart: 11, wantEnd: 13},
+		{name: "case-sensitive by default", options: SectionOptions{Marker: "equity"}, wantErr: ErrSectionNotFound},
+		{name: "second column", options: SectionOptions{Marker: "AB", Column: 1, End: EndAfterRows, Rows: 0}, wantStart: 1, wantEnd: 1},
+		{name: "empty marker", options: SectionOptions{}, wantErr: ErrEmptyMarker},
+		{name: "blank exact marker", options: SectionOptions{Marker: "  ", Match: MatchExact}, wantErr: ErrEmptyMarker},
+		{name: "bad regex", options: SectionOptions{Marker: "(", Match: MatchRegex}, wantErr: errAny},
+		{name: "predicate missing", options: SectionOptions{Marker: "Equity", End: EndAtPredicate}, wantErr: errAny},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			lSection, lErr := ExtractSection(statementRows, lCase.options)
+			if lCase.wantErr != nil {
+				if lCase.wantErr == errAny && lErr == nil || lCase.wantErr != errAny && !errors.Is(lErr, lCase.wantErr) {
+					t.Fatalf("err = %v, want %v", lErr, lCase.wantErr)
+				}
+				if lSection.Found || lSection.Marker != -1 || lSection.Rows != nil {
+					t.Errorf("section = %+v, want not found", lSection)
+				}
+				return
+			}
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			if !lSection.Found || lSection.Start != lCase.wantStart || lSection.End != lCase.wantEnd {
+				t.Fatalf("section = %d..%d (found %v), want %d..%d", lSection.Start, lSection.End, lSection.Found, lCase.wantStart, lCase.wantEnd)
+			}
+			if !reflect.DeepEqual(lSection.Rows, statementRows[lCase.wantStart:lCase.wantEnd]) {
+				t.Errorf("rows = %q", lSection.Rows)
+			}
+		})
+	}
+}
+
+func TestExtractSections(t *testing.T) {
+	lOptions := SectionOptions{Marker: `^(Equity|F&O|Charges)$`, Match: MatchRegex, End: EndAtBlankRow}
+	lSections, lErr := ExtractSections(statementRows, lOptions)
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+
+	lWant := []struct {
+		title  string
+		marker int
+		start  int
+		end    int
+		header []string
+		rows   int
+	}{
+		// The blank row ends the first block before the next title.
+		{"Equity", 2, 3, 6, []string{"SYMBOL", "QTY"}, 2},
+		// A block without a blank row ends at the next title, so no row is in two blocks.
+		{"F&O", 7, 8, 10, []string{"SYMBOL", "LOTS"}, 1},
+		{"Charges", 10, 11, 14, []string{"ITEM", "AMOUNT"}, 2},
+		{"Equity", 14, 15, 17, []string{"SYMBOL", "QTY"}, 1},
+	}
+	if len(lSections) != len(lWant) {
+		t.Fatalf("%d sections, want %d", len(lSections), len(lWant))
+	}
+	for lIndex, lSection := range lSections {
+		lExpected := lWant[lIndex]
+		if lSection.Title != lExpected.title || lSection.Marker != lExpected.marker || lSection.Start != lExpected.start || lSection.End != lExpected.end {
+			t.Errorf("sections[%d] = %q rows %d..%d at %d, want %q rows %d..%d at %d", lIndex, lSection.Title, lSection.Start, lSection.End, lSection.Marker, lExpected.title, lExpected.start, lExpected.end, lExpected.marker)
+		}
+		if !reflect.DeepEqual(lSection.Header, lExpected.header) || len(lSection.Rows) != lExpected.rows {
+			t.Errorf("sections[%d] table = %q %q", lIndex, lSection.Header, lSection.Rows)
+		}
+		if !reflect.DeepEqual(lSection.TitleRow, statementRows[lExpected.marker]) {
+			t.Errorf("sections[%d] title row = %q", lIndex, lSection.TitleRow)
+		}
+	}
+
+	// The title is the matched cell, trimmed, even when it is not in the first column.
+	lSections, lErr = ExtractSections([][]string{{"", " Totals "}, {"A", "B"}}, SectionOptions{Marker: "Totals", Column: AnyColumn})
+	if lErr != nil || len(lSections) != 1 || lSections[0].Title != "Totals" || !reflect.DeepEqual(lSections[0].Header, []string{"A", "B"}) {
+		t.Errorf("sections = %+v, %v", lSections, lErr)
+	}
+
+	if _, lErr := ExtractSections(statementRows, SectionOptions{Marker: "Dividends"}); !errors.Is(lErr, ErrSectionNotFound) {
+		t.Errorf("err = %v, want ErrSectionNotFound", lErr)
+	}
+	if _, lErr := ExtractSections(statementRows, SectionOptions{}); !errors.Is(lErr, ErrEmptyMarker) {
+		t.Errorf("err = %v, want ErrEmptyMarker", lErr)
+	}
+}
@@ -0,0 +1,212 @@
+package readfiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+//------------------------------------------------------------ SFTP Source ----------------------------------------------------------
+
+// ErrNoHostKeyCheck is returned when SFTPOptions gives no way to check the server's host key.
+var ErrNoHostKeyCheck = errors.New("sftp: no known_hosts file or host key callback configured")
+
+// SFTPOptions configures an SFTP connection.
+type SFTPOptions struct {
+	// Address is "host:port"; the port defaults to 22.
+	Address string
+	User    string
+	// Password and PrivateKey (PEM, optionally protected by Passphrase) are offered as authentication methods.
+	Password   string
+	PrivateKey []byte
+	Passphrase string
+	// KnownHostsFile is an OpenSSH known_hosts file used to check the server's host key.
+	KnownHostsFile string
+	// HostKeyCallback checks the host key instead of KnownHostsFile. One of the two is required;
+	// ssh.InsecureIgnoreHostKey() may be given explicitly for tests.
+	HostKeyCallback ssh.HostKeyCallback
+	// Timeout bounds the connection set-up. Zero means 30 seconds.
+	Timeout time.Duration
+	// Dial, if set, opens the connection that SSH runs over instead of a plain TCP dial, for example through
+	// a jump host or a proxy. The host key is still checked against Address.
+	Dial func(pCtx context.Context, pAddress string) (net.Conn, error)
+}
+
+// ListSFTP returns the files of the folder pDir that pass pFilter, using the same Include/Exclude rules
+// as archive entries. Folders and links are left out.
+func ListSFTP(pOptions SFTPOptions, pDir string, pFilter EntryFilter) ([]RemoteFile, error) {
+	lClient, lSSH, lErr := dialSFTP(pOptions)
+	if lErr != nil {
+		return nil, fmt.Errorf("ListSFTP:001 %w", lErr)
+	}
+	defer lSSH.Close()
+	defer lClient.Close()
+
+	lInfos, lErr := lClient.ReadDir(pDir)
+	if lErr != nil {
+		return nil, fmt.Errorf("ListSFTP:002 %w", lErr)
+	}
+
+	var lFiles []RemoteFile
+	for _, lInfo := range lInfos {
+		if !lInfo.Mode().IsRegular() {
+			continue
+		}
+		lFile := RemoteFile{Path: path.Join(pDir, lInfo.Name()), Name: lInfo.Name(), Size: lInfo.Size(), Modified: lInfo.ModTime()}
+		lKeep, lErr := keepRemoteFile(lFile, pFilter)
+		if lErr != nil {
+			return nil, fmt.Errorf("ListSFTP:003 %w", lErr)
+		}
+		if lKeep {
+			lFiles = append(lFiles, lFile)
+		}
+	}
+	return lFiles, nil
+}
+
+// FromSFTP is the file at pPath on an SFTP server. Archives are read in place with random access,
+// so only the parts that are needed are transferred.
+func FromSFTP(pOptions SFTPOptions, pPath string) Source {
+	return sftpSource{options: pOptions, path: pPath}
+}
+
+type sftpSource struct {
+	options SFTPOptions
+	path    string
+}
+
+func (s sftpSource) Name() string {
+	return path.Base(s.path)
+}
+
+func (s sftpSource) Open(pOptions ReadOptions) (io.ReadCloser, int64, error) {
+	lClient, lSSH, lErr := dialSFTP(s.options)
+	if lErr != nil {
+		return nil, 0, lErr
+	}
+	lFile, lErr := lClient.Open(s.path)
+	if lErr != nil {
+		lClient.Close()
+		lSSH.Close()
+		return nil, 0, lErr
+	}
+	lInfo, lErr := lFile.Stat()
+	if lErr != nil {
+		lFile.Close()
+		lClient.Close()
+		lSSH.Close()
+		return nil, 0, lErr
+	}
+	lBody := progressBody(lFile, "sftp://"+s.options.Address+"/"+s.path, 0, lInfo.Size(), pOptions)
+	return &sftpReader{Reader: lBody, file: lFile, client: lClient, ssh: lSSH}, lInfo.Size(), nil
+}
+
+// sftpReader reads a remote file and closes the SFTP session and SSH connection with it.
+type sftpReader struct {
+	io.Reader
+	file   *sftp.File
+	client *sftp.Client
+	ssh    *ssh.Client
+}
+
+func (r *sftpReader) ReadAt(p []byte, pOffset int64) (int, error) {
+	return r.file.ReadAt(p, pOffset)
+}
+
+func (r *sftpReader) Close() error {
+	lErr := r.file.Close()
+	r.client.Close()
+	r.ssh.Close()
+	return lErr
+}
+
+// dialSFTP opens an SSH connection, checking the host key, and starts an SFTP session on it.
+func dialSFTP(pOptions SFTPOptions) (*sftp.Client, *ssh.Client, error) {
+	lAddress := pOptions.Address
+	if _, _, lErr := net.SplitHostPort(lAddress); lErr != nil {
+		lAddress = net.JoinHostPort(lAddress, "22")
+	}
+	lTimeout := pOptions.Timeout
+	if lTimeout <= 0 {
+		lTimeout = 30 * time.Second
+	}
+
+	lHostKey := pOptions.HostKeyCallback
+	if lHostKey == nil {
+		if pOptions.KnownHostsFile == "" {
+			return nil, nil, ErrNoHostKeyCheck
+		}
+		lCallback, lErr := knownhosts.New(pOptions.KnownHostsFile)
+		if lErr != nil {
+			return nil, nil, fmt.Errorf("dialSFTP:001 %w", lErr)
+		}
+		lHostKey = lCallback
+	}
+
+	var lAuth []ssh.AuthMethod
+	if len(pOptions.PrivateKey) > 0 {
+		var lSigner ssh.Signer
+		var lErr error
+		if pOptions.Passphrase != "" {
+			lSigner, lErr = ssh.ParsePrivateKeyWithPassphrase(pOptions.PrivateKey, []byte(pOptions.Passphrase))
+		} else {
+			lSigner, lErr = ssh.ParsePrivateKey(pOptions.PrivateKey)
+		}
+		if lErr != nil {
+			return nil, nil, fmt.Errorf("dialSFTP:002 %w", lErr)
+		}
+		lAuth = append(lAuth, ssh.PublicKeys(lSigner))
+	}
+	if pOptions.Password != "" {
+		lAuth = append(lAuth, ssh.Password(pOptions.Password))
+	}
+
+	lConfig := &ssh.ClientConfig{
+		User:            pOptions.User,
+		Auth:            lAuth,
+		HostKeyCallback: lHostKey,
+		Timeout:         lTimeout,
+	}
+	var lSSH *ssh.Client
+	var lErr error
+	if pOptions.Dial != nil {
+		lSSH, lErr = dialSSH(pOptions.Dial, lAddress, lConfig)
+	} else {
+		lSSH, lErr = ssh.Dial("tcp", lAddress, lConfig)
+	}
+	if lErr != nil {
+		return nil, nil, fmt.Errorf("dialSFTP:003 %w", lErr)
+	}
+	lClient, lErr := sftp.NewClient(lSSH)
+	if lErr != nil {
+		lSSH.Close()
+		return nil, nil, fmt.Errorf("dialSFTP:004 %w", lErr)
+	}
+	return lClient, lSSH, nil
+}
+
+// dialSSH is ssh.Dial over a connection opened by pDial.
+func dialSSH(pDial func(context.Context, string) (net.Conn, error), pAddress string, pConfig *ssh.ClientConfig) (*ssh.Client, error) {
+	lCtx, lCancel := context.WithTimeout(context.Background(), pConfig.Timeout)
+	defer lCancel()
+	lConn, lErr := pDial(lCtx, pAddress)
+	if lErr != nil {
+		return nil, lErr
+	}
+	lClientConn, lChannels, lRequests, lErr := ssh.NewClientConn(lConn, pAddress, pConfig)
+	if lErr != nil {
+		lConn.Close()
+		return nil, lErr
+	}
+	return ssh.NewClient(lClientConn, lChannels, lRequests), nil
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
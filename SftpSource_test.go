@@ -0,0 +1,242 @@
+package readfiles
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sftpTestServer is an SSH server on 127.0.0.1 whose "sftp" subsystem serves an in-memory file system.
+// It accepts "ops" with the password "secret" or the client key given to startSFTPServer.
+type sftpTestServer struct {
+	listener net.Listener
+	hostKey  ssh.PublicKey
+}
+
+// startSFTPServer serves pFiles, keyed by absolute path, until the test ends.
+func startSFTPServer(t *testing.T, pFiles map[string]string, pClientKey ssh.PublicKey) *sftpTestServer {
+	t.Helper()
+	lHandlers := sftp.InMemHandler()
+	uploadSFTPFiles(t, lHandlers, pFiles)
+
+	_, lHostPrivate, lErr := ed25519.GenerateKey(nil)
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lHostSigner, lErr := ssh.NewSignerFromKey(lHostPrivate)
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lConfig := &ssh.ServerConfig{
+		PasswordCallback: func(pMeta ssh.ConnMetadata, pPassword []byte) (*ssh.Permissions, error) {
+			if pMeta.User() == "ops" && string(pPassword) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+		PublicKeyCallback: func(pMeta ssh.ConnMetadata, pKey ssh.PublicKey) (*ssh.Permissions, error) {
+			if pClientKey != nil && bytes.Equal(pKey.Marshal(), pClientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+	}
+	lConfig.AddHostKey(lHostSigner)
+
+	lListener, lErr := net.Listen("tcp", "127.0.0.1:0")
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	t.Cleanup(func() { lListener.Close() })
+	go func() {
+		for {
+			lConn, lErr := lListener.Accept()
+			if lErr != nil {
+				return
+			}
+			go serveSSH(lConn, lConfig, lHandlers)
+		}
+	}()
+	return &sftpTestServer{listener: lListener, hostKey: lHostSigner.PublicKey()}
+}
+
+// serveSSH runs one SSH connection, starting an SFTP request server for each "sftp" subsystem request.
+func serveSSH(pConn net.Conn, pConfig *ssh.ServerConfig, pHandlers sftp.Handlers) {
+	lServerConn, lChannels, lRequests, lErr := ssh.NewServerConn(pConn, pConfig)
+	if lErr != nil {
+		pConn.Close()
+		return
+	}
+	defer lServerConn.Close()
+	go ssh.DiscardRequests(lRequests)
+
+	for lNew := range lChannels {
+		if lNew.ChannelType() != "session" {
+			lNew.Reject(ssh.UnknownChannelType, "sessions only")
+			continue
+		}
+		lChannel, lChannelRequests, lErr := lNew.Accept()
+		if lErr != nil {
+			continue
+		}
+		go func() {
+			for lRequest := range lChannelRequests {
+				lOk := lRequest.Type == "subsystem" && len(lRequest.Payload) > 4 && string(lRequest.Payload[4:]) == "sftp"
+				lRequest.Reply(lOk, nil)
+				if lOk {
+					go func() {
+						sftp.NewRequestServer(lChannel, pHandlers).Serve()
+						lChannel.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// uploadSFTPFiles writes pFiles into pHandlers through an SFTP client on a pipe.
+func uploadSFTPFiles(t *testing.T, pHandlers sftp.Handlers, pFiles map[string]string) {
+	t.Helper()
+	lClientSide, lServerSide := net.Pipe()
+	go sftp.NewRequestServer(lServerSide, pHandlers).Serve()
+	lClient, lErr := sftp.NewClientPipe(lClientSide, lClientSide)
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	defer lClient.Close()
+	for lPath, lBody := range pFiles {
+		if lErr := lClient.MkdirAll(path.Dir(lPath)); lErr != nil {
+			t.Fatal(lErr)
+		}
+		lFile, lErr := lClient.Create(lPath)
+		if lErr != nil {
+			t.Fatal(lErr)
+		}
+		lFile.Write([]byte(lBody))
+		lFile.Close()
+	}
+}
+
+// knownHostsFile writes a known_hosts file that trusts pKey for pAddress.
+func knownHostsFile(t *testing.T, pAddress string, pKey ssh.PublicKey) string {
+	t.Helper()
+	lPath := filepath.Join(t.TempDir(), "known_hosts")
+	if lErr := os.WriteFile(lPath, []byte(knownhosts.Line([]string{pAddress}, pKey)+"\n"), 0o600); lErr != nil {
+		t.Fatal(lErr)
+	}
+	return lPath
+}
+
+func TestSFTPSource(t *testing.T) {
+	lClientPublic, lClientPrivate, _ := ed25519.GenerateKey(nil)
+	lClientKey, _ := ssh.NewPublicKey(lClientPublic)
+	lBlock, lErr := ssh.MarshalPrivateKeyWithPassphrase(lClientPrivate, "", []byte("phrase"))
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lPEM := pem.EncodeToMemory(lBlock)
+
+	lServer := startSFTPServer(t, map[string]string{
+		"/pub/cm01JAN2024bhav.csv.zip": string(zipBytes(t, testFile{"cm01JAN2024bhav.csv", "SYMBOL,CLOSE\nINFY,1450\n"})),
+		"/pub/fo01JAN2024bhav.csv.zip": string(zipBytes(t, testFile{"fo01JAN2024bhav.csv", "SYMBOL,OI\nINFY,9\n"})),
+		"/pub/readme.txt":              "hello\n",
+		"/pub/old/cm01JAN2023.zip":     "",
+	}, lClientKey)
+	lAddress := lServer.listener.Addr().String()
+
+	lDialed := 0
+	lHook := func(pCtx context.Context, pAddress string) (net.Conn, error) {
+		lDialed++
+		if pAddress != "sftp.example.com:22" {
+			return nil, errors.New("unexpected address " + pAddress)
+		}
+		var lDialer net.Dialer
+		return lDialer.DialContext(pCtx, "tcp", lAddress)
+	}
+
+	lCases := []struct {
+		name    string
+		options SFTPOptions
+	}{
+		{"known_hosts and password", SFTPOptions{Address: lAddress, User: "ops", Password: "secret", KnownHostsFile: knownHostsFile(t, lAddress, lServer.hostKey)}},
+		{"host key callback and private key", SFTPOptions{Address: lAddress, User: "ops", PrivateKey: lPEM, Passphrase: "phrase", HostKeyCallback: ssh.FixedHostKey(lServer.hostKey)}},
+		// The host key is checked against the configured name, not the address the hook dialed.
+		{"dial hook", SFTPOptions{Address: "sftp.example.com", User: "ops", Password: "secret", KnownHostsFile: knownHostsFile(t, "sftp.example.com:22", lServer.hostKey), Dial: lHook}},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			lListed, lErr := ListSFTP(lCase.options, "/pub", EntryFilter{Include: []string{"*bhav.csv.zip"}})
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			var lPaths []string
+			for _, lFile := range lListed {
+				lPaths = append(lPaths, lFile.Path)
+			}
+			if lWant := []string{"/pub/cm01JAN2024bhav.csv.zip", "/pub/fo01JAN2024bhav.csv.zip"}; !reflect.DeepEqual(lPaths, lWant) {
+				t.Fatalf("listed %q, want %q", lPaths, lWant)
+			}
+
+			lResults, lErr := Read(FromSFTP(lCase.options, lListed[1].Path), ReadOptions{})
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			if lWant := [][]string{{"SYMBOL", "OI"}, {"INFY", "9"}}; len(lResults) != 1 || !reflect.DeepEqual(lResults[0].Rows, lWant) {
+				t.Fatalf("results = %+v, want %q", lResults, lWant)
+			}
+		})
+	}
+	if lDialed != 2 {
+		t.Errorf("dial hook called %d times, want 2", lDialed)
+	}
+}
+
+func TestSFTPSourceHostKey(t *testing.T) {
+	lServer := startSFTPServer(t, map[string]string{"/pub/a.zip": string(zipBytes(t, testFile{"a.csv", "1,2\n"}))}, nil)
+	lAddress := lServer.listener.Addr().String()
+
+	lOtherPublic, _, _ := ed25519.GenerateKey(nil)
+	lOtherKey, _ := ssh.NewPublicKey(lOtherPublic)
+
+	_, lErr := ListSFTP(SFTPOptions{Address: lAddress, User: "ops", Password: "secret"}, "/pub", EntryFilter{})
+	if !errors.Is(lErr, ErrNoHostKeyCheck) {
+		t.Errorf("no host key check: err = %v, want ErrNoHostKeyCheck", lErr)
+	}
+	if _, lErr := Read(FromSFTP(SFTPOptions{Address: lAddress, User: "ops", Password: "secret"}, "/pub/a.zip"), ReadOptions{}); !errors.Is(lErr, ErrNoHostKeyCheck) {
+		t.Errorf("no host key check on Read: err = %v, want ErrNoHostKeyCheck", lErr)
+	}
+
+	lCases := []struct {
+		name       string
+		knownHosts string
+	}{
+		{"changed host key", knownHostsFile(t, lAddress, lOtherKey)},
+		{"unknown host", knownHostsFile(t, "other.example.com:22", lServer.hostKey)},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			_, lErr := ListSFTP(SFTPOptions{Address: lAddress, User: "ops", Password: "secret", KnownHostsFile: lCase.knownHosts}, "/pub", EntryFilter{})
+			var lKeyErr *knownhosts.KeyError
+			if !errors.As(lErr, &lKeyErr) {
+				t.Fatalf("err = %v, want a *knownhosts.KeyError", lErr)
+			}
+		})
+	}
+
+	lOptions := SFTPOptions{Address: lAddress, User: "ops", Password: "wrong", HostKeyCallback: ssh.FixedHostKey(lServer.hostKey)}
+	if _, lErr := ListSFTP(lOptions, "/pub", EntryFilter{}); lErr == nil {
+		t.Error("a wrong password logged in")
+	}
+}
@@ -0,0 +1,222 @@
+package readfiles
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+//--------------------------------------------------------------- Source ------------------------------------------------------------
+
+// ErrUnknownFormat is returned by Read when a source is neither an archive nor a file with a supported extension.
+var ErrUnknownFormat = errors.New("cannot detect the format of the source")
+
+// Source is where Read gets its content from: an upload, a URL, a local file, a reader or a byte slice.
+type Source interface {
+	// Name is the file name of the content. Its extension chooses the parser for data files.
+	Name() string
+	// Open returns the content and its size, or -1 when the size is unknown.
+	// The reader also implements io.ReaderAt when the content allows random access.
+	Open(pOptions ReadOptions) (io.ReadCloser, int64, error)
+}
+
+// FromRequest is the file uploaded in the form field pFormName of r.
+func FromRequest(r *http.Request, pFormName string) Source {
+	return &requestSource{request: r, field: pFormName}
+}
+
+// FromURL is the file at pUrl, downloaded with the HTTP, retry, cache and verification options given to Read.
+func FromURL(pUrl string) Source {
+	return urlSource{url: pUrl}
+}
+
+// FromFile is the local file at pPath.
+func FromFile(pPath string) Source {
+	return fileSource{path: pPath}
+}
+
+// FromReader is content read from pReader, named pName. It is read once; archives are stored
+// in memory or a temporary file first, as ZIP needs random access.
+func FromReader(pName string, pReader io.Reader) Source {
+	return readerSource{name: pName, reader: pReader}
+}
+
+// FromBytes is content held in memory, named pName. It is handy for tests.
+func FromBytes(pName string, pData []byte) Source {
+	return bytesSource{name: pName, data: pData}
+}
+
+// Read reads any supported content from any source. Archives (ZIP, tar, tar.gz, tgz) are recognised from
+// their first bytes and read entry by entry, as ReadArchiveReader does; a CSV, TXT, XLSX or custom-parser file
+// is recognised from the source name and returned as a single EntryResult.
+
+// Step-by-Step Process:
+// 1. Open the source.
+// 2. If the name has a data extension (.csv, .txt, .xlsx or one in pOptions.Parsers), parse the stream directly.
+// 3. Otherwise store the content for random access if needed, detect the archive format and read its entries.
+func Read(pSource Source, pOptions ReadOptions) ([]EntryResult, error) {
+	log.Println("Read(+)")
+
+	lReader, lSize, lErr := pSource.Open(pOptions)
+	if lErr != nil {
+		return nil, fmt.Errorf("Read:001 %w", lErr)
+	}
+	defer lReader.Close()
+
+	lName := pSource.Name()
+	lFormat, lOk := entryFormat(lName, pOptions)
+	_, lCustom := pOptions.parser(lName)
+	if lOk && (lCustom || (lFormat != FormatZip && lFormat != FormatTar && lFormat != FormatGzip)) {
+		lResults, lErr := readEntry(lName, lName, lFormat, lReader, lSize, 0, pOptions.withBudget())
+		if lErr != nil {
+			return lResults, fmt.Errorf("Read:002 %w", lErr)
+		}
+		log.Println("Read(-)")
+		return lResults, nil
+	}
+
+	lRandom, lOk := lReader.(io.ReaderAt)
+	if !lOk || lSize < 0 {
+		// Only archives need random access; anything else is rejected before it is stored.
+		lBuffered := bufio.NewReaderSize(lReader, 512)
+		lPeek, _ := lBuffered.Peek(512)
+		if sniffFormat(lPeek) == "" {
+			return nil, fmt.Errorf("Read:003 %s: %w", lName, ErrUnknownFormat)
+		}
+		lSpool, lErr := spoolDownload(lBuffered, lSize, pOptions.MemoryLimit, lName)
+		if lErr != nil {
+			return nil, fmt.Errorf("Read:004 %w", lErr)
+		}
+		defer lSpool.Close()
+		lRandom, lSize = lSpool.ReaderAt(), lSpool.Size()
+	}
+
+	lResults, lErr := ReadArchiveReader(lName, lRandom, lSize, pOptions)
+	if errors.Is(lErr, ErrUnknownArchiveFormat) {
+		lErr = fmt.Errorf("%w: %w", ErrUnknownFormat, lErr)
+	}
+	if lErr != nil {
+		return lResults, fmt.Errorf("Read:005 %w", lErr)
+	}
+
+	log.Println("Read(-)")
+	return lResults, nil
+}
+
+//---- Sources ----
+
+type requestSource struct {
+	request *http.Request
+	field   string
+	name    string
+}
+
+func (s *requestSource) Name() string {
+	if s.name == "" {
+		// The file name is only known from the multipart header.
+		if lFile, lHeader, lErr := s.request.FormFile(s.field); lErr == nil {
+			lFile.Close()
+			s.name = lHeader.Filename
+		}
+	}
+	return s.name
+}
+
+func (s *requestSource) Open(pOptions ReadOptions) (io.ReadCloser, int64, error) {
+	lFile, lHeader, lErr := s.request.FormFile(s.field)
+	if lErr != nil {
+		return nil, 0, lErr
+	}
+	s.name = lHeader.Filename
+	return lFile, lHeader.Size, nil
+}
+
+type urlSource struct {
+	url string
+}
+
+func (s urlSource) Name() string {
+	return remoteArchiveName(s.url)
+}
+
+func (s urlSource) Open(pOptions ReadOptions) (io.ReadCloser, int64, error) {
+	lDownload, lErr := downloadArchive(s.url, s.Name(), pOptions)
+	if lErr != nil {
+		return nil, 0, lErr
+	}
+	return &downloadReader{SectionReader: io.NewSectionReader(lDownload.ReaderAt(), 0, lDownload.Size()), download: lDownload}, lDownload.Size(), nil
+}
+
+// downloadReader reads a stored download and releases it on Close.
+type downloadReader struct {
+	*io.SectionReader
+	download *spooledDownload
+}
+
+func (r *downloadReader) Close() error {
+	return r.download.Close()
+}
+
+type fileSource struct {
+	path string
+}
+
+func (s fileSource) Name() string {
+	return filepath.Base(s.path)
+}
+
+func (s fileSource) Open(pOptions ReadOptions) (io.ReadCloser, int64, error) {
+	lFile, lErr := os.Open(s.path)
+	if lErr != nil {
+		return nil, 0, lErr
+	}
+	lInfo, lErr := lFile.Stat()
+	if lErr != nil {
+		lFile.Close()
+		return nil, 0, lErr
+	}
+	return lFile, lInfo.Size(), nil
+}
+
+type readerSource struct {
+	name   string
+	reader io.Reader
+}
+
+func (s readerSource) Name() string {
+	return s.name
+}
+
+func (s readerSource) Open(pOptions ReadOptions) (io.ReadCloser, int64, error) {
+	return io.NopCloser(s.reader), -1, nil
+}
+
+type bytesSource struct {
+	name string
+	data []byte
+}
+
+func (s bytesSource) Name() string {
+	return s.name
+}
+
+func (s bytesSource) Open(pOptions ReadOptions) (io.ReadCloser, int64, error) {
+	return bytesReader{bytes.NewReader(s.data)}, int64(len(s.data)), nil
+}
+
+// bytesReader is a bytes.Reader with a no-op Close, keeping its ReadAt visible to Read.
+type bytesReader struct {
+	*bytes.Reader
+}
+
+func (bytesReader) Close() error {
+	return nil
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
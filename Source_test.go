@@ -0,0 +1,92 @@
+package readfiles
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	lZip := zipBytes(t, testFile{"a.csv", "1,2\n"})
+	lTgz := gzipBytes(t, "a.tar", tarBytes(t, testFile{"a.csv", "1,2\n"}))
+
+	lServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(lZip)
+	}))
+	defer lServer.Close()
+
+	lPath := filepath.Join(t.TempDir(), "upload.bin")
+	if lErr := os.WriteFile(lPath, lTgz, 0o644); lErr != nil {
+		t.Fatal(lErr)
+	}
+
+	lCases := []struct {
+		name     string
+		source   Source
+		wantPath string
+		wantErr  error
+	}{
+		{name: "bytes, ZIP", source: FromBytes("a.zip", lZip), wantPath: "a.zip/a.csv"},
+		{name: "bytes, CSV", source: FromBytes("b.csv", []byte("1,2\n")), wantPath: "b.csv"},
+		// The content decides the archive format, not the name.
+		{name: "bytes, ZIP named .dat", source: FromBytes("report.dat", lZip), wantPath: "report.dat/a.csv"},
+		{name: "reader, tar.gz", source: FromReader("a.tgz", bytes.NewReader(lTgz)), wantPath: "a.tgz/a.csv"},
+		{name: "reader, ZIP", source: FromReader("a.zip", bytes.NewReader(lZip)), wantPath: "a.zip/a.csv"},
+		{name: "reader, unknown", source: FromReader("a.bin", strings.NewReader("plain words")), wantErr: ErrUnknownFormat},
+		{name: "bytes, unknown", source: FromBytes("a.bin", []byte("plain words")), wantErr: ErrUnknownFormat},
+		{name: "file", source: FromFile(lPath), wantPath: "upload.bin/a.csv"},
+		{name: "missing file", source: FromFile(lPath + ".missing"), wantErr: os.ErrNotExist},
+		{name: "URL", source: FromURL(lServer.URL + "/daily.zip"), wantPath: "daily.zip/a.csv"},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			lResults, lErr := Read(lCase.source, ReadOptions{})
+			if lCase.wantErr != nil {
+				if !errors.Is(lErr, lCase.wantErr) {
+					t.Fatalf("err = %v, want %v", lErr, lCase.wantErr)
+				}
+				return
+			}
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			if len(lResults) != 1 || lResults[0].Path != lCase.wantPath || !reflect.DeepEqual(lResults[0].Rows, [][]string{{"1", "2"}}) {
+				t.Fatalf("results = %+v, want %s with one row", lResults, lCase.wantPath)
+			}
+		})
+	}
+}
+
+func TestReadFromRequest(t *testing.T) {
+	var lBody bytes.Buffer
+	lForm := multipart.NewWriter(&lBody)
+	lPart, lErr := lForm.CreateFormFile("file", "upload.zip")
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lPart.Write(zipBytes(t, testFile{"a.csv", "1,2\n"}, testFile{"b.csv", "3,4\n"}))
+	lForm.Close()
+
+	lRequest := httptest.NewRequest(http.MethodPost, "/upload", &lBody)
+	lRequest.Header.Set("Content-Type", lForm.FormDataContentType())
+
+	lSource := FromRequest(lRequest, "file")
+	if lName := lSource.Name(); lName != "upload.zip" {
+		t.Errorf("Name() = %q, want upload.zip", lName)
+	}
+	lResults, lErr := Read(lSource, ReadOptions{})
+	if lErr != nil || len(lResults) != 2 {
+		t.Fatalf("results = %+v, %v", lResults, lErr)
+	}
+
+	if _, lErr := Read(FromRequest(lRequest, "other"), ReadOptions{}); lErr == nil {
+		t.Error("a missing form field was accepted")
+	}
+}
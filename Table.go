@@ -0,0 +1,49 @@
+package readfiles
+
+import "strings"
+
+//----------------------------------------------------------- Table -----------------------------------------------------------------
+
+// Table is a 2D string array split into its header row and its data rows.
+// The readers in this package return plain [][]string values; NewTable turns such a value into a Table
+// when the first row is a header, and Records turns it back.
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// NewTable builds a Table from a 2D string array, treating the first row as the header.
+// An empty input returns an empty Table.
+func NewTable(pRecords [][]string) Table {
+	var lTable Table
+	if len(pRecords) == 0 {
+		return lTable
+	}
+	lTable.Header = pRecords[0]
+	lTable.Rows = pRecords[1:]
+	return lTable
+}
+
+// Records returns the Table as a 2D string array with the header as its first row.
+// A Table without a header returns only its rows.
+func (t Table) Records() [][]string {
+	var lRecords [][]string
+	if len(t.Header) > 0 {
+		lRecords = append(lRecords, t.Header)
+	}
+	lRecords = append(lRecords, t.Rows...)
+	return lRecords
+}
+
+// ColumnIndex returns the position of the named header column, or -1 if it is not present.
+// Names are compared case-insensitively after trimming surrounding spaces.
+func (t Table) ColumnIndex(pName string) int {
+	for lIndex, lColumn := range t.Header {
+		if strings.EqualFold(strings.TrimSpace(lColumn), strings.TrimSpace(pName)) {
+			return lIndex
+		}
+	}
+	return -1
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,185 @@
+package readfiles
+
+import (
+	"strconv"
+	"strings"
+)
+
+//------------------------------------------------------------- Union ---------------------------------------------------------------
+
+// UnionInput is one table to be stacked by UnionTables, with where it came from.
+type UnionInput struct {
+	Table
+	// Source, Sheet and Date fill the provenance columns of UnionOptions for the rows of this input.
+	Source string
+	Sheet  string
+	Date   string
+}
+
+// UnionOptions configures UnionTables.
+type UnionOptions struct {
+	// Columns fixes the output columns and their order; input columns not listed are dropped.
+	// Nil uses every column of every input, in the order they are first seen.
+	Columns []string
+	// SourceColumn, SheetColumn and DateColumn name provenance columns added after the data columns.
+	// An empty name leaves the column out.
+	SourceColumn string
+	SheetColumn  string
+	DateColumn   string
+	// DateOf computes the date of an input whose Date is empty from its Source, for example from a
+	// bhavcopy file name.
+	DateOf func(pSource string) string
+}
+
+// UnionTables stacks the rows of several tables under one header, aligning columns by header name
+// (case-insensitively, ignoring surrounding spaces) instead of by position, so files whose vendor reordered
+// or added columns line up. Columns missing from an input are left blank. A row holding every name of the
+// input's header, in any order and possibly with extra names, is a header left behind by appending files with
+// Join2DArray: it is dropped, and the rows after it are aligned by its names instead.
+
+// Step-by-Step Process:
+// 1. Split each input into segments at its embedded header rows, each segment with its own header.
+// 2. Build the output columns from UnionOptions.Columns or from the headers of all segments.
+// 3. For each segment, map its header positions to output positions; a repeated name maps to its next occurrence.
+// 4. Copy each row into a blank output row through the mapping of its segment.
+// 5. Append the provenance cells of the input to every row.
+func UnionTables(pInputs []UnionInput, pOptions UnionOptions) Table {
+	var lTable Table
+	lPositions := make(map[string]int)
+	for lColumn, lKey := range unionKeys(pOptions.Columns) {
+		lPositions[lKey] = len(lTable.Header) + 1
+		lTable.Header = append(lTable.Header, pOptions.Columns[lColumn])
+	}
+
+	lSegments := make([][]unionSegment, len(pInputs))
+	for lInput, lSource := range pInputs {
+		lSegments[lInput] = unionSegments(lSource.Table)
+		for lIndex := range lSegments[lInput] {
+			lSegment := &lSegments[lInput][lIndex]
+			for lColumn, lKey := range unionKeys(lSegment.header) {
+				if lPositions[lKey] == 0 && pOptions.Columns == nil {
+					lPositions[lKey] = len(lTable.Header) + 1
+					lTable.Header = append(lTable.Header, lSegment.header[lColumn])
+				}
+				// Positions are stored one higher so that zero means "not in the output".
+				lSegment.mapping = append(lSegment.mapping, lPositions[lKey]-1)
+			}
+		}
+	}
+
+	lWidth := len(lTable.Header)
+	var lProvenance []func(UnionInput) string
+	for _, lColumn := range []struct {
+		name  string
+		value func(UnionInput) string
+	}{
+		{pOptions.SourceColumn, func(pInput UnionInput) string { return pInput.Source }},
+		{pOptions.SheetColumn, func(pInput UnionInput) string { return pInput.Sheet }},
+		{pOptions.DateColumn, func(pInput UnionInput) string {
+			if pInput.Date == "" && pOptions.DateOf != nil {
+				return pOptions.DateOf(pInput.Source)
+			}
+			return pInput.Date
+		}},
+	} {
+		if lColumn.name != "" {
+			lTable.Header = append(lTable.Header, lColumn.name)
+			lProvenance = append(lProvenance, lColumn.value)
+		}
+	}
+
+	for lInput, lSource := range pInputs {
+		var lCells []string
+		for _, lValue := range lProvenance {
+			lCells = append(lCells, lValue(lSource))
+		}
+		for _, lSegment := range lSegments[lInput] {
+			for _, lRow := range lSegment.rows {
+				lOut := make([]string, lWidth, len(lTable.Header))
+				for lColumn, lCell := range lRow {
+					if lColumn < len(lSegment.mapping) && lSegment.mapping[lColumn] >= 0 {
+						lOut[lSegment.mapping[lColumn]] = lCell
+					}
+				}
+				lTable.Rows = append(lTable.Rows, append(lOut, lCells...))
+			}
+		}
+	}
+	return lTable
+}
+
+// UnionEntries stacks the results of ReadZip, Read or ReadArchiveReader with UnionTables. Each XLSX sheet is
+// its own input, so the sheet column can tell them apart; entries with an error are left out.
+func UnionEntries(pResults []EntryResult, pOptions UnionOptions) Table {
+	var lInputs []UnionInput
+	for _, lResult := range pResults {
+		if lResult.Err != nil {
+			continue
+		}
+		if len(lResult.Sheets) == 0 {
+			lInputs = append(lInputs, UnionInput{Table: lResult.Table, Source: lResult.Path})
+			continue
+		}
+		for _, lSheet := range lResult.Sheets {
+			lInputs = append(lInputs, UnionInput{Table: NewTable(lSheet.Rows), Source: lResult.Path, Sheet: lSheet.Name})
+		}
+	}
+	return UnionTables(lInputs, pOptions)
+}
+
+// unionKeys normalises header names for matching. A name repeated within the header gets its
+// occurrence number, so the second "Amount" matches the second "Amount" of another input.
+func unionKeys(pHeader []string) []string {
+	lKeys := make([]string, len(pHeader))
+	lCount := make(map[string]int)
+	for lColumn, lName := range pHeader {
+		lKey := strings.ToLower(strings.TrimSpace(lName))
+		lCount[lKey]++
+		if lCount[lKey] > 1 {
+			lKey += "#" + strconv.Itoa(lCount[lKey])
+		}
+		lKeys[lColumn] = lKey
+	}
+	return lKeys
+}
+
+// unionSegment is a run of rows of one input that share a header, with the output position of each column.
+type unionSegment struct {
+	header  []string
+	rows    [][]string
+	mapping []int
+}
+
+// unionSegments splits pTable at the rows that are embedded headers. The first segment has the table's own header.
+func unionSegments(pTable Table) []unionSegment {
+	lSegments := []unionSegment{{header: pTable.Header}}
+	for _, lRow := range pTable.Rows {
+		if embeddedHeader(lRow, pTable.Header) {
+			lSegments = append(lSegments, unionSegment{header: lRow})
+			continue
+		}
+		lLast := &lSegments[len(lSegments)-1]
+		lLast.rows = append(lLast.rows, lRow)
+	}
+	return lSegments
+}
+
+// embeddedHeader reports whether pRow holds every name of pHeader, in any order, ignoring case and
+// surrounding spaces. pRow may hold names that pHeader lacks, as when a later file added a column.
+func embeddedHeader(pRow []string, pHeader []string) bool {
+	if len(pHeader) == 0 || len(pRow) < len(pHeader) {
+		return false
+	}
+	lNames := make(map[string]bool)
+	for _, lKey := range unionKeys(pRow) {
+		lNames[lKey] = true
+	}
+	for _, lKey := range unionKeys(pHeader) {
+		if !lNames[lKey] {
+			return false
+		}
+	}
+	return true
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
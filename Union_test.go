@@ -0,0 +1,160 @@
+package readfiles
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestUnionTables(t *testing.T) {
+	lInputs := []UnionInput{
+		{
+			Table: Table{
+				Header: []string{"SYMBOL", "CLOSE"},
+				Rows: [][]string{
+					{"INFY", "1450"},
+					// A header left behind by Join2DArray is dropped whatever its case.
+					{"symbol", " Close "},
+					{"TCS", "3500", "extra"},
+				},
+			},
+			Source: "cm01JAN2024bhav.csv",
+		},
+		{
+			Table:  Table{Header: []string{" close ", "Symbol", "ISIN"}, Rows: [][]string{{"1451", "INFY", "INE009A01021"}, {"9"}}},
+			Source: "cm02JAN2024bhav.csv",
+			Sheet:  "Sheet1",
+			Date:   "2024-01-02",
+		},
+	}
+
+	lCases := []struct {
+		name       string
+		options    UnionOptions
+		wantHeader []string
+		wantRows   [][]string
+	}{
+		{
+			name:       "columns aligned by name",
+			wantHeader: []string{"SYMBOL", "CLOSE", "ISIN"},
+			wantRows:   [][]string{{"INFY", "1450", ""}, {"TCS", "3500", ""}, {"INFY", "1451", "INE009A01021"}, {"", "9", ""}},
+		},
+		{
+			name:       "fixed columns",
+			options:    UnionOptions{Columns: []string{"Isin", "Symbol"}},
+			wantHeader: []string{"Isin", "Symbol"},
+			wantRows:   [][]string{{"", "INFY"}, {"", "TCS"}, {"INE009A01021", "INFY"}, {"", ""}},
+		},
+		{
+			name: "provenance columns",
+			options: UnionOptions{
+				Columns:      []string{"SYMBOL"},
+				SourceColumn: "FILE",
+				SheetColumn:  "SHEET",
+				DateColumn:   "DATE",
+				DateOf:       func(pSource string) string { return pSource[2:11] },
+			},
+			wantHeader: []string{"SYMBOL", "FILE", "SHEET", "DATE"},
+			wantRows: [][]string{
+				{"INFY", "cm01JAN2024bhav.csv", "", "01JAN2024"},
+				{"TCS", "cm01JAN2024bhav.csv", "", "01JAN2024"},
+				// An input's own date wins over DateOf.
+				{"INFY", "cm02JAN2024bhav.csv", "Sheet1", "2024-01-02"},
+				{"", "cm02JAN2024bhav.csv", "Sheet1", "2024-01-02"},
+			},
+		},
+		{
+			name:       "date without DateOf",
+			options:    UnionOptions{Columns: []string{"CLOSE"}, DateColumn: "DATE"},
+			wantHeader: []string{"CLOSE", "DATE"},
+			wantRows:   [][]string{{"1450", ""}, {"3500", ""}, {"1451", "2024-01-02"}, {"9", "2024-01-02"}},
+		},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			lTable := UnionTables(lInputs, lCase.options)
+			if !reflect.DeepEqual(lTable.Header, lCase.wantHeader) {
+				t.Errorf("header = %q, want %q", lTable.Header, lCase.wantHeader)
+			}
+			if !reflect.DeepEqual(lTable.Rows, lCase.wantRows) {
+				t.Errorf("rows = %q\nwant   %q", lTable.Rows, lCase.wantRows)
+			}
+		})
+	}
+}
+
+func TestUnionTablesEmbeddedHeaders(t *testing.T) {
+	// Three bhavcopy files appended with Join2DArray: the vendor reordered the columns of the second
+	// and added SERIES to the third, so their header rows sit in the data.
+	lAppended := NewTable([][]string{
+		{"SYMBOL", "CLOSE"},
+		{"INFY", "1450"},
+		{"Close", "Symbol"},
+		{"1451", "INFY"},
+		{" SERIES ", "symbol", "close"},
+		{"EQ", "INFY", "1452"},
+		{"SYMBOL", "CLOSE"},
+		{"TCS", "3500"},
+		// A row with only some of the names is data.
+		{"CLOSE", "7"},
+	})
+
+	lTable := UnionTables([]UnionInput{{Table: lAppended, Source: "appended.csv"}}, UnionOptions{SourceColumn: "FILE"})
+	lWant := Table{
+		Header: []string{"SYMBOL", "CLOSE", " SERIES ", "FILE"},
+		Rows: [][]string{
+			{"INFY", "1450", "", "appended.csv"},
+			{"INFY", "1451", "", "appended.csv"},
+			{"INFY", "1452", "EQ", "appended.csv"},
+			{"TCS", "3500", "", "appended.csv"},
+			{"CLOSE", "7", "", "appended.csv"},
+		},
+	}
+	if !reflect.DeepEqual(lTable, lWant) {
+		t.Errorf("table = %q\nwant    %q", lTable, lWant)
+	}
+}
+
+func TestUnionTablesRepeatedNames(t *testing.T) {
+	lTable := UnionTables([]UnionInput{
+		{Table: Table{Header: []string{"Amount", "Item", "Amount"}, Rows: [][]string{{"1", "a", "2"}}}},
+		{Table: Table{Header: []string{"AMOUNT", "amount", "Amount"}, Rows: [][]string{{"3", "4", "5"}}}},
+	}, UnionOptions{})
+
+	// The n-th "Amount" of one input lines up with the n-th of another.
+	lWant := Table{
+		Header: []string{"Amount", "Item", "Amount", "Amount"},
+		Rows:   [][]string{{"1", "a", "2", ""}, {"3", "", "4", "5"}},
+	}
+	if !reflect.DeepEqual(lTable, lWant) {
+		t.Errorf("table = %q, want %q", lTable, lWant)
+	}
+	if lTable := UnionTables(nil, UnionOptions{SourceColumn: "FILE"}); !reflect.DeepEqual(lTable, Table{Header: []string{"FILE"}}) {
+		t.Errorf("no inputs = %q", lTable)
+	}
+}
+
+func TestUnionEntries(t *testing.T) {
+	lResults := []EntryResult{
+		{Path: "a.zip/a.csv", Table: NewTable([][]string{{"SYMBOL", "QTY"}, {"INFY", "10"}})},
+		{Path: "a.zip/broken.csv", Err: errors.New("broken")},
+		{Path: "a.zip/b.xlsx", Sheets: []XlsxSheetData{
+			{Name: "Equity", Rows: [][]string{{"Symbol", "Qty"}, {"TCS", "5"}}},
+			{Name: "Empty"},
+			{Name: "F&O", Rows: [][]string{{"SYMBOL", "LOTS"}, {"NIFTY", "2"}}},
+		}},
+	}
+	lTable := UnionEntries(lResults, UnionOptions{SourceColumn: "FILE", SheetColumn: "SHEET"})
+
+	lWant := Table{
+		Header: []string{"SYMBOL", "QTY", "LOTS", "FILE", "SHEET"},
+		Rows: [][]string{
+			{"INFY", "10", "", "a.zip/a.csv", ""},
+			{"TCS", "5", "", "a.zip/b.xlsx", "Equity"},
+			{"NIFTY", "", "2", "a.zip/b.xlsx", "F&O"},
+		},
+	}
+	if !reflect.DeepEqual(lTable, lWant) {
+		t.Errorf("table = %q\nwant    %q", lTable, lWant)
+	}
+}
@@ -0,0 +1,214 @@
+package readfiles
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+//---------------------------------------------------------- Verify Download --------------------------------------------------------
+
+// ErrSignatureInvalid is returned when a downloaded file does not match its detached signature.
+var ErrSignatureInvalid = errors.New("signature verification failed")
+
+// maxSidecarSize caps the checksum and signature files fetched next to an archive.
+const maxSidecarSize = 1 << 20
+
+// VerifyOptions checks that a downloaded archive is intact and authentic before it is read.
+// Every check that is configured must pass; a failed check is reported with ErrChecksumMismatch or ErrSignatureInvalid.
+type VerifyOptions struct {
+	// SHA256 and MD5 are expected hex digests of the archive.
+	SHA256 string
+	MD5    string
+	// Sidecar fetches the expected SHA-256 from SidecarURL, or from the archive URL + ".sha256" when that is empty.
+	// The file may hold the bare digest or "digest  filename" lines as written by sha256sum.
+	Sidecar    bool
+	SidecarURL string
+	// PGPPublicKey is an armored OpenPGP public key ring. When set, the archive must carry a detached signature
+	// from one of its keys, armored or binary.
+	PGPPublicKey string
+	// Ed25519PublicKey, when set, checks a detached ed25519 signature of the archive, given raw, in hex or in base64.
+	// An ed25519 signature covers the whole message at once, so the archive is held in memory for the check;
+	// archives larger than ReadOptions.MemoryLimit are refused rather than loaded.
+	Ed25519PublicKey ed25519.PublicKey
+	// SignatureURL is where the detached signature is fetched. Empty means the archive URL + ".sig".
+	SignatureURL string
+}
+
+// enabled reports whether any check is configured.
+func (v VerifyOptions) enabled() bool {
+	return v.SHA256 != "" || v.MD5 != "" || v.Sidecar || v.PGPPublicKey != "" || len(v.Ed25519PublicKey) > 0
+}
+
+// verifyDownload runs the checks of pOptions.Verify against a downloaded archive.
+
+// Step-by-Step Process:
+// 1. For an ed25519 check, validate the key length and make sure the archive fits in MemoryLimit.
+// 2. Hash the archive once with SHA-256 and MD5.
+// 3. Compare the digests with the configured values and with the sidecar file.
+// 4. Fetch the detached signature and check it with the OpenPGP key ring and/or the ed25519 key.
+func verifyDownload(pUrl string, pDownload *spooledDownload, pOptions ReadOptions) error {
+	lVerify := pOptions.Verify
+
+	if len(lVerify.Ed25519PublicKey) > 0 {
+		if len(lVerify.Ed25519PublicKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("verifyDownload:012 ed25519 public key is %d bytes, expected %d", len(lVerify.Ed25519PublicKey), ed25519.PublicKeySize)
+		}
+		lLimit := pOptions.MemoryLimit
+		if lLimit <= 0 {
+			lLimit = DefaultMemoryLimit
+		}
+		if pDownload.Size() > lLimit {
+			return fmt.Errorf("verifyDownload:013 archive of %d bytes is above the memory limit of %d needed for an ed25519 check", pDownload.Size(), lLimit)
+		}
+	}
+
+	lSHA256, lMD5 := sha256.New(), md5.New()
+	_, lErr := io.Copy(io.MultiWriter(lSHA256, lMD5), io.NewSectionReader(pDownload.ReaderAt(), 0, pDownload.Size()))
+	if lErr != nil {
+		return fmt.Errorf("verifyDownload:001 %w", lErr)
+	}
+
+	lErr = compareDigest("SHA-256", lVerify.SHA256, lSHA256)
+	if lErr != nil {
+		return fmt.Errorf("verifyDownload:002 %w", lErr)
+	}
+	lErr = compareDigest("MD5", lVerify.MD5, lMD5)
+	if lErr != nil {
+		return fmt.Errorf("verifyDownload:003 %w", lErr)
+	}
+
+	if lVerify.Sidecar {
+		lSidecarURL := lVerify.SidecarURL
+		if lSidecarURL == "" {
+			lSidecarURL = pUrl + ".sha256"
+		}
+		lSidecar, lErr := fetchSmall(lSidecarURL, pOptions)
+		if lErr != nil {
+			return fmt.Errorf("verifyDownload:004 %w", lErr)
+		}
+		lDigest := sidecarDigest(lSidecar, remoteArchiveName(pUrl))
+		if lDigest == "" {
+			return fmt.Errorf("verifyDownload:011 %s: %w: no digest in sidecar file", lSidecarURL, ErrChecksumMismatch)
+		}
+		lErr = compareDigest("SHA-256", lDigest, lSHA256)
+		if lErr != nil {
+			return fmt.Errorf("verifyDownload:005 %s: %w", lSidecarURL, lErr)
+		}
+	}
+
+	if lVerify.PGPPublicKey == "" && len(lVerify.Ed25519PublicKey) == 0 {
+		return nil
+	}
+	lSignatureURL := lVerify.SignatureURL
+	if lSignatureURL == "" {
+		lSignatureURL = pUrl + ".sig"
+	}
+	lSignature, lErr := fetchSmall(lSignatureURL, pOptions)
+	if lErr != nil {
+		return fmt.Errorf("verifyDownload:006 %w", lErr)
+	}
+
+	if lVerify.PGPPublicKey != "" {
+		lKeyRing, lErr := openpgp.ReadArmoredKeyRing(strings.NewReader(lVerify.PGPPublicKey))
+		if lErr != nil {
+			return fmt.Errorf("verifyDownload:007 %w", lErr)
+		}
+		lSigned := io.NewSectionReader(pDownload.ReaderAt(), 0, pDownload.Size())
+		if bytes.HasPrefix(bytes.TrimSpace(lSignature), []byte("-----BEGIN")) {
+			_, lErr = openpgp.CheckArmoredDetachedSignature(lKeyRing, lSigned, bytes.NewReader(lSignature), nil)
+		} else {
+			_, lErr = openpgp.CheckDetachedSignature(lKeyRing, lSigned, bytes.NewReader(lSignature), nil)
+		}
+		if lErr != nil {
+			return fmt.Errorf("verifyDownload:008 %w: OpenPGP: %v", ErrSignatureInvalid, lErr)
+		}
+	}
+
+	if len(lVerify.Ed25519PublicKey) > 0 {
+		lRaw := decodeSignature(lSignature)
+		lData := pDownload.data
+		if pDownload.file != nil {
+			lData, lErr = io.ReadAll(io.NewSectionReader(pDownload.ReaderAt(), 0, pDownload.Size()))
+			if lErr != nil {
+				return fmt.Errorf("verifyDownload:009 %w", lErr)
+			}
+		}
+		if len(lRaw) != ed25519.SignatureSize || !ed25519.Verify(lVerify.Ed25519PublicKey, lData, lRaw) {
+			return fmt.Errorf("verifyDownload:010 %w: ed25519", ErrSignatureInvalid)
+		}
+	}
+
+	log.Println("verifyDownload: verified", pUrl)
+	return nil
+}
+
+// compareDigest checks a hash against an expected hex digest; an empty expectation always passes.
+func compareDigest(pName string, pExpected string, pHash hash.Hash) error {
+	if pExpected == "" {
+		return nil
+	}
+	lActual := hex.EncodeToString(pHash.Sum(nil))
+	if !strings.EqualFold(strings.TrimSpace(pExpected), lActual) {
+		return fmt.Errorf("%w: %s is %s, expected %s", ErrChecksumMismatch, pName, lActual, pExpected)
+	}
+	return nil
+}
+
+// sidecarDigest picks the digest out of a checksum file: the line naming pName, or else the first digest in the file.
+func sidecarDigest(pContent []byte, pName string) string {
+	var lFirst string
+	for _, lLine := range strings.Split(string(pContent), "\n") {
+		lFields := strings.Fields(lLine)
+		if len(lFields) == 0 {
+			continue
+		}
+		if lFirst == "" {
+			lFirst = lFields[0]
+		}
+		if len(lFields) > 1 && strings.TrimPrefix(lFields[len(lFields)-1], "*") == pName {
+			return lFields[0]
+		}
+	}
+	return lFirst
+}
+
+// decodeSignature accepts a raw ed25519 signature or its hex or base64 text form.
+func decodeSignature(pSignature []byte) []byte {
+	if len(pSignature) == ed25519.SignatureSize {
+		return pSignature
+	}
+	lText := strings.TrimSpace(string(pSignature))
+	if lRaw, lErr := hex.DecodeString(lText); lErr == nil {
+		return lRaw
+	}
+	if lRaw, lErr := base64.StdEncoding.DecodeString(lText); lErr == nil {
+		return lRaw
+	}
+	return pSignature
+}
+
+// fetchSmall downloads a small companion file, such as a checksum or signature, into memory.
+// AcceptContentTypes is meant for the archive, so it is not applied here.
+func fetchSmall(pUrl string, pOptions ReadOptions) ([]byte, error) {
+	pOptions.AcceptContentTypes = nil
+	lResponse, lErr := fetchURL(pUrl, nil, pOptions)
+	if lErr != nil {
+		return nil, lErr
+	}
+	defer lResponse.Body.Close()
+	return io.ReadAll(io.LimitReader(lResponse.Body, maxSidecarSize))
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,148 @@
+package readfiles
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// pgpKeyPair returns a fresh OpenPGP entity and its armored public key.
+func pgpKeyPair(t *testing.T) (*openpgp.Entity, string) {
+	t.Helper()
+	lEntity, lErr := openpgp.NewEntity("ops", "", "ops@example.com", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	var lBuffer bytes.Buffer
+	lArmor, lErr := armor.Encode(&lBuffer, openpgp.PublicKeyType, nil)
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	if lErr := lEntity.Serialize(lArmor); lErr != nil {
+		t.Fatal(lErr)
+	}
+	lArmor.Close()
+	return lEntity, lBuffer.String()
+}
+
+func TestVerifyDownload(t *testing.T) {
+	lArchive := zipBytes(t, testFile{"a.csv", "1,2\n"})
+	lSHA := sha256.Sum256(lArchive)
+	lSHAHex := hex.EncodeToString(lSHA[:])
+	lMD5 := md5.Sum(lArchive)
+
+	lPublic, lPrivate, lErr := ed25519.GenerateKey(nil)
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	lEdSignature := ed25519.Sign(lPrivate, lArchive)
+	lOtherPublic, _, _ := ed25519.GenerateKey(nil)
+
+	lEntity, lArmoredKey := pgpKeyPair(t)
+	var lArmoredSig, lBinarySig bytes.Buffer
+	if lErr := openpgp.ArmoredDetachSign(&lArmoredSig, lEntity, bytes.NewReader(lArchive), nil); lErr != nil {
+		t.Fatal(lErr)
+	}
+	if lErr := openpgp.DetachSign(&lBinarySig, lEntity, bytes.NewReader(lArchive), nil); lErr != nil {
+		t.Fatal(lErr)
+	}
+	_, lOtherKey := pgpKeyPair(t)
+
+	lCases := []struct {
+		name    string
+		verify  VerifyOptions
+		files   map[string]string
+		memory  int64
+		wantErr error
+	}{
+		{name: "SHA-256 and MD5", verify: VerifyOptions{SHA256: strings.ToUpper(lSHAHex), MD5: hex.EncodeToString(lMD5[:])}},
+		{name: "SHA-256 mismatch", verify: VerifyOptions{SHA256: strings.Repeat("0", 64)}, wantErr: ErrChecksumMismatch},
+		{name: "MD5 mismatch", verify: VerifyOptions{MD5: strings.Repeat("0", 32)}, wantErr: ErrChecksumMismatch},
+		{name: "bare sidecar", verify: VerifyOptions{Sidecar: true}, files: map[string]string{".sha256": lSHAHex + "\n"}},
+		{name: "sha256sum sidecar", verify: VerifyOptions{Sidecar: true}, files: map[string]string{".sha256": strings.Repeat("0", 64) + "  other.zip\n" + lSHAHex + " *daily.zip\n"}},
+		{name: "sidecar at another URL", verify: VerifyOptions{Sidecar: true, SidecarURL: "/sums.txt"}, files: map[string]string{"sums": lSHAHex}},
+		{name: "sidecar mismatch", verify: VerifyOptions{Sidecar: true}, files: map[string]string{".sha256": strings.Repeat("0", 64)}, wantErr: ErrChecksumMismatch},
+		{name: "empty sidecar", verify: VerifyOptions{Sidecar: true}, files: map[string]string{".sha256": "\n"}, wantErr: ErrChecksumMismatch},
+		{name: "ed25519 raw", verify: VerifyOptions{Ed25519PublicKey: lPublic}, files: map[string]string{".sig": string(lEdSignature)}},
+		{name: "ed25519 hex", verify: VerifyOptions{Ed25519PublicKey: lPublic}, files: map[string]string{".sig": hex.EncodeToString(lEdSignature) + "\n"}},
+		{name: "ed25519 base64", verify: VerifyOptions{Ed25519PublicKey: lPublic}, files: map[string]string{".sig": base64.StdEncoding.EncodeToString(lEdSignature)}},
+		{name: "ed25519 wrong key", verify: VerifyOptions{Ed25519PublicKey: lOtherPublic}, files: map[string]string{".sig": string(lEdSignature)}, wantErr: ErrSignatureInvalid},
+		{name: "ed25519 short signature", verify: VerifyOptions{Ed25519PublicKey: lPublic}, files: map[string]string{".sig": "abc"}, wantErr: ErrSignatureInvalid},
+		{name: "ed25519 key of 3 bytes", verify: VerifyOptions{Ed25519PublicKey: ed25519.PublicKey{1, 2, 3}}, files: map[string]string{".sig": string(lEdSignature)}, wantErr: errAny},
+		{name: "ed25519 above the memory limit", verify: VerifyOptions{Ed25519PublicKey: lPublic}, files: map[string]string{".sig": string(lEdSignature)}, memory: 16, wantErr: errAny},
+		{name: "OpenPGP armored", verify: VerifyOptions{PGPPublicKey: lArmoredKey}, files: map[string]string{".sig": lArmoredSig.String()}},
+		{name: "OpenPGP binary at another URL", verify: VerifyOptions{PGPPublicKey: lArmoredKey, SignatureURL: "/detached.bin"}, files: map[string]string{"detached": lBinarySig.String()}},
+		{name: "OpenPGP wrong key", verify: VerifyOptions{PGPPublicKey: lOtherKey}, files: map[string]string{".sig": lArmoredSig.String()}, wantErr: ErrSignatureInvalid},
+		{name: "missing signature", verify: VerifyOptions{PGPPublicKey: lArmoredKey}, wantErr: errAny},
+	}
+	for _, lCase := range lCases {
+		t.Run(lCase.name, func(t *testing.T) {
+			lServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for lSuffix, lBody := range lCase.files {
+					if strings.HasSuffix(r.URL.Path, lSuffix) || strings.HasPrefix(r.URL.Path, "/"+lSuffix) {
+						w.Write([]byte(lBody))
+						return
+					}
+				}
+				if r.URL.Path == "/daily.zip" {
+					w.Write(lArchive)
+					return
+				}
+				http.NotFound(w, r)
+			}))
+			defer lServer.Close()
+
+			lVerify := lCase.verify
+			if lVerify.SidecarURL != "" {
+				lVerify.SidecarURL = lServer.URL + lVerify.SidecarURL
+			}
+			if lVerify.SignatureURL != "" {
+				lVerify.SignatureURL = lServer.URL + lVerify.SignatureURL
+			}
+			lOptions := ReadOptions{Verify: lVerify, MemoryLimit: lCase.memory, Retry: RetryPolicy{MaxAttempts: 1}}
+			lDownload := &spooledDownload{data: lArchive, size: int64(len(lArchive))}
+
+			lErr := verifyDownload(lServer.URL+"/daily.zip", lDownload, lOptions)
+			switch {
+			case lCase.wantErr == nil && lErr != nil:
+				t.Fatal(lErr)
+			case lCase.wantErr == errAny && lErr == nil:
+				t.Fatal("the check passed")
+			case lCase.wantErr != nil && lCase.wantErr != errAny && !errors.Is(lErr, lCase.wantErr):
+				t.Fatalf("err = %v, want %v", lErr, lCase.wantErr)
+			}
+		})
+	}
+}
+
+// errAny marks a test case that must fail without naming a specific error.
+var errAny = errors.New("any error")
+
+func TestReadZipWithVerify(t *testing.T) {
+	lArchive := zipBytes(t, testFile{"a.csv", "1,2\n"})
+	lServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(lArchive)
+	}))
+	defer lServer.Close()
+
+	lSum := sha256.Sum256(lArchive)
+	lResults, lErr := ReadZipWithOptions(lServer.URL+"/a.zip", "", ReadOptions{Verify: VerifyOptions{SHA256: hex.EncodeToString(lSum[:])}})
+	if lErr != nil || len(lResults) != 1 {
+		t.Fatalf("results = %v, %v", lResults, lErr)
+	}
+	if _, lErr := ReadZipWithOptions(lServer.URL+"/a.zip", "", ReadOptions{Verify: VerifyOptions{SHA256: "00"}}); !errors.Is(lErr, ErrChecksumMismatch) {
+		t.Fatalf("err = %v, want ErrChecksumMismatch", lErr)
+	}
+}
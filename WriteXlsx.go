@@ -0,0 +1,432 @@
+package readfiles
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+)
+
+//----------------------------------------------------------- Write XLSX ------------------------------------------------------------
+
+// XlsxSheet is one worksheet to be written by WriteXlsx.
+// The first row of Rows is treated as the header row.
+type XlsxSheet struct {
+	Name string
+	Rows [][]string
+}
+
+// XlsxWriteOptions controls how WriteXlsx lays out each worksheet.
+// The zero value writes every cell as plain text with no styling.
+type XlsxWriteOptions struct {
+	// BoldHeader renders the first row of every sheet in bold.
+	BoldHeader bool
+	// FreezeHeader keeps the first row visible while scrolling.
+	FreezeHeader bool
+	// AutoWidth sizes every column to its longest value, capped at MaxColumnWidth.
+	AutoWidth bool
+	// MaxColumnWidth caps AutoWidth. Zero means 60 characters.
+	MaxColumnWidth float64
+	// TypedCells writes numeric values as numbers and values matching DateLayouts as dates.
+	TypedCells bool
+	// DateLayouts are the Go time layouts tried when TypedCells is set. Nil means DefaultDateLayouts.
+	DateLayouts []string
+	// DateFormat is the Excel number format applied to date cells. Empty means "yyyy-mm-dd".
+	DateFormat string
+	// Stream writes the sheets through excelize's StreamWriter, which keeps memory flat for large outputs.
+	Stream bool
+}
+
+// DefaultDateLayouts are the date layouts recognised by TypedCells when none are configured.
+// They cover ISO dates and the DD-MON-YYYY form used in exchange bhavcopy files.
+var DefaultDateLayouts = []string{"2006-01-02", "02-Jan-2006", "02-01-2006", "02/01/2006"}
+
+// DefaultXlsxWriteOptions returns the options used for report downloads:
+// bold frozen header, auto-width columns and typed cells.
+func DefaultXlsxWriteOptions() XlsxWriteOptions {
+	return XlsxWriteOptions{
+		BoldHeader:   true,
+		FreezeHeader: true,
+		AutoWidth:    true,
+		TypedCells:   true,
+	}
+}
+
+// SheetFromTable wraps a Table as an XlsxSheet with its header as the first row.
+func SheetFromTable(pName string, pTable Table) XlsxSheet {
+	return XlsxSheet{Name: pName, Rows: pTable.Records()}
+}
+
+// WriteXlsx builds an XLSX workbook from the given sheets and writes it to pWriter.
+
+// Step-by-Step Process:
+// 1. Create a new workbook, in streaming or in-memory mode depending on pOptions.Stream.
+// 2. Add one worksheet per XlsxSheet, renaming the default "Sheet1" for the first one.
+// 3. Write the rows, styling the header and converting typed cells as configured.
+// 4. Write the finished workbook to pWriter and close it.
+func WriteXlsx(pWriter io.Writer, pSheets []XlsxSheet, pOptions XlsxWriteOptions) error {
+	log.Println("WriteXlsx(+)")
+
+	lStream, lErr := NewXlsxStreamWriter(pOptions)
+	if lErr != nil {
+		return fmt.Errorf("WriteXlsx:001 %w", lErr)
+	}
+	defer lStream.Close()
+
+	for _, lSheet := range pSheets {
+		var lHeader []string
+		var lRows [][]string
+		if len(lSheet.Rows) > 0 {
+			lHeader = lSheet.Rows[0]
+			lRows = lSheet.Rows[1:]
+		}
+
+		var lWidths []float64
+		if pOptions.AutoWidth {
+			lWidths = xlsxColumnWidths(lSheet.Rows, pOptions.MaxColumnWidth)
+		}
+
+		lErr = lStream.AddSheet(lSheet.Name, lHeader, lWidths)
+		if lErr != nil {
+			return fmt.Errorf("WriteXlsx:002 %w", lErr)
+		}
+		for _, lRow := range lRows {
+			lErr = lStream.WriteRow(lRow)
+			if lErr != nil {
+				return fmt.Errorf("WriteXlsx:003 %w", lErr)
+			}
+		}
+	}
+
+	lErr = lStream.Save(pWriter)
+	if lErr != nil {
+		return fmt.Errorf("WriteXlsx:004 %w", lErr)
+	}
+
+	log.Println("WriteXlsx(-)")
+	return nil
+}
+
+// WriteXlsxFile writes the sheets to a new XLSX file at pPath, replacing any existing file.
+func WriteXlsxFile(pPath string, pSheets []XlsxSheet, pOptions XlsxWriteOptions) error {
+	lOut, lErr := os.Create(pPath)
+	if lErr != nil {
+		return fmt.Errorf("WriteXlsxFile:001 %w", lErr)
+	}
+	defer lOut.Close()
+
+	lErr = WriteXlsx(lOut, pSheets, pOptions)
+	if lErr != nil {
+		return fmt.Errorf("WriteXlsxFile:002 %w", lErr)
+	}
+	return lOut.Close()
+}
+
+// WriteXlsxResponse sends the sheets as an XLSX download.
+// It sets the spreadsheet Content-Type and an attachment Content-Disposition using pFilename
+// before any of the body is written, so it must be called before anything else writes to w.
+func WriteXlsxResponse(w http.ResponseWriter, pFilename string, pSheets []XlsxSheet, pOptions XlsxWriteOptions) error {
+	if !strings.HasSuffix(strings.ToLower(pFilename), ".xlsx") {
+		pFilename += ".xlsx"
+	}
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", pFilename))
+
+	lErr := WriteXlsx(w, pSheets, pOptions)
+	if lErr != nil {
+		return fmt.Errorf("WriteXlsxResponse:001 %w", lErr)
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
+
+//------------------------------------------------------- XLSX Stream Writer --------------------------------------------------------
+
+// XlsxStreamWriter writes a workbook one row at a time.
+// Call AddSheet to start each worksheet, WriteRow for its data rows, and Save once at the end.
+// When the options ask for streaming, rows go through excelize's StreamWriter and are not held in memory.
+type XlsxStreamWriter struct {
+	file       *excelize.File
+	options    XlsxWriteOptions
+	stream     *excelize.StreamWriter
+	sheetName  string
+	sheetCount int
+	rowIndex   int
+	boldStyle  int
+	dateStyle  int
+	sheetNames map[string]bool
+}
+
+// NewXlsxStreamWriter creates an empty workbook ready for AddSheet.
+func NewXlsxStreamWriter(pOptions XlsxWriteOptions) (*XlsxStreamWriter, error) {
+	lWriter := &XlsxStreamWriter{
+		file:       excelize.NewFile(),
+		options:    pOptions,
+		sheetNames: make(map[string]bool),
+	}
+
+	lErr := lWriter.createStyles()
+	if lErr != nil {
+		lWriter.file.Close()
+		return nil, fmt.Errorf("NewXlsxStreamWriter:001 %w", lErr)
+	}
+	return lWriter, nil
+}
+
+// createStyles registers the bold header style and the date number format in the workbook.
+func (w *XlsxStreamWriter) createStyles() error {
+	var lErr error
+	w.boldStyle, lErr = w.file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if lErr != nil {
+		return lErr
+	}
+
+	lDateFormat := w.options.DateFormat
+	if lDateFormat == "" {
+		lDateFormat = "yyyy-mm-dd"
+	}
+	w.dateStyle, lErr = w.file.NewStyle(&excelize.Style{CustomNumFmt: &lDateFormat})
+	return lErr
+}
+
+// AddSheet finishes the current worksheet and starts a new one named pName.
+// pHeader is written as the first row; pWidths, if given, sets the width of each column in order.
+func (w *XlsxStreamWriter) AddSheet(pName string, pHeader []string, pWidths []float64) error {
+	lErr := w.flushSheet()
+	if lErr != nil {
+		return fmt.Errorf("AddSheet:001 %w", lErr)
+	}
+
+	lName := xlsxSheetName(pName, w.sheetCount+1, w.sheetNames)
+	if w.sheetNames[strings.ToLower(lName)] {
+		return fmt.Errorf("AddSheet:002 duplicate sheet name %q", lName)
+	}
+
+	if w.sheetCount == 0 {
+		lErr = w.file.SetSheetName("Sheet1", lName)
+	} else {
+		_, lErr = w.file.NewSheet(lName)
+	}
+	if lErr != nil {
+		return fmt.Errorf("AddSheet:003 %w", lErr)
+	}
+	w.sheetNames[strings.ToLower(lName)] = true
+	w.sheetName = lName
+	w.sheetCount++
+	w.rowIndex = 0
+
+	if w.options.Stream {
+		w.stream, lErr = w.file.NewStreamWriter(lName)
+		if lErr != nil {
+			return fmt.Errorf("AddSheet:004 %w", lErr)
+		}
+	}
+
+	// The stream writer only accepts widths and panes before the first row.
+	for lIndex, lWidth := range pWidths {
+		if lWidth <= 0 {
+			continue
+		}
+		if w.stream != nil {
+			lErr = w.stream.SetColWidth(lIndex+1, lIndex+1, lWidth)
+		} else {
+			lColumn, _ := excelize.ColumnNumberToName(lIndex + 1)
+			lErr = w.file.SetColWidth(lName, lColumn, lColumn, lWidth)
+		}
+		if lErr != nil {
+			return fmt.Errorf("AddSheet:005 %w", lErr)
+		}
+	}
+
+	if w.options.FreezeHeader && len(pHeader) > 0 {
+		lPanes := &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}
+		if w.stream != nil {
+			lErr = w.stream.SetPanes(lPanes)
+		} else {
+			lErr = w.file.SetPanes(lName, lPanes)
+		}
+		if lErr != nil {
+			return fmt.Errorf("AddSheet:006 %w", lErr)
+		}
+	}
+
+	if len(pHeader) > 0 {
+		lErr = w.writeRow(pHeader, true)
+		if lErr != nil {
+			return fmt.Errorf("AddSheet:007 %w", lErr)
+		}
+	}
+	return nil
+}
+
+// WriteRow appends one data row to the current worksheet.
+func (w *XlsxStreamWriter) WriteRow(pRow []string) error {
+	if w.sheetCount == 0 {
+		return fmt.Errorf("WriteRow:001 AddSheet must be called before WriteRow")
+	}
+	lErr := w.writeRow(pRow, false)
+	if lErr != nil {
+		return fmt.Errorf("WriteRow:002 %w", lErr)
+	}
+	return nil
+}
+
+// writeRow converts and writes a single row at the next row position.
+func (w *XlsxStreamWriter) writeRow(pRow []string, pHeader bool) error {
+	w.rowIndex++
+
+	lCells := make([]interface{}, len(pRow))
+	for lIndex, lValue := range pRow {
+		lCell := excelize.Cell{Value: lValue}
+		if pHeader {
+			if w.options.BoldHeader {
+				lCell.StyleID = w.boldStyle
+			}
+		} else if w.options.TypedCells {
+			lCell.Value = xlsxTypedValue(lValue, w.options.DateLayouts)
+			if _, lIsDate := lCell.Value.(time.Time); lIsDate {
+				lCell.StyleID = w.dateStyle
+			}
+		}
+		lCells[lIndex] = lCell
+	}
+
+	lStart, _ := excelize.CoordinatesToCellName(1, w.rowIndex)
+	if w.stream != nil {
+		return w.stream.SetRow(lStart, lCells)
+	}
+
+	for lIndex, lValue := range lCells {
+		lCell := lValue.(excelize.Cell)
+		lName, _ := excelize.CoordinatesToCellName(lIndex+1, w.rowIndex)
+		lErr := w.file.SetCellValue(w.sheetName, lName, lCell.Value)
+		if lErr != nil {
+			return lErr
+		}
+		if lCell.StyleID != 0 {
+			lErr = w.file.SetCellStyle(w.sheetName, lName, lName, lCell.StyleID)
+			if lErr != nil {
+				return lErr
+			}
+		}
+	}
+	return nil
+}
+
+// flushSheet completes the stream of the current worksheet, if any.
+func (w *XlsxStreamWriter) flushSheet() error {
+	if w.stream == nil {
+		return nil
+	}
+	lErr := w.stream.Flush()
+	w.stream = nil
+	return lErr
+}
+
+// Save finishes the last worksheet and writes the workbook to pWriter.
+// A workbook with no sheets is written with a single empty "Sheet1".
+func (w *XlsxStreamWriter) Save(pWriter io.Writer) error {
+	lErr := w.flushSheet()
+	if lErr != nil {
+		return fmt.Errorf("Save:001 %w", lErr)
+	}
+	lErr = w.file.Write(pWriter)
+	if lErr != nil {
+		return fmt.Errorf("Save:002 %w", lErr)
+	}
+	return nil
+}
+
+// Close releases the temporary files held by the underlying workbook.
+func (w *XlsxStreamWriter) Close() error {
+	return w.file.Close()
+}
+
+//-----------------------------------------------------------------------------------------------------------------------------------
+
+// xlsxNumberPattern matches plain decimal numbers. Exponents and leading zeros are left out on purpose,
+// so that codes such as "0012" or "1E5" stay as text.
+var xlsxNumberPattern = regexp.MustCompile(`^[+-]?(0|[1-9][0-9]*)(\.[0-9]+)?$`)
+
+// xlsxTypedValue converts a cell string to a float64 or time.Time when it clearly is one,
+// and returns the original string otherwise.
+func xlsxTypedValue(pValue string, pLayouts []string) interface{} {
+	lValue := strings.TrimSpace(pValue)
+	if lValue == "" {
+		return pValue
+	}
+
+	// Excel keeps 15 significant digits, so longer numbers (account numbers, ISIN parts) stay as text.
+	if xlsxNumberPattern.MatchString(lValue) && len(strings.TrimLeft(lValue, "+-.0")) <= 15 {
+		lNumber, lErr := strconv.ParseFloat(lValue, 64)
+		if lErr == nil {
+			return lNumber
+		}
+	}
+
+	if pLayouts == nil {
+		pLayouts = DefaultDateLayouts
+	}
+	for _, lLayout := range pLayouts {
+		lDate, lErr := time.Parse(lLayout, lValue)
+		if lErr == nil {
+			return lDate
+		}
+	}
+	return pValue
+}
+
+// xlsxColumnWidths returns the width of every column based on its longest value.
+func xlsxColumnWidths(pRows [][]string, pMax float64) []float64 {
+	if pMax <= 0 {
+		pMax = 60
+	}
+	var lWidths []float64
+	for _, lRow := range pRows {
+		for lIndex, lValue := range lRow {
+			for len(lWidths) <= lIndex {
+				lWidths = append(lWidths, 8)
+			}
+			lWidth := float64(utf8.RuneCountInString(lValue)) + 2
+			if lWidth > pMax {
+				lWidth = pMax
+			}
+			if lWidth > lWidths[lIndex] {
+				lWidths[lIndex] = lWidth
+			}
+		}
+	}
+	return lWidths
+}
+
+// xlsxSheetName makes pName acceptable to Excel: no []:*?/\ characters and at most 31 characters.
+// An empty name becomes "SheetN", where N is the sheet position or, if that name is in pUsed (keyed in
+// lower case), the next number giving an unused name.
+func xlsxSheetName(pName string, pPosition int, pUsed map[string]bool) string {
+	lName := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(pName))
+	if lName == "" {
+		lName = "Sheet" + strconv.Itoa(pPosition)
+		for pUsed[strings.ToLower(lName)] {
+			pPosition++
+			lName = "Sheet" + strconv.Itoa(pPosition)
+		}
+	}
+	if utf8.RuneCountInString(lName) > 31 {
+		lName = string([]rune(lName)[:31])
+	}
+	return lName
+}
@@ -0,0 +1,166 @@
+package readfiles
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestWriteXlsx(t *testing.T) {
+	lSheets := []XlsxSheet{
+		{Name: "Trades/2024", Rows: [][]string{{"SYMBOL", "QTY", "DATE", "CODE"}, {"INFY", "10", "01-Jan-2024", "0012"}, {"TCS", "2.5", "2024-01-02", "1E5"}}},
+		{Name: "", Rows: [][]string{{"ONLY", "HEADER"}}},
+	}
+
+	for _, lStream := range []bool{false, true} {
+		t.Run(map[bool]string{false: "memory", true: "stream"}[lStream], func(t *testing.T) {
+			lOptions := DefaultXlsxWriteOptions()
+			lOptions.Stream = lStream
+			var lBuffer bytes.Buffer
+			if lErr := WriteXlsx(&lBuffer, lSheets, lOptions); lErr != nil {
+				t.Fatal(lErr)
+			}
+
+			lBook, lErr := excelize.OpenReader(&lBuffer)
+			if lErr != nil {
+				t.Fatal(lErr)
+			}
+			defer lBook.Close()
+			if lNames := lBook.GetSheetList(); !reflect.DeepEqual(lNames, []string{"Trades_2024", "Sheet2"}) {
+				t.Fatalf("sheets = %v", lNames)
+			}
+
+			lCases := []struct {
+				cell string
+				want string
+			}{
+				{"A1", "SYMBOL"},
+				{"B2", "10"},
+				{"B3", "2.5"},
+				{"C2", "2024-01-01"},
+				{"D2", "0012"},
+				{"D3", "1E5"},
+			}
+			for _, lCase := range lCases {
+				lValue, lErr := lBook.GetCellValue("Trades_2024", lCase.cell)
+				if lErr != nil || lValue != lCase.want {
+					t.Errorf("%s = %q, %v; want %q", lCase.cell, lValue, lErr, lCase.want)
+				}
+			}
+
+			// Numbers and dates are stored as numbers; codes that only look numeric stay text.
+			lRaw, _ := lBook.GetCellValue("Trades_2024", "C2", excelize.Options{RawCellValue: true})
+			if lRaw != "45292" {
+				t.Errorf("raw C2 = %q, want the date serial 45292", lRaw)
+			}
+			lType, _ := lBook.GetCellType("Trades_2024", "D2")
+			if lType == excelize.CellTypeNumber || lType == excelize.CellTypeUnset {
+				t.Errorf("D2 type = %v, want text", lType)
+			}
+
+			lStyle, _ := lBook.GetCellStyle("Trades_2024", "A1")
+			lFormat, lErr := lBook.GetStyle(lStyle)
+			if lErr != nil || lFormat.Font == nil || !lFormat.Font.Bold {
+				t.Errorf("header style = %+v, %v; want bold", lFormat, lErr)
+			}
+			lPanes, lErr := lBook.GetPanes("Trades_2024")
+			if lErr != nil || !lPanes.Freeze || lPanes.YSplit != 1 {
+				t.Errorf("panes = %+v, %v; want the header frozen", lPanes, lErr)
+			}
+		})
+	}
+}
+
+func TestWriteXlsxErrors(t *testing.T) {
+	lSheets := []XlsxSheet{{Name: "Data"}, {Name: "data"}}
+	if lErr := WriteXlsx(&bytes.Buffer{}, lSheets, XlsxWriteOptions{}); lErr == nil || !strings.Contains(lErr.Error(), "duplicate sheet name") {
+		t.Fatalf("err = %v, want duplicate sheet name", lErr)
+	}
+	// An unnamed sheet never clashes with a name given to another sheet.
+	var lBuffer bytes.Buffer
+	if lErr := WriteXlsx(&lBuffer, []XlsxSheet{{Name: "Sheet2"}, {}}, XlsxWriteOptions{}); lErr != nil {
+		t.Fatalf("unnamed sheet after \"Sheet2\": %v", lErr)
+	}
+	lBook, lErr := excelize.OpenReader(&lBuffer)
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	if lNames := lBook.GetSheetList(); !reflect.DeepEqual(lNames, []string{"Sheet2", "Sheet3"}) {
+		t.Errorf("sheets = %v, want Sheet2 and Sheet3", lNames)
+	}
+
+	lWriter, lErr := NewXlsxStreamWriter(XlsxWriteOptions{})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	defer lWriter.Close()
+	if lErr := lWriter.WriteRow([]string{"x"}); lErr == nil {
+		t.Fatal("WriteRow before AddSheet succeeded")
+	}
+}
+
+func TestWriteXlsxResponse(t *testing.T) {
+	lRecorder := httptest.NewRecorder()
+	lErr := WriteXlsxResponse(lRecorder, "report", []XlsxSheet{{Name: "R", Rows: [][]string{{"A"}, {"1"}}}}, XlsxWriteOptions{})
+	if lErr != nil {
+		t.Fatal(lErr)
+	}
+	if lType := lRecorder.Header().Get("Content-Type"); !strings.Contains(lType, "spreadsheetml") {
+		t.Errorf("Content-Type = %q", lType)
+	}
+	if lDisposition := lRecorder.Header().Get("Content-Disposition"); lDisposition != `attachment; filename="report.xlsx"` {
+		t.Errorf("Content-Disposition = %q", lDisposition)
+	}
+	if _, lErr := excelize.OpenReader(lRecorder.Body); lErr != nil {
+		t.Errorf("body is not a workbook: %v", lErr)
+	}
+}
+
+func TestXlsxTypedValue(t *testing.T) {
+	lCases := []struct {
+		value string
+		want  interface{}
+	}{
+		{"42", float64(42)},
+		{"-3.25", -3.25},
+		{" 7 ", float64(7)},
+		{"0012", "0012"},
+		{"1E5", "1E5"},
+		{"1234567890123456", "1234567890123456"},
+		{"15-Mar-2024", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"2024-03-15", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"INFY", "INFY"},
+		{"", ""},
+	}
+	for _, lCase := range lCases {
+		if lGot := xlsxTypedValue(lCase.value, nil); !reflect.DeepEqual(lGot, lCase.want) {
+			t.Errorf("xlsxTypedValue(%q) = %#v, want %#v", lCase.value, lGot, lCase.want)
+		}
+	}
+}
+
+func TestXlsxSheetName(t *testing.T) {
+	lCases := []struct {
+		name     string
+		position int
+		used     map[string]bool
+		want     string
+	}{
+		{"Trades", 1, nil, "Trades"},
+		{"a[b]:c*d?e/f\\g", 1, nil, "a_b__c_d_e_f_g"},
+		{"  ", 3, nil, "Sheet3"},
+		// "Sheet3" and "Sheet4" are taken, so the next free number is used.
+		{"", 3, map[string]bool{"sheet3": true, "sheet4": true}, "Sheet5"},
+		{strings.Repeat("x", 40), 1, nil, strings.Repeat("x", 31)},
+	}
+	for _, lCase := range lCases {
+		if lGot := xlsxSheetName(lCase.name, lCase.position, lCase.used); lGot != lCase.want {
+			t.Errorf("xlsxSheetName(%q) = %q, want %q", lCase.name, lGot, lCase.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package readfiles
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"testing"
+)
+
+// testFile is one entry of an archive built by a test.
+type testFile struct {
+	name string
+	body string
+}
+
+// zipBytes builds a ZIP archive holding pFiles in order.
+func zipBytes(t *testing.T, pFiles ...testFile) []byte {
+	t.Helper()
+	var lBuffer bytes.Buffer
+	lWriter := zip.NewWriter(&lBuffer)
+	for _, lFile := range pFiles {
+		lEntry, lErr := lWriter.Create(lFile.name)
+		if lErr != nil {
+			t.Fatal(lErr)
+		}
+		if _, lErr = lEntry.Write([]byte(lFile.body)); lErr != nil {
+			t.Fatal(lErr)
+		}
+	}
+	if lErr := lWriter.Close(); lErr != nil {
+		t.Fatal(lErr)
+	}
+	return lBuffer.Bytes()
+}
+
+// tarBytes builds a tar archive holding pFiles in order as regular files.
+func tarBytes(t *testing.T, pFiles ...testFile) []byte {
+	t.Helper()
+	var lBuffer bytes.Buffer
+	lWriter := tar.NewWriter(&lBuffer)
+	for _, lFile := range pFiles {
+		lErr := lWriter.WriteHeader(&tar.Header{Name: lFile.name, Mode: 0o644, Size: int64(len(lFile.body)), Typeflag: tar.TypeReg})
+		if lErr != nil {
+			t.Fatal(lErr)
+		}
+		if _, lErr = lWriter.Write([]byte(lFile.body)); lErr != nil {
+			t.Fatal(lErr)
+		}
+	}
+	if lErr := lWriter.Close(); lErr != nil {
+		t.Fatal(lErr)
+	}
+	return lBuffer.Bytes()
+}
+
+// gzipBytes compresses pData with gzip, recording pName as the original file name.
+func gzipBytes(t *testing.T, pName string, pData []byte) []byte {
+	t.Helper()
+	var lBuffer bytes.Buffer
+	lWriter := gzip.NewWriter(&lBuffer)
+	lWriter.Name = pName
+	if _, lErr := lWriter.Write(pData); lErr != nil {
+		t.Fatal(lErr)
+	}
+	if lErr := lWriter.Close(); lErr != nil {
+		t.Fatal(lErr)
+	}
+	return lBuffer.Bytes()
+}
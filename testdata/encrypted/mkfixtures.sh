@@ -0,0 +1,53 @@
+#!/bin/sh
+# Regenerates the encrypted ZIP fixtures with Info-ZIP zip and libarchive's bsdtar.
+# Every archive holds trades.csv (26 bytes) and t.csv (2 bytes), encrypted with the password "secret".
+# bsdtar writes AE-1 for trades.csv and AE-2, with a zero CRC, for the small t.csv, and uses data descriptors.
+set -e
+cd "$(dirname "$0")"
+lWork=$(mktemp -d)
+trap 'rm -rf "$lWork"' EXIT
+printf 'SYMBOL,QTY\nINFY,10\nTCS,20\n' > "$lWork/trades.csv"
+printf 'x\n' > "$lWork/t.csv"
+lHere=$(pwd)
+rm -f ./*.zip
+cd "$lWork"
+# ZipCrypto with data descriptors, from Info-ZIP and from libarchive: the check byte is the high byte of the DOS time.
+zip -q -X -P secret "$lHere/zipcrypto-dd.zip" trades.csv t.csv
+bsdtar --format zip --options zip:encryption=zipcrypt --passphrase secret -cf "$lHere/zipcrypto-libarchive.zip" trades.csv t.csv
+# ZipCrypto, stored, without data descriptors: the check byte is the high byte of the CRC.
+# Neither tool writes this layout for encrypted entries, so it is built here from the PKWARE APPNOTE.
+python3 - "$lHere/zipcrypto.zip" trades.csv t.csv <<'PYTHON'
+import struct, sys, zlib
+
+def update(keys, byte):
+    keys[0] = zlib.crc32(bytes([byte]), keys[0] ^ 0xffffffff) ^ 0xffffffff
+    keys[1] = ((keys[1] + (keys[0] & 0xff)) * 134775813 + 1) & 0xffffffff
+    keys[2] = zlib.crc32(bytes([keys[1] >> 24]), keys[2] ^ 0xffffffff) ^ 0xffffffff
+
+def encrypt(data, password):
+    keys = [0x12345678, 0x23456789, 0x34567890]
+    for byte in password:
+        update(keys, byte)
+    out = bytearray()
+    for byte in data:
+        temp = (keys[2] | 2) & 0xffff
+        out.append(byte ^ (((temp * (temp ^ 1)) >> 8) & 0xff))
+        update(keys, byte)
+    return bytes(out)
+
+archive, central = bytearray(), bytearray()
+for name in sys.argv[2:]:
+    data = open(name, "rb").read()
+    crc = zlib.crc32(data)
+    body = encrypt(bytes(range(11)) + bytes([crc >> 24]) + data, b"secret")
+    fields = struct.pack("<HHHHHIIIHH", 20, 1, 0, 0, 0x21, crc, len(body), len(data), len(name), 0)
+    central += b"PK\x01\x02" + struct.pack("<H", 20) + fields + struct.pack("<HHHII", 0, 0, 0, 0, len(archive)) + name.encode()
+    archive += b"PK\x03\x04" + fields + name.encode() + body
+count = len(sys.argv) - 2
+archive += central + b"PK\x05\x06" + struct.pack("<HHHHIIH", 0, 0, count, count, len(central), len(archive), 0)
+open(sys.argv[1], "wb").write(archive)
+PYTHON
+bsdtar --format zip --options zip:encryption=zipcrypt --passphrase secret -cf "$lHere/zipcrypto-dd.zip" trades.csv t.csv
+bsdtar --format zip --options zip:encryption=aes128 --passphrase secret -cf "$lHere/aes128.zip" trades.csv t.csv
+bsdtar --format zip --options zip:encryption=aes256 --passphrase secret -cf "$lHere/aes256.zip" trades.csv t.csv
+bsdtar --format zip --options zip:encryption=aes256,zip:compression=store --passphrase secret -cf "$lHere/aes256-store.zip" trades.csv t.csv